@@ -14,8 +14,8 @@ provider "mirage" {
 # Example: Generate a simple DAG from a GCS template
 resource "mirage_dag_generator" "simple_dag" {
   dag_generator_backend_url = "https://your-backend-service.com"
-  template_gcs_path        = "gs://your-bucket/templates/simple_dag.py.j2"
-  target_gcs_path          = "gs://your-bucket/dags/simple_dag.py"
+  template_path            = "gs://your-bucket/templates/simple_dag.py.j2"
+  target_path              = "gs://your-bucket/dags/simple_dag.py"
   
   context_json = jsonencode({
     dag_id            = "simple_example_dag"
@@ -34,7 +34,7 @@ output "dag_checksum" {
 }
 
 output "dag_generation" {
-  value = mirage_dag_generator.simple_dag.gcs_generation_number
+  value = mirage_dag_generator.simple_dag.generation
 }
 
 output "dag_path" {
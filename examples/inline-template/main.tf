@@ -18,7 +18,7 @@ resource "mirage_dag_generator" "inline_dag" {
   # Use inline template content instead of GCS path
   template_content = file("${path.module}/templates/data_pipeline.py.j2")
   
-  target_gcs_path = "gs://your-bucket/dags/data_pipeline.py"
+  target_path = "gs://your-bucket/dags/data_pipeline.py"
   
   context_json = jsonencode({
     dag_id            = "data_pipeline_dag"
@@ -71,6 +71,6 @@ output "inline_dag_info" {
   value = {
     id         = mirage_dag_generator.inline_dag.id
     checksum   = mirage_dag_generator.inline_dag.generated_file_checksum
-    generation = mirage_dag_generator.inline_dag.gcs_generation_number
+    generation = mirage_dag_generator.inline_dag.generation
   }
 } 
\ No newline at end of file
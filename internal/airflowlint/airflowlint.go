@@ -0,0 +1,863 @@
+// Package airflowlint finds structural mistakes in Airflow DAG files without
+// importing them.
+//
+// The checks work on the tokens of the file rather than by running it, so
+// they only see what is written literally: task and DAG IDs given as string
+// literals, dependencies between task variables set with >> and <<, and
+// schedules given as strings. Anything computed at import time, such as an
+// ID built with an f-string or tasks created in a loop, is skipped rather
+// than guessed at.
+package airflowlint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mm-aranda/terraform-provider-mirage/internal/pysyntax"
+)
+
+// Rule names a check.
+type Rule string
+
+const (
+	// DuplicateTaskID reports a task_id used twice in the same DAG.
+	DuplicateTaskID Rule = "duplicate_task_id"
+	// DependencyCycle reports tasks whose >> and << dependencies form a
+	// cycle.
+	DependencyCycle Rule = "dependency_cycle"
+	// DagIDMismatch reports a file none of whose DAGs has the expected
+	// dag_id.
+	DagIDMismatch Rule = "dag_id_mismatch"
+	// InvalidSchedule reports a schedule string that is neither a preset
+	// nor a valid cron expression.
+	InvalidSchedule Rule = "invalid_schedule"
+	// Deprecated reports modules and arguments deprecated in the targeted
+	// Airflow version.
+	Deprecated Rule = "deprecated"
+)
+
+// Rules lists every rule.
+var Rules = []Rule{DuplicateTaskID, DependencyCycle, DagIDMismatch, InvalidSchedule, Deprecated}
+
+// Finding is an issue found by a rule. Line and Col are 1-based, and Col
+// counts characters rather than bytes.
+type Finding struct {
+	Rule Rule
+	Line int
+	Col  int
+	Msg  string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("line %d, column %d: %s", f.Line, f.Col, f.Msg)
+}
+
+// Options configures Lint.
+type Options struct {
+	// DagID is the dag_id the file is expected to define. Empty skips the
+	// dag_id_mismatch rule.
+	DagID string
+	// AirflowVersion is the Airflow version the file targets, which decides
+	// what the deprecated rule reports. The zero Version reports every known
+	// deprecation.
+	AirflowVersion Version
+}
+
+// Lint returns the findings of every rule in src, ordered by position, or the
+// syntax error, as a *pysyntax.Error, if src is not valid Python.
+func Lint(src string, opts Options) ([]Finding, error) {
+	toks, err := pysyntax.Tokens(src)
+	if err != nil {
+		return nil, err
+	}
+	l := &linter{
+		opts:     opts,
+		frames:   []frame{{depth: -1}},
+		arms:     map[int]arm{},
+		dagVars:  map[string]string{},
+		tasks:    map[string][]task{},
+		versions: map[string]int{},
+		edges:    map[string][]edge{},
+		names:    map[string]string{},
+	}
+	for _, s := range statements(toks) {
+		l.statement(s)
+	}
+	l.checkCycles()
+	l.checkDagIDs()
+	sort.SliceStable(l.findings, func(i, j int) bool {
+		a, b := l.findings[i], l.findings[j]
+		return a.Line < b.Line || a.Line == b.Line && a.Col < b.Col
+	})
+	return l.findings, nil
+}
+
+// stmt is a logical line, or one of several simple statements separated by
+// semicolons, at an indentation depth.
+type stmt struct {
+	toks  []pysyntax.Token
+	depth int
+}
+
+func statements(toks []pysyntax.Token) []stmt {
+	var out []stmt
+	var cur []pysyntax.Token
+	depth, brackets := 0, 0
+	flush := func() {
+		if len(cur) > 0 {
+			out = append(out, stmt{toks: cur, depth: depth})
+			cur = nil
+		}
+	}
+	for _, t := range toks {
+		switch t.Kind {
+		case pysyntax.Indent:
+			depth++
+			continue
+		case pysyntax.Dedent:
+			depth--
+			continue
+		case pysyntax.Newline:
+			flush()
+			continue
+		case pysyntax.Op:
+			switch t.Text {
+			case "(", "[", "{":
+				brackets++
+			case ")", "]", "}":
+				brackets--
+			case ";":
+				if brackets == 0 {
+					flush()
+					continue
+				}
+			}
+		}
+		cur = append(cur, t)
+	}
+	flush()
+	return out
+}
+
+// frame is what the linter knows about the block being walked.
+type frame struct {
+	// depth is the depth of the statement that opened the block.
+	depth int
+	// dag is the key of the DAG tasks created in the block belong to, unless
+	// they name one with dag=.
+	dag string
+	// prefix is the prefix the enclosing task groups give task IDs.
+	prefix string
+	// scope is the key of the function or class the block is in, empty at
+	// module level. Variables are tracked per scope.
+	scope string
+	// branches are the if/elif/else and case arms the block is in.
+	branches []branch
+}
+
+// branch is an arm of an if/elif/else chain or a match statement. Code in
+// different arms of the same chain never runs together.
+type branch struct {
+	chain int
+	arm   int
+}
+
+// arm is the last arm opened at a depth, which a following elif, else or
+// case continues.
+type arm struct {
+	branch
+	match bool
+}
+
+type task struct {
+	line     int
+	branches []branch
+}
+
+type edge struct {
+	to  string
+	tok pysyntax.Token
+}
+
+// dagID is the literal dag_id of a DAG the file defines.
+type dagID struct {
+	id  string
+	tok pysyntax.Token
+}
+
+type linter struct {
+	opts     Options
+	findings []Finding
+	nextKey  int
+
+	frames []frame
+	arms   map[int]arm
+	chains int
+	// decorators are the decorators of the next def or class.
+	decorators [][]pysyntax.Token
+
+	// dagVars maps variables holding a DAG to its key.
+	dagVars map[string]string
+	// tasks are the tasks created, keyed by DAG key and full task ID.
+	tasks map[string][]task
+	dags  []dagID
+	// dynamicDagID is set when a dag_id is not a literal, so the dag_id
+	// the file defines are not all known.
+	dynamicDagID bool
+
+	// versions counts the assignments to each variable, so dependencies on
+	// a reassigned variable are not mixed with those of its earlier value.
+	versions map[string]int
+	// nodes, edges and names are the dependency graph between task
+	// variables, in the order they appear, and their names as written.
+	nodes []string
+	edges map[string][]edge
+	names map[string]string
+}
+
+func (l *linter) report(rule Rule, tok pysyntax.Token, format string, args ...any) {
+	l.findings = append(l.findings, Finding{Rule: rule, Line: tok.Line, Col: tok.Col, Msg: fmt.Sprintf(format, args...)})
+}
+
+func (l *linter) newKey(kind string) string {
+	l.nextKey++
+	return fmt.Sprintf("%s#%d", kind, l.nextKey)
+}
+
+func (l *linter) top() frame {
+	return l.frames[len(l.frames)-1]
+}
+
+// compound are the keywords that start a compound statement.
+var compound = map[string]bool{
+	"if": true, "elif": true, "else": true, "for": true, "while": true,
+	"with": true, "def": true, "class": true, "try": true, "except": true,
+	"finally": true, "async": true, "match": true, "case": true,
+}
+
+func (l *linter) statement(s stmt) {
+	for len(l.frames) > 1 && l.top().depth >= s.depth {
+		l.frames = l.frames[:len(l.frames)-1]
+	}
+	for d := range l.arms {
+		if d > s.depth {
+			delete(l.arms, d)
+		}
+	}
+
+	toks := s.toks
+	if isOp(toks[0], "@") {
+		l.decorators = append(l.decorators, toks[1:])
+		return
+	}
+	colon := -1
+	if toks[0].Kind == pysyntax.Name && compound[toks[0].Text] {
+		colon = topLevel(toks, ":")
+	}
+	if colon < 0 {
+		delete(l.arms, s.depth)
+		l.decorators = nil
+		l.simple(toks)
+		return
+	}
+
+	f := l.top()
+	f.depth = s.depth
+	f.branches = append([]branch(nil), f.branches...)
+	header := toks[:colon]
+	kw := header[0].Text
+	if kw == "async" && len(header) > 1 {
+		header = header[1:]
+		kw = header[0].Text
+	}
+	prev, continues := l.arms[s.depth]
+	delete(l.arms, s.depth)
+	switch kw {
+	case "if":
+		l.chains++
+		a := arm{branch: branch{chain: l.chains}}
+		l.arms[s.depth] = a
+		f.branches = append(f.branches, a.branch)
+	case "elif", "else":
+		if continues && !prev.match {
+			prev.arm++
+			l.arms[s.depth] = prev
+			f.branches = append(f.branches, prev.branch)
+		}
+	case "case":
+		a := prev
+		if continues && prev.match {
+			a.arm++
+		} else {
+			l.chains++
+			a = arm{branch: branch{chain: l.chains}, match: true}
+		}
+		l.arms[s.depth] = a
+		f.branches = append(f.branches, a.branch)
+	case "for":
+		if in := topLevel(header, "in"); in > 1 {
+			for _, t := range header[1:in] {
+				if t.Kind == pysyntax.Name {
+					l.assign(f.scope, t.Text)
+				}
+			}
+		}
+	case "with":
+		l.with(header[1:], &f)
+	case "def", "class":
+		l.definition(header, &f)
+	}
+	l.decorators = nil
+	l.frames = append(l.frames, f)
+
+	if body := toks[colon+1:]; len(body) > 0 {
+		l.simple(body)
+	}
+}
+
+// with handles the items of a with statement, whose DAG and TaskGroup
+// contexts apply to the block.
+func (l *linter) with(items []pysyntax.Token, f *frame) {
+	for _, c := range calls(items) {
+		switch c.name {
+		case "DAG":
+			key := l.newKey("dag")
+			f.dag, f.prefix = key, ""
+			if c.end+2 < len(items) && items[c.end+1].Text == "as" && items[c.end+2].Kind == pysyntax.Name {
+				l.dagVars[items[c.end+2].Text] = key
+			}
+			l.dagCall(c, nil)
+		case "TaskGroup":
+			f.prefix += l.groupPrefix(c, "")
+		}
+	}
+}
+
+// definition handles a def or class statement and its decorators.
+func (l *linter) definition(header []pysyntax.Token, f *frame) {
+	f.scope = l.newKey("scope")
+	f.dag = l.newKey("function")
+	if len(header) < 2 || header[0].Text != "def" {
+		return
+	}
+	name := header[1]
+	for _, d := range l.decorators {
+		var c call
+		if cs := calls(d); len(cs) > 0 && cs[0].start == 0 {
+			c = cs[0]
+		} else if d[len(d)-1].Kind == pysyntax.Name {
+			c = call{name: d[len(d)-1].Text}
+		}
+		switch c.name {
+		case "dag":
+			f.dag, f.prefix = l.newKey("dag"), ""
+			l.dagCall(c, &name)
+		case "task_group":
+			f.prefix += l.groupPrefix(c, name.Text)
+		}
+	}
+}
+
+// groupPrefix returns the prefix a task group gives the IDs of its tasks.
+// def is the name of the function a @task_group decorates, if any.
+func (l *linter) groupPrefix(c call, def string) string {
+	if a, ok := c.arg("prefix_group_id", -1); ok && len(a) == 1 && a[0].Text == "False" {
+		return ""
+	}
+	a, ok := c.arg("group_id", 0)
+	if !ok && def != "" {
+		return def + "."
+	}
+	if id, ok := literal(a); ok {
+		return id + "."
+	}
+	// A prefix no other group has, so its tasks are never taken for
+	// duplicates.
+	return l.newKey("group") + "."
+}
+
+// dagCall checks a call to DAG or the @dag decorator. def is the function a
+// @dag decorates, whose name is the default dag_id.
+func (l *linter) dagCall(c call, def *pysyntax.Token) {
+	if a, ok := c.arg("dag_id", 0); ok {
+		if id, ok := literal(a); ok {
+			l.dags = append(l.dags, dagID{id: id, tok: a[0]})
+		} else {
+			l.dynamicDagID = true
+		}
+	} else if def != nil {
+		l.dags = append(l.dags, dagID{id: def.Text, tok: *def})
+	}
+
+	for _, name := range []string{"schedule", "schedule_interval"} {
+		a, ok := c.arg(name, -1)
+		if !ok {
+			continue
+		}
+		if s, ok := literal(a); ok {
+			if msg := checkSchedule(s); msg != "" {
+				l.report(InvalidSchedule, a[0], "invalid schedule %q: %s", s, msg)
+			}
+		}
+	}
+	l.deprecatedArgs(c, deprecatedDAGArgs)
+}
+
+func (l *linter) deprecatedArgs(c call, deprecated map[string]deprecation) {
+	for _, a := range c.args {
+		if d, ok := deprecated[a.key]; ok {
+			if msg := d.message(l.opts.AirflowVersion); msg != "" {
+				l.report(Deprecated, a.keyTok, "%s", msg)
+			}
+		}
+	}
+}
+
+// simple handles a simple statement.
+func (l *linter) simple(toks []pysyntax.Token) {
+	f := l.top()
+	switch toks[0].Text {
+	case "import":
+		l.imports(toks)
+		return
+	case "from":
+		l.fromImport(toks)
+		return
+	}
+
+	for _, c := range calls(toks) {
+		switch {
+		case c.name == "DAG":
+			// A DAG assigned to a variable is the one its tasks name with
+			// dag=.
+			if len(toks) > 2 && toks[0].Kind == pysyntax.Name && isOp(toks[1], "=") && c.start == 2 {
+				l.dagVars[toks[0].Text] = l.newKey("dag")
+			}
+			l.dagCall(c, nil)
+		case c.name != "override":
+			if a, ok := c.arg("task_id", -1); ok {
+				l.task(c, a, f)
+			}
+		}
+	}
+
+	l.dependencies(toks, f.scope)
+
+	for i := 0; i+1 < len(toks) && toks[i].Kind == pysyntax.Name && isOp(toks[i+1], "="); i += 2 {
+		l.assign(f.scope, toks[i].Text)
+	}
+}
+
+func (l *linter) imports(toks []pysyntax.Token) {
+	for _, item := range split(toks[1:], ",") {
+		if module := dotted(item); module != "" {
+			if d, ok := moduleDeprecation(module); ok {
+				if msg := d.message(l.opts.AirflowVersion); msg != "" {
+					l.report(Deprecated, item[0], "%s", msg)
+				}
+			}
+		}
+	}
+}
+
+func (l *linter) fromImport(toks []pysyntax.Token) {
+	imp := topLevel(toks, "import")
+	if imp < 2 {
+		return
+	}
+	module := dotted(toks[1:imp])
+	if module == "" {
+		return
+	}
+	if d, ok := moduleDeprecation(module); ok {
+		if msg := d.message(l.opts.AirflowVersion); msg != "" {
+			l.report(Deprecated, toks[1], "%s", msg)
+		}
+		return
+	}
+	names := deprecatedImports[module]
+	for _, t := range toks[imp+1:] {
+		if d, ok := names[t.Text]; ok && t.Kind == pysyntax.Name {
+			if msg := d.message(l.opts.AirflowVersion); msg != "" {
+				l.report(Deprecated, t, "%s", msg)
+			}
+		}
+	}
+}
+
+// task records a task created by call c with the task_id argument a.
+func (l *linter) task(c call, a []pysyntax.Token, f frame) {
+	l.deprecatedArgs(c, deprecatedTaskArgs)
+	id, ok := literal(a)
+	if !ok {
+		return
+	}
+	dag := f.dag
+	if d, ok := c.arg("dag", -1); ok {
+		switch {
+		case len(d) == 1 && d[0].Kind == pysyntax.Name && l.dagVars[d[0].Text] != "":
+			dag = l.dagVars[d[0].Text]
+		case len(d) == 1 && d[0].Kind == pysyntax.Name:
+			dag = "var:" + d[0].Text
+		default:
+			dag = l.newKey("dag")
+		}
+	}
+	id = f.prefix + id
+	key := dag + "\x00" + id
+	t := task{line: a[0].Line, branches: f.branches}
+	for _, prev := range l.tasks[key] {
+		if !exclusive(prev.branches, t.branches) {
+			l.report(DuplicateTaskID, a[0], "task_id %q is already used on line %d", id, prev.line)
+			break
+		}
+	}
+	l.tasks[key] = append(l.tasks[key], t)
+}
+
+// exclusive reports whether code in branches a and b never runs together,
+// being in different arms of the same chain.
+func exclusive(a, b []branch) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x.chain == y.chain && x.arm != y.arm {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (l *linter) assign(scope, name string) {
+	l.versions[scope+"\x00"+name]++
+}
+
+// dependencies records the dependencies a statement such as
+// "extract >> [clean, check] >> load" sets between task variables.
+func (l *linter) dependencies(toks []pysyntax.Token, scope string) {
+	var segments [][]pysyntax.Token
+	var ops []pysyntax.Token
+	depth, start := 0, 0
+	for i, t := range toks {
+		if t.Kind != pysyntax.Op {
+			continue
+		}
+		switch t.Text {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+		case ">>", "<<":
+			if depth == 0 {
+				segments = append(segments, toks[start:i])
+				ops = append(ops, t)
+				start = i + 1
+			}
+		case "=", "+=", "-=", ":=", ">>=", "<<=":
+			if depth == 0 {
+				return
+			}
+		}
+	}
+	if len(ops) == 0 {
+		return
+	}
+	segments = append(segments, toks[start:])
+
+	nodes := make([][]string, len(segments))
+	for i, seg := range segments {
+		nodes[i] = l.operand(seg, scope)
+	}
+	for i, op := range ops {
+		from, to := nodes[i], nodes[i+1]
+		if op.Text == "<<" {
+			from, to = to, from
+		}
+		for _, u := range from {
+			for _, v := range to {
+				l.addEdge(u, v, op)
+			}
+		}
+	}
+}
+
+// operand returns the graph nodes of a task variable, or of a list or tuple
+// of them. Other expressions, such as calls, have none.
+func (l *linter) operand(seg []pysyntax.Token, scope string) []string {
+	if len(seg) > 1 && (isOp(seg[0], "[") || isOp(seg[0], "(")) && closing(seg, 0) == len(seg)-1 {
+		var out []string
+		for _, item := range split(seg[1:len(seg)-1], ",") {
+			if n := l.node(item, scope); n != "" {
+				out = append(out, n)
+			}
+		}
+		return out
+	}
+	if n := l.node(seg, scope); n != "" {
+		return []string{n}
+	}
+	return nil
+}
+
+// node returns the graph node of a reference to a task, such as t,
+// tasks.load or tasks["load"], or "" if seg is not one.
+func (l *linter) node(seg []pysyntax.Token, scope string) string {
+	if len(seg) == 0 || seg[0].Kind != pysyntax.Name || compound[seg[0].Text] || seg[0].Text == "None" {
+		return ""
+	}
+	var text strings.Builder
+	text.WriteString(seg[0].Text)
+	for i := 1; i < len(seg); i++ {
+		switch {
+		case isOp(seg[i], ".") && i+1 < len(seg) && seg[i+1].Kind == pysyntax.Name:
+			text.WriteString("." + seg[i+1].Text)
+			i++
+		case isOp(seg[i], "[") && i+2 < len(seg) && (seg[i+1].Kind == pysyntax.String || seg[i+1].Kind == pysyntax.Number) && isOp(seg[i+2], "]"):
+			text.WriteString("[" + seg[i+1].Text + "]")
+			i += 2
+		default:
+			return ""
+		}
+	}
+	root := scope + "\x00" + seg[0].Text
+	key := fmt.Sprintf("%s\x00%s\x00%d", scope, text.String(), l.versions[root])
+	if _, ok := l.names[key]; !ok {
+		l.names[key] = text.String()
+		l.nodes = append(l.nodes, key)
+	}
+	return key
+}
+
+func (l *linter) addEdge(u, v string, tok pysyntax.Token) {
+	for _, e := range l.edges[u] {
+		if e.to == v {
+			return
+		}
+	}
+	l.edges[u] = append(l.edges[u], edge{to: v, tok: tok})
+}
+
+// checkCycles reports each cycle in the dependency graph once, at the
+// operator that closes it.
+func (l *linter) checkCycles() {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := map[string]int{}
+	var path []string
+	var visit func(u string)
+	visit = func(u string) {
+		state[u] = visiting
+		path = append(path, u)
+		for _, e := range l.edges[u] {
+			switch state[e.to] {
+			case unvisited:
+				visit(e.to)
+			case visiting:
+				var names []string
+				for i := len(path) - 1; i >= 0; i-- {
+					names = append(names, l.names[path[i]])
+					if path[i] == e.to {
+						break
+					}
+				}
+				for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+					names[i], names[j] = names[j], names[i]
+				}
+				names = append(names, l.names[e.to])
+				l.report(DependencyCycle, e.tok, "dependency cycle: %s", strings.Join(names, " >> "))
+			}
+		}
+		path = path[:len(path)-1]
+		state[u] = done
+	}
+	for _, n := range l.nodes {
+		if state[n] == unvisited {
+			visit(n)
+		}
+	}
+}
+
+// checkDagIDs reports the DAGs of a file that defines none with the expected
+// dag_id.
+func (l *linter) checkDagIDs() {
+	if l.opts.DagID == "" || l.dynamicDagID {
+		return
+	}
+	for _, d := range l.dags {
+		if d.id == l.opts.DagID {
+			return
+		}
+	}
+	for _, d := range l.dags {
+		l.report(DagIDMismatch, d.tok, "dag_id %q does not match %q from the context", d.id, l.opts.DagID)
+	}
+}
+
+// call is a call in a statement: the last name of the callee, its arguments
+// and the positions of the callee and the closing parenthesis in the
+// statement.
+type call struct {
+	name       string
+	args       []argument
+	start, end int
+}
+
+type argument struct {
+	// key is the keyword of a keyword argument, empty for a positional one.
+	key    string
+	keyTok pysyntax.Token
+	value  []pysyntax.Token
+}
+
+// arg returns the value of the keyword argument key or, if pos is not
+// negative and there is none, of the positional argument at pos.
+func (c call) arg(key string, pos int) ([]pysyntax.Token, bool) {
+	n := 0
+	for _, a := range c.args {
+		if a.key == key {
+			return a.value, true
+		}
+	}
+	for _, a := range c.args {
+		if a.key != "" || len(a.value) == 0 || isOp(a.value[0], "*") || isOp(a.value[0], "**") {
+			continue
+		}
+		if n == pos {
+			return a.value, true
+		}
+		n++
+	}
+	return nil, false
+}
+
+// calls returns the calls in toks, outer calls before the calls in their
+// arguments.
+func calls(toks []pysyntax.Token) []call {
+	var out []call
+	for i := 1; i < len(toks); i++ {
+		if !isOp(toks[i], "(") || toks[i-1].Kind != pysyntax.Name || compound[toks[i-1].Text] {
+			continue
+		}
+		end := closing(toks, i)
+		if end < 0 {
+			continue
+		}
+		start := i - 1
+		for start >= 2 && isOp(toks[start-1], ".") && toks[start-2].Kind == pysyntax.Name {
+			start -= 2
+		}
+		c := call{name: toks[i-1].Text, start: start, end: end}
+		for _, a := range split(toks[i+1:end], ",") {
+			if len(a) >= 2 && a[0].Kind == pysyntax.Name && isOp(a[1], "=") {
+				c.args = append(c.args, argument{key: a[0].Text, keyTok: a[0], value: a[2:]})
+			} else if len(a) > 0 {
+				c.args = append(c.args, argument{value: a})
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// closing returns the index of the bracket closing the one at open, or -1.
+func closing(toks []pysyntax.Token, open int) int {
+	depth := 0
+	for i := open; i < len(toks); i++ {
+		if toks[i].Kind != pysyntax.Op {
+			continue
+		}
+		switch toks[i].Text {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// split splits toks at the top-level occurrences of sep.
+func split(toks []pysyntax.Token, sep string) [][]pysyntax.Token {
+	var out [][]pysyntax.Token
+	depth, start := 0, 0
+	for i, t := range toks {
+		if t.Kind != pysyntax.Op {
+			continue
+		}
+		switch t.Text {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+		case sep:
+			if depth == 0 {
+				out = append(out, toks[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(out, toks[start:])
+}
+
+// topLevel returns the index of the first token text outside brackets, or -1.
+func topLevel(toks []pysyntax.Token, text string) int {
+	depth := 0
+	for i, t := range toks {
+		if t.Kind == pysyntax.Op {
+			switch t.Text {
+			case "(", "[", "{":
+				depth++
+			case ")", "]", "}":
+				depth--
+			}
+		}
+		if depth == 0 && t.Text == text && t.Kind != pysyntax.String {
+			return i
+		}
+	}
+	return -1
+}
+
+// dotted returns the dotted name toks start with, such as
+// airflow.operators.bash, or "" if they start with something else.
+func dotted(toks []pysyntax.Token) string {
+	if len(toks) == 0 || toks[0].Kind != pysyntax.Name {
+		return ""
+	}
+	name := toks[0].Text
+	for i := 1; i+1 < len(toks) && isOp(toks[i], ".") && toks[i+1].Kind == pysyntax.Name; i += 2 {
+		name += "." + toks[i+1].Text
+	}
+	return name
+}
+
+// literal returns the value of an expression that is a string literal, or
+// several adjacent ones, that is not an f-string.
+func literal(toks []pysyntax.Token) (string, bool) {
+	if len(toks) == 0 {
+		return "", false
+	}
+	var s strings.Builder
+	for _, t := range toks {
+		if t.Kind != pysyntax.String {
+			return "", false
+		}
+		v, ok := pysyntax.StringValue(t.Text)
+		if !ok {
+			return "", false
+		}
+		s.WriteString(v)
+	}
+	return s.String(), true
+}
+
+func isOp(t pysyntax.Token, text string) bool {
+	return t.Kind == pysyntax.Op && t.Text == text
+}
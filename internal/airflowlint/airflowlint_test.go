@@ -0,0 +1,182 @@
+package airflowlint
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mm-aranda/terraform-provider-mirage/internal/pysyntax"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		opts Options
+		want []Finding
+	}{
+		{
+			name: "clean",
+			src: `from airflow import DAG
+from airflow.operators.empty import EmptyOperator
+
+with DAG(dag_id="orders", schedule="0 2 * * mon-fri") as dag:
+    extract = EmptyOperator(task_id="extract")
+    load = EmptyOperator(task_id="load")
+    extract >> load
+`,
+			opts: Options{DagID: "orders", AirflowVersion: Version{2, 9}},
+		},
+		{
+			name: string(DuplicateTaskID),
+			src: `from airflow import DAG
+from airflow.operators.empty import EmptyOperator
+
+with DAG(dag_id="orders") as dag:
+    a = EmptyOperator(task_id="extract")
+    b = EmptyOperator(task_id="extract")
+`,
+			opts: Options{AirflowVersion: Version{2, 9}},
+			want: []Finding{{Rule: DuplicateTaskID, Line: 6, Col: 31, Msg: `task_id "extract" is already used on line 5`}},
+		},
+		{
+			name: string(DependencyCycle),
+			src: `from airflow import DAG
+from airflow.operators.empty import EmptyOperator
+
+with DAG(dag_id="orders") as dag:
+    a = EmptyOperator(task_id="a")
+    b = EmptyOperator(task_id="b")
+    c = EmptyOperator(task_id="c")
+    a >> b >> c
+    c >> a
+`,
+			opts: Options{AirflowVersion: Version{2, 9}},
+			want: []Finding{{Rule: DependencyCycle, Line: 9, Col: 7, Msg: "dependency cycle: a >> b >> c >> a"}},
+		},
+		{
+			name: string(DagIDMismatch),
+			src: `from airflow import DAG
+
+with DAG(dag_id="payments") as dag:
+    pass
+`,
+			opts: Options{DagID: "orders", AirflowVersion: Version{2, 9}},
+			want: []Finding{{Rule: DagIDMismatch, Line: 3, Col: 17, Msg: `dag_id "payments" does not match "orders" from the context`}},
+		},
+		{
+			name: string(InvalidSchedule),
+			src: `from airflow import DAG
+
+with DAG(dag_id="orders", schedule="0 25 * * *") as dag:
+    pass
+`,
+			opts: Options{AirflowVersion: Version{2, 9}},
+			want: []Finding{{Rule: InvalidSchedule, Line: 3, Col: 36, Msg: `invalid schedule "0 25 * * *": hour 25 is out of range 0-23`}},
+		},
+		{
+			name: string(Deprecated),
+			src: `from airflow import DAG
+from airflow.operators.dummy import DummyOperator
+
+with DAG(dag_id="orders", schedule_interval="@daily") as dag:
+    a = DummyOperator(task_id="a")
+`,
+			opts: Options{AirflowVersion: Version{2, 4}},
+			want: []Finding{
+				{Rule: Deprecated, Line: 2, Col: 6, Msg: "airflow.operators.dummy is deprecated since Airflow 2.4; use airflow.operators.empty instead"},
+				{Rule: Deprecated, Line: 4, Col: 27, Msg: "schedule_interval is deprecated since Airflow 2.4; use schedule instead"},
+			},
+		},
+		{
+			name: "deprecated before the targeted version",
+			src: `from airflow.operators.dummy import DummyOperator
+`,
+			opts: Options{AirflowVersion: Version{2, 3}},
+		},
+		{
+			name: "removed in the targeted version",
+			src: `from airflow.operators.dummy import DummyOperator
+`,
+			opts: Options{AirflowVersion: Version{3, 0}},
+			want: []Finding{{Rule: Deprecated, Line: 1, Col: 6, Msg: "airflow.operators.dummy was deprecated in Airflow 2.4 and removed in 3.0; use airflow.operators.empty instead"}},
+		},
+		{
+			name: "computed task IDs are skipped",
+			src: `from airflow import DAG
+from airflow.operators.empty import EmptyOperator
+
+with DAG(dag_id="orders") as dag:
+    for table in ["a", "b"]:
+        EmptyOperator(task_id=f"load_{table}")
+    for table in ["c", "d"]:
+        EmptyOperator(task_id=f"load_{table}")
+`,
+			opts: Options{AirflowVersion: Version{2, 9}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Lint(tt.src, tt.opts)
+			if err != nil {
+				t.Fatalf("Lint: %v", err)
+			}
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lint =\n%v\nwant\n%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLintSyntaxError(t *testing.T) {
+	_, err := Lint("x = 'abc\n", Options{})
+	var syntaxErr *pysyntax.Error
+	if !errors.As(err, &syntaxErr) || syntaxErr.Line != 1 || syntaxErr.Col != 5 {
+		t.Errorf("Lint = %v, want a syntax error at line 1, column 5", err)
+	}
+}
+
+func TestCheckSchedule(t *testing.T) {
+	tests := []struct {
+		schedule string
+		want     string
+	}{
+		{schedule: "@daily"},
+		{schedule: "@Weekly"},
+		{schedule: "*/15 0-6,22 1,L jan-jun mon#2"},
+		{schedule: "0 0 * * 7"},
+		{schedule: "@fortnightly", want: "@fortnightly is not a schedule preset"},
+		{schedule: "0 0 * *", want: "a cron expression has 5 fields, not 4"},
+		{schedule: "60 * * * *", want: "minute 60 is out of range 0-59"},
+		{schedule: "0 0 * foo *", want: `invalid month value "foo"`},
+	}
+	for _, tt := range tests {
+		if got := checkSchedule(tt.schedule); got != tt.want {
+			t.Errorf("checkSchedule(%q) = %q, want %q", tt.schedule, got, tt.want)
+		}
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    Version
+		wantErr bool
+	}{
+		{s: "2.4", want: Version{2, 4}},
+		{s: "2.10.5", want: Version{2, 10}},
+		{s: "3", want: Version{3, 0}},
+		{s: "0.1", wantErr: true},
+		{s: "2.x", wantErr: true},
+		{s: "2.4.1.1", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseVersion(tt.s)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseVersion(%q) = %v, %v, want %v, error %v", tt.s, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package airflowlint
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is an Airflow major and minor version. The zero Version stands for
+// the latest release.
+type Version struct {
+	Major int
+	Minor int
+}
+
+// ParseVersion parses an Airflow version such as "2.4" or "2.10.5". The patch
+// release, if any, is ignored.
+func ParseVersion(s string) (Version, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return Version{}, fmt.Errorf("invalid Airflow version %q", s)
+	}
+	var nums [2]int
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return Version{}, fmt.Errorf("invalid Airflow version %q", s)
+		}
+		if i < 2 {
+			nums[i] = n
+		}
+	}
+	if nums[0] == 0 {
+		return Version{}, fmt.Errorf("invalid Airflow version %q", s)
+	}
+	return Version{Major: nums[0], Minor: nums[1]}, nil
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// atLeast reports whether v is o or later. The latest release is later than
+// any version.
+func (v Version) atLeast(o Version) bool {
+	if v == (Version{}) {
+		return true
+	}
+	return v.Major > o.Major || v.Major == o.Major && v.Minor >= o.Minor
+}
+
+// deprecation is something a DAG file uses that Airflow deprecated in a
+// release, and removed in a later one if removed is set.
+type deprecation struct {
+	old         string
+	replacement string
+	since       Version
+	removed     Version
+}
+
+// message describes the deprecation for a DAG file targeting version v, or
+// returns "" if it does not apply to v yet.
+func (d deprecation) message(v Version) string {
+	switch {
+	case !v.atLeast(d.since):
+		return ""
+	case d.removed != (Version{}) && v.atLeast(d.removed):
+		return fmt.Sprintf("%s was deprecated in Airflow %s and removed in %s; %s", d.old, d.since, d.removed, d.replacement)
+	default:
+		return fmt.Sprintf("%s is deprecated since Airflow %s; %s", d.old, d.since, d.replacement)
+	}
+}
+
+var (
+	v2_0 = Version{2, 0}
+	v2_2 = Version{2, 2}
+	v2_4 = Version{2, 4}
+	v3_0 = Version{3, 0}
+)
+
+// deprecatedModules are deprecated modules, which also cover their
+// submodules.
+var deprecatedModules = []deprecation{
+	{old: "airflow.contrib", replacement: "use the modules of the provider packages instead", since: v2_0, removed: v3_0},
+	{old: "airflow.operators.bash_operator", replacement: "use airflow.operators.bash instead", since: v2_0, removed: v3_0},
+	{old: "airflow.operators.python_operator", replacement: "use airflow.operators.python instead", since: v2_0, removed: v3_0},
+	{old: "airflow.operators.dummy_operator", replacement: "use airflow.operators.empty instead", since: v2_0, removed: v3_0},
+	{old: "airflow.operators.email_operator", replacement: "use airflow.operators.email instead", since: v2_0, removed: v3_0},
+	{old: "airflow.operators.subdag_operator", replacement: "use task groups instead", since: v2_0, removed: v3_0},
+	{old: "airflow.sensors.base_sensor_operator", replacement: "use airflow.sensors.base instead", since: v2_0, removed: v3_0},
+	{old: "airflow.hooks.base_hook", replacement: "use airflow.hooks.base instead", since: v2_0, removed: v3_0},
+	{old: "airflow.operators.dummy", replacement: "use airflow.operators.empty instead", since: v2_4, removed: v3_0},
+	{old: "airflow.operators.bash", replacement: "use airflow.providers.standard.operators.bash instead", since: v3_0},
+	{old: "airflow.operators.python", replacement: "use airflow.providers.standard.operators.python instead", since: v3_0},
+	{old: "airflow.operators.empty", replacement: "use airflow.providers.standard.operators.empty instead", since: v3_0},
+	{old: "airflow.decorators", replacement: "use airflow.sdk instead", since: v3_0},
+}
+
+// deprecatedImports are deprecated names imported from modules that are
+// otherwise current, keyed by module.
+var deprecatedImports = map[string]map[string]deprecation{
+	"airflow": {
+		"DAG": {old: "importing DAG from airflow", replacement: "import it from airflow.sdk instead", since: v3_0},
+	},
+	"airflow.models": {
+		"DAG": {old: "importing DAG from airflow.models", replacement: "import it from airflow.sdk instead", since: v3_0},
+	},
+}
+
+// deprecatedDAGArgs are deprecated arguments of DAG and @dag.
+var deprecatedDAGArgs = map[string]deprecation{
+	"schedule_interval": {old: "schedule_interval", replacement: "use schedule instead", since: v2_4, removed: v3_0},
+	"timetable":         {old: "timetable", replacement: "use schedule instead", since: v2_4, removed: v3_0},
+	"concurrency":       {old: "concurrency", replacement: "use max_active_tasks instead", since: v2_2, removed: v3_0},
+}
+
+// deprecatedTaskArgs are deprecated arguments of operators.
+var deprecatedTaskArgs = map[string]deprecation{
+	"provide_context":  {old: "provide_context", replacement: "the context is passed to the callable without it", since: v2_0, removed: v3_0},
+	"task_concurrency": {old: "task_concurrency", replacement: "use max_active_tis_per_dag instead", since: v2_2, removed: v3_0},
+}
+
+// moduleDeprecation returns the deprecation of module, if any.
+func moduleDeprecation(module string) (deprecation, bool) {
+	for _, d := range deprecatedModules {
+		if module == d.old || strings.HasPrefix(module, d.old+".") {
+			return d, true
+		}
+	}
+	return deprecation{}, false
+}
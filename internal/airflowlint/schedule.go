@@ -0,0 +1,111 @@
+package airflowlint
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// schedulePresets are the schedule strings Airflow and croniter accept in
+// place of a cron expression.
+var schedulePresets = map[string]bool{
+	"@once": true, "@continuous": true, "@hourly": true, "@daily": true,
+	"@midnight": true, "@weekly": true, "@monthly": true, "@quarterly": true,
+	"@yearly": true, "@annually": true,
+}
+
+// cronField describes a field of a cron expression.
+type cronField struct {
+	name     string
+	min, max int
+	// names are the names values may also be given by, such as jan or mon.
+	names []string
+	// last allows L, the last day of the month.
+	last bool
+	// nth allows day#n, the nth given weekday of the month.
+	nth bool
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31, last: true},
+	{name: "month", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	// 7 is Sunday as well as 0.
+	{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}, nth: true},
+}
+
+// checkSchedule returns why the schedule string s is invalid, or "" if it is
+// a preset or a valid five-field cron expression.
+func checkSchedule(s string) string {
+	if strings.HasPrefix(s, "@") {
+		if schedulePresets[strings.ToLower(s)] {
+			return ""
+		}
+		return fmt.Sprintf("%s is not a schedule preset", s)
+	}
+	fields := strings.Fields(s)
+	if len(fields) != len(cronFields) {
+		return fmt.Sprintf("a cron expression has 5 fields, not %d", len(fields))
+	}
+	for i, f := range fields {
+		if msg := cronFields[i].check(f); msg != "" {
+			return msg
+		}
+	}
+	return ""
+}
+
+// check returns why s is not a valid value of the field, or "".
+func (f cronField) check(s string) string {
+	for _, item := range strings.Split(s, ",") {
+		base, step, hasStep := strings.Cut(item, "/")
+		if hasStep {
+			if n, err := strconv.Atoi(step); err != nil || n <= 0 {
+				return fmt.Sprintf("invalid step %q in the %s field", step, f.name)
+			}
+		}
+		switch {
+		case base == "*" || base == "?" && (f.last || f.nth):
+			continue
+		case f.last && strings.EqualFold(base, "L") && !hasStep:
+			continue
+		case f.nth && strings.Contains(base, "#") && !hasStep:
+			day, n, _ := strings.Cut(base, "#")
+			if msg := f.value(day); msg != "" {
+				return msg
+			}
+			if k, err := strconv.Atoi(n); err != nil || k < 1 || k > 5 {
+				return fmt.Sprintf("invalid occurrence %q in the %s field", n, f.name)
+			}
+			continue
+		}
+		low, high, isRange := strings.Cut(base, "-")
+		if msg := f.value(low); msg != "" {
+			return msg
+		}
+		if isRange {
+			if msg := f.value(high); msg != "" {
+				return msg
+			}
+		}
+	}
+	return ""
+}
+
+// value returns why s is not a single value of the field, or "".
+func (f cronField) value(s string) string {
+	for _, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return ""
+		}
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Sprintf("invalid %s value %q", f.name, s)
+	}
+	if n < f.min || n > f.max {
+		return fmt.Sprintf("%s %d is out of range %d-%d", f.name, n, f.min, f.max)
+	}
+	return ""
+}
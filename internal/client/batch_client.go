@@ -0,0 +1,130 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// DefaultBatchSize is the number of items sent per /generate-batch request
+// when no size is given.
+const DefaultBatchSize = 50
+
+// BatchGenerateItem is one entry of a batch, identified by a caller-chosen ID.
+type BatchGenerateItem struct {
+	ID string `json:"id"`
+	GenerateRequest
+}
+
+// BatchGenerateResult is the outcome of one batch item. Err is set when the
+// item, or the whole request carrying it, failed.
+type BatchGenerateResult struct {
+	ID         string
+	Checksum   string
+	Generation string
+	Err        error
+}
+
+type batchGenerateRequest struct {
+	Items []BatchGenerateItem `json:"items"`
+}
+
+// batchGenerateResponse matches the JSON from the backend's /generate-batch endpoint.
+type batchGenerateResponse struct {
+	Results []struct {
+		ID         string `json:"id"`
+		Checksum   string `json:"checksum"`
+		Generation string `json:"generation"`
+		Error      string `json:"error"`
+	} `json:"results"`
+}
+
+// GenerateBatch generates items in chunks of batchSize through /generate-batch
+// and returns one result per item, in the order given. A failed chunk marks
+// each of its items as failed and the remaining chunks are still sent.
+func (s *DagGeneratorService) GenerateBatch(ctx context.Context, items []BatchGenerateItem, batchSize int) []BatchGenerateResult {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	results := make([]BatchGenerateResult, 0, len(items))
+	for start := 0; start < len(items); start += batchSize {
+		end := min(start+batchSize, len(items))
+		results = append(results, s.generateChunk(ctx, items[start:end])...)
+	}
+	return results
+}
+
+func (s *DagGeneratorService) generateChunk(ctx context.Context, items []BatchGenerateItem) []BatchGenerateResult {
+	results := make([]BatchGenerateResult, len(items))
+	for i, item := range items {
+		results[i].ID = item.ID
+	}
+	fail := func(err error) []BatchGenerateResult {
+		for i := range results {
+			results[i].Err = err
+		}
+		return results
+	}
+
+	url := fmt.Sprintf("%s/generate-batch", s.Client.BaseURL)
+
+	body, err := json.Marshal(batchGenerateRequest{Items: items})
+	if err != nil {
+		return fail(err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return fail(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.do(ctx, req)
+	if err != nil {
+		return fail(err)
+	}
+
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fail(newAPIError(resp))
+	}
+
+	var batchResp batchGenerateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&batchResp); err != nil {
+		return fail(err)
+	}
+
+	byID := make(map[string]int, len(items))
+	for i, item := range items {
+		byID[item.ID] = i
+	}
+	seen := make(map[string]bool, len(items))
+	for _, r := range batchResp.Results {
+		i, ok := byID[r.ID]
+		if !ok {
+			continue
+		}
+		seen[r.ID] = true
+		if r.Error != "" {
+			results[i].Err = errors.New(r.Error)
+			continue
+		}
+		results[i].Checksum = r.Checksum
+		results[i].Generation = r.Generation
+	}
+	for i := range results {
+		if !seen[results[i].ID] {
+			results[i].Err = errors.New("backend returned no result for this item")
+		}
+	}
+
+	return results
+}
@@ -0,0 +1,132 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// ErrCircuitOpen is wrapped by errors returned while the circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// circuitBreaker stops sending requests to a backend after a run of
+// consecutive failures. Once the cooldown has passed, a single trial request
+// is let through: success closes the breaker, failure opens it again. A nil
+// breaker never trips.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures int
+	open     bool
+	trial    bool
+	openedAt time.Time
+	lastErr  error
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow returns an error wrapping ErrCircuitOpen if the request must not be sent.
+func (b *circuitBreaker) allow(ctx context.Context, backend string) error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.open {
+		return nil
+	}
+
+	retryAt := b.openedAt.Add(b.cooldown)
+	if time.Now().Before(retryAt) || b.trial {
+		return fmt.Errorf("%w: backend %s failed %d consecutive times, not retrying until %s; last error: %v",
+			ErrCircuitOpen, backend, b.failures, retryAt.Format(time.RFC3339), b.lastErr)
+	}
+
+	tflog.Debug(ctx, "Circuit breaker cooldown elapsed, sending trial request", map[string]interface{}{
+		"backend": backend,
+	})
+	b.trial = true
+	return nil
+}
+
+// record updates the breaker with the outcome of a request. A request that
+// failed because its context was cancelled says nothing about the backend, so
+// it only frees the trial slot it may have held.
+func (b *circuitBreaker) record(ctx context.Context, backend string, resp *http.Response, err error) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	wasTrial := b.trial
+	b.trial = false
+	if err != nil && ctx.Err() != nil {
+		return
+	}
+
+	failed := err != nil
+	if err == nil && resp != nil && resp.StatusCode >= http.StatusInternalServerError {
+		failed = true
+		err = fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	if !failed {
+		if b.open {
+			tflog.Info(ctx, "Circuit breaker closed", map[string]interface{}{"backend": backend})
+		}
+		b.failures = 0
+		b.open = false
+		b.lastErr = nil
+		return
+	}
+
+	b.failures++
+	b.lastErr = err
+	if wasTrial || b.failures >= b.threshold {
+		if !b.open || wasTrial {
+			tflog.Warn(ctx, "Circuit breaker opened", map[string]interface{}{
+				"backend":  backend,
+				"failures": b.failures,
+				"cooldown": b.cooldown.String(),
+			})
+		}
+		b.open = true
+		b.openedAt = time.Now()
+	}
+}
+
+// trip opens the breaker immediately, e.g. after a failed health check.
+func (b *circuitBreaker) trip(ctx context.Context, backend string, err error) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	tflog.Warn(ctx, "Circuit breaker opened by failed health check", map[string]interface{}{
+		"backend": backend,
+		"error":   err.Error(),
+	})
+	b.failures = max(b.failures, 1)
+	b.lastErr = err
+	b.open = true
+	b.trial = false
+	b.openedAt = time.Now()
+}
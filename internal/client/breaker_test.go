@@ -0,0 +1,188 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyBackend counts requests per path and answers them with status, which
+// tests change as they go. /slow blocks until the request is cancelled.
+type flakyBackend struct {
+	*httptest.Server
+
+	status atomic.Int32
+	mu     sync.Mutex
+	hits   map[string]int
+}
+
+func newFlakyBackend(t *testing.T, status int) *flakyBackend {
+	t.Helper()
+	b := &flakyBackend{hits: map[string]int{}}
+	b.status.Store(int32(status))
+	b.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b.mu.Lock()
+		b.hits[req.URL.Path]++
+		b.mu.Unlock()
+		if req.URL.Path == "/slow" {
+			<-req.Context().Done()
+			return
+		}
+		w.WriteHeader(int(b.status.Load()))
+		_, _ = w.Write([]byte("{}"))
+	}))
+	t.Cleanup(b.Close)
+	return b
+}
+
+func (b *flakyBackend) count(path string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.hits[path]
+}
+
+func getStatus(svc *DagGeneratorService) error {
+	_, err := svc.GetStatus(context.Background(), "gs://bucket/dag.py")
+	return err
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	const cooldown = 50 * time.Millisecond
+	backend := newFlakyBackend(t, http.StatusInternalServerError)
+	svc := newTestService(t, backend.URL, ClientOptions{CircuitBreakerThreshold: 3, CircuitBreakerCooldown: cooldown}, nil)
+
+	for i := 0; i < 3; i++ {
+		if err := getStatus(svc); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("request %d: err = %v, want the backend's error", i+1, err)
+		}
+	}
+
+	err := getStatus(svc)
+	if !errors.Is(err, ErrCircuitOpen) || !strings.Contains(err.Error(), "failed 3 consecutive times") {
+		t.Fatalf("err = %v, want %v after 3 failures", err, ErrCircuitOpen)
+	}
+	if got := backend.count("/status"); got != 3 {
+		t.Errorf("the backend received %d requests, want 3: an open breaker must fail fast", got)
+	}
+
+	// A failed trial reopens the breaker for another cooldown.
+	time.Sleep(cooldown)
+	if err := getStatus(svc); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("trial request: err = %v, want the backend's error", err)
+	}
+	if err := getStatus(svc); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("err = %v, want %v after a failed trial", err, ErrCircuitOpen)
+	}
+
+	// A successful trial closes it.
+	backend.status.Store(http.StatusOK)
+	time.Sleep(cooldown)
+	for i := 0; i < 2; i++ {
+		if err := getStatus(svc); err != nil {
+			t.Fatalf("request %d after recovery: %v", i+1, err)
+		}
+	}
+	if got := backend.count("/status"); got != 6 {
+		t.Errorf("the backend received %d requests, want 6", got)
+	}
+}
+
+func TestCircuitBreakerSingleTrial(t *testing.T) {
+	ctx := context.Background()
+	b := newCircuitBreaker(1, time.Millisecond)
+	b.record(ctx, "backend", nil, errors.New("connection refused"))
+	time.Sleep(2 * time.Millisecond)
+
+	if err := b.allow(ctx, "backend"); err != nil {
+		t.Fatalf("first request after the cooldown: %v", err)
+	}
+	if err := b.allow(ctx, "backend"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("err = %v, want %v while the trial is in flight", err, ErrCircuitOpen)
+	}
+}
+
+func TestCircuitBreakerIgnoresCancelledRequests(t *testing.T) {
+	backend := newFlakyBackend(t, http.StatusOK)
+	svc := newTestService(t, backend.URL, ClientOptions{CircuitBreakerThreshold: 1}, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, backend.URL+"/slow", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.do(ctx, req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if err := getStatus(svc); err != nil {
+		t.Errorf("err = %v, want a cancelled request not to open the breaker", err)
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	t.Run("probes once per client", func(t *testing.T) {
+		backend := newFlakyBackend(t, http.StatusOK)
+		svc := newTestService(t, backend.URL, ClientOptions{HealthCheck: true}, nil)
+
+		for i := 0; i < 3; i++ {
+			if err := getStatus(svc); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := backend.count("/healthz"); got != 1 {
+			t.Errorf("/healthz was probed %d times, want 1", got)
+		}
+	})
+
+	t.Run("failed probe opens the breaker", func(t *testing.T) {
+		backend := newFlakyBackend(t, http.StatusServiceUnavailable)
+		svc := newTestService(t, backend.URL, ClientOptions{HealthCheck: true, CircuitBreakerThreshold: 5, CircuitBreakerCooldown: time.Hour}, nil)
+
+		for i := 0; i < 2; i++ {
+			if err := getStatus(svc); !errors.Is(err, ErrCircuitOpen) || !strings.Contains(err.Error(), "health check failed") {
+				t.Fatalf("err = %v, want %v naming the failed health check", err, ErrCircuitOpen)
+			}
+		}
+		if backend.count("/healthz") != 1 || backend.count("/status") != 0 {
+			t.Errorf("the backend received %d probes and %d requests, want 1 and 0", backend.count("/healthz"), backend.count("/status"))
+		}
+	})
+
+	t.Run("failed probe without a breaker", func(t *testing.T) {
+		backend := newFlakyBackend(t, http.StatusServiceUnavailable)
+		svc := newTestService(t, backend.URL, ClientOptions{HealthCheck: true}, nil)
+
+		for i := 0; i < 2; i++ {
+			if err := getStatus(svc); err == nil || !strings.Contains(err.Error(), "is unavailable") {
+				t.Fatalf("err = %v, want the backend reported unavailable", err)
+			}
+		}
+		if got := backend.count("/healthz"); got != 1 {
+			t.Errorf("/healthz was probed %d times, want 1", got)
+		}
+	})
+
+	t.Run("cancelled probe is retried", func(t *testing.T) {
+		backend := newFlakyBackend(t, http.StatusOK)
+		svc := newTestService(t, backend.URL, ClientOptions{HealthCheck: true}, nil)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := svc.GetStatus(ctx, "gs://bucket/dag.py"); err == nil {
+			t.Fatal("a request with a cancelled context succeeded")
+		}
+		if err := getStatus(svc); err != nil {
+			t.Fatal(err)
+		}
+		if got := backend.count("/healthz"); got != 1 {
+			t.Errorf("/healthz was probed %d times, want 1 after the cancelled probe", got)
+		}
+	})
+}
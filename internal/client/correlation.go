@@ -0,0 +1,87 @@
+package client
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	requestIDHeader   = "X-Request-ID"
+	traceparentHeader = "traceparent"
+)
+
+// APIError is returned when the backend answers with a non-success status.
+type APIError struct {
+	StatusCode int
+	Body       string
+	// RequestID is the correlation ID of the failed request, as returned by the
+	// backend or, failing that, as sent by the provider.
+	RequestID string
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("backend returned status %d: %s", e.StatusCode, e.Body)
+	if e.RequestID != "" {
+		msg += fmt.Sprintf(" (request ID: %s)", e.RequestID)
+	}
+	return msg
+}
+
+// Is reports a 404 response as ErrNotFound, so callers can use errors.Is
+// whichever endpoint the error came from.
+func (e *APIError) Is(target error) bool {
+	return target == ErrNotFound && e.StatusCode == http.StatusNotFound
+}
+
+// newAPIError builds an APIError from a non-success response, consuming its body.
+func newAPIError(resp *http.Response) *APIError {
+	respBody, _ := io.ReadAll(resp.Body)
+	return &APIError{
+		StatusCode: resp.StatusCode,
+		Body:       strings.TrimSpace(string(respBody)),
+		RequestID:  responseRequestID(resp),
+	}
+}
+
+// newRequestID returns a random UUID (version 4) used as X-Request-ID.
+func newRequestID() string {
+	b := randomBytes(16)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	h := hex.EncodeToString(b)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
+
+// newTraceparent returns a W3C traceparent header value whose trace ID is
+// derived from requestID, so the two headers can be matched in backend logs.
+func newTraceparent(requestID string) string {
+	traceID := strings.ReplaceAll(requestID, "-", "")
+	spanID := hex.EncodeToString(randomBytes(8))
+	return fmt.Sprintf("00-%s-%s-01", traceID, spanID)
+}
+
+// responseRequestID returns the request ID reported by the backend, falling
+// back to the one sent with the request.
+func responseRequestID(resp *http.Response) string {
+	if id := resp.Header.Get(requestIDHeader); id != "" {
+		return id
+	}
+	if trace := resp.Header.Get("X-Cloud-Trace-Context"); trace != "" {
+		return strings.SplitN(trace, "/", 2)[0]
+	}
+	if resp.Request != nil {
+		return resp.Request.Header.Get(requestIDHeader)
+	}
+	return ""
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	// crypto/rand.Read never returns an error on supported platforms.
+	_, _ = rand.Read(b)
+	return b
+}
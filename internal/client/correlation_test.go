@@ -0,0 +1,81 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var (
+	uuidV4Pattern      = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	traceparentPattern = regexp.MustCompile(`^00-([0-9a-f]{32})-([0-9a-f]{16})-01$`)
+)
+
+func TestCorrelationHeaders(t *testing.T) {
+	srv := newRecordingServer(t, http.StatusOK, nil)
+	svc := newTestService(t, srv.URL, ClientOptions{}, nil)
+
+	seen := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		if _, err := svc.GetStatus(context.Background(), "gs://bucket/dag.py"); err != nil {
+			t.Fatal(err)
+		}
+		h := srv.last(t)
+		id := h.Get("X-Request-ID")
+		if !uuidV4Pattern.MatchString(id) {
+			t.Fatalf("X-Request-ID = %q, want a version 4 UUID", id)
+		}
+		if seen[id] {
+			t.Errorf("X-Request-ID %s was sent twice", id)
+		}
+		seen[id] = true
+
+		m := traceparentPattern.FindStringSubmatch(h.Get("traceparent"))
+		if m == nil {
+			t.Fatalf("traceparent = %q, want a W3C traceparent", h.Get("traceparent"))
+		}
+		if want := strings.ReplaceAll(id, "-", ""); m[1] != want {
+			t.Errorf("traceparent trace ID = %s, want %s from the request ID", m[1], want)
+		}
+	}
+}
+
+func TestAPIErrorRequestID(t *testing.T) {
+	tests := []struct {
+		name        string
+		respHeaders map[string]string
+		want        string
+	}{
+		{name: "backend request ID", respHeaders: map[string]string{"X-Request-ID": "backend-123"}, want: "backend-123"},
+		{name: "cloud trace context", respHeaders: map[string]string{"X-Cloud-Trace-Context": "abc123/456;o=1"}, want: "abc123"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newRecordingServer(t, http.StatusInternalServerError, tt.respHeaders)
+			svc := newTestService(t, srv.URL, ClientOptions{}, nil)
+
+			_, err := svc.GetStatus(context.Background(), "gs://bucket/dag.py")
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("err = %v, want an *APIError", err)
+			}
+			if apiErr.RequestID != tt.want || !strings.Contains(err.Error(), "(request ID: "+tt.want+")") {
+				t.Errorf("err = %q, want it to carry request ID %s", err, tt.want)
+			}
+		})
+	}
+
+	t.Run("sent request ID", func(t *testing.T) {
+		srv := newRecordingServer(t, http.StatusInternalServerError, nil)
+		svc := newTestService(t, srv.URL, ClientOptions{}, nil)
+
+		_, err := svc.GetStatus(context.Background(), "gs://bucket/dag.py")
+		sent := srv.last(t).Get("X-Request-ID")
+		if err == nil || !strings.Contains(err.Error(), "(request ID: "+sent+")") {
+			t.Errorf("err = %v, want it to carry the sent request ID %s", err, sent)
+		}
+	})
+}
@@ -4,11 +4,24 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	neturl "net/url"
+	"sort"
 	"strings"
-
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/idtoken"
@@ -18,6 +31,15 @@ import (
 type DagGeneratorAPIClient struct {
 	BaseURL               string
 	HTTPClient            *http.Client
+	UserAgent             string
+	DefaultHeaders        map[string]string
+	MaxRetries            int
+	HealthCheck           bool
+	limiter               *requestLimiter
+	breaker               *circuitBreaker
+	healthMu              sync.Mutex
+	healthChecked         bool
+	healthErr             error
 	useServiceAccountAuth bool
 	idTokenSource         oauth2.TokenSource
 }
@@ -29,15 +51,67 @@ func NewDagGeneratorAPIClient(baseURL string) *DagGeneratorAPIClient {
 	}
 }
 
-// NewDagGeneratorAPIClientWithAuth creates a client with optional service account auth.
-func NewDagGeneratorAPIClientWithAuth(baseURL string, useServiceAccountAuth bool) *DagGeneratorAPIClient {
+// ClientOptions configures optional behaviour of a DagGeneratorAPIClient.
+type ClientOptions struct {
+	UseServiceAccountAuth bool
+	Transport             TransportConfig
+	UserAgent             string
+	DefaultHeaders        map[string]string
+	// MaxRetries is how many times a request is resent after a network error
+	// or a 429/502/503/504 response.
+	MaxRetries int
+	// MaxConcurrentRequests caps the number of in-flight requests made through
+	// the client. Zero means no limit.
+	MaxConcurrentRequests int
+	// RequestsPerSecond caps the rate of requests made through the client,
+	// including retries. Zero means no limit.
+	RequestsPerSecond float64
+	// CircuitBreakerThreshold is the number of consecutive failed requests
+	// after which further requests fail fast. Zero disables the breaker.
+	CircuitBreakerThreshold int
+	// CircuitBreakerCooldown is how long the breaker stays open before a
+	// trial request is allowed.
+	CircuitBreakerCooldown time.Duration
+	// HealthCheck probes /healthz before the first request through the client.
+	HealthCheck bool
+}
+
+// UserAgent returns the User-Agent sent to the backend, identifying the provider and Terraform versions.
+func UserAgent(providerVersion, terraformVersion string) string {
+	if providerVersion == "" {
+		providerVersion = "dev"
+	}
+	ua := "terraform-provider-mirage/" + providerVersion
+	if terraformVersion != "" {
+		ua += " terraform/" + terraformVersion
+	}
+	return ua
+}
+
+// NewDagGeneratorAPIClientWithOptions creates a client whose HTTP transport and
+// authentication are configured from opts.
+func NewDagGeneratorAPIClientWithOptions(baseURL string, opts ClientOptions) (*DagGeneratorAPIClient, error) {
+	httpClient, err := NewHTTPClient(opts.Transport)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckHeaders(opts.DefaultHeaders, opts.UseServiceAccountAuth); err != nil {
+		return nil, fmt.Errorf("default_headers: %w", err)
+	}
+
 	client := &DagGeneratorAPIClient{
 		BaseURL:               baseURL,
-		HTTPClient:            &http.Client{},
-		useServiceAccountAuth: useServiceAccountAuth,
+		HTTPClient:            httpClient,
+		UserAgent:             opts.UserAgent,
+		DefaultHeaders:        opts.DefaultHeaders,
+		MaxRetries:            opts.MaxRetries,
+		HealthCheck:           opts.HealthCheck,
+		limiter:               newRequestLimiter(opts.MaxConcurrentRequests, opts.RequestsPerSecond),
+		breaker:               newCircuitBreaker(opts.CircuitBreakerThreshold, opts.CircuitBreakerCooldown),
+		useServiceAccountAuth: opts.UseServiceAccountAuth,
 	}
 
-	if useServiceAccountAuth {
+	if opts.UseServiceAccountAuth {
 		// Try to create ID token source first
 		ts, err := idtoken.NewTokenSource(context.Background(), baseURL)
 		if err != nil {
@@ -58,7 +132,7 @@ func NewDagGeneratorAPIClientWithAuth(baseURL string, useServiceAccountAuth bool
 			client.idTokenSource = ts
 		}
 	}
-	return client
+	return client, nil
 }
 
 // addAuthHeader adds the ID token if service account auth is enabled.
@@ -75,9 +149,159 @@ func (c *DagGeneratorAPIClient) addAuthHeader(ctx context.Context, req *http.Req
 	return nil
 }
 
+// do sends req unless the circuit breaker is open, and records the outcome.
+func (c *DagGeneratorAPIClient) do(ctx context.Context, req *http.Request, headers map[string]string) (*http.Response, error) {
+	if err := c.checkAvailable(ctx); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.roundTrip(ctx, req, headers)
+	c.breaker.record(ctx, c.BaseURL, resp, err)
+	return resp, err
+}
+
+// managedHeaders are set by the client itself and are never taken from the
+// default or per-call headers.
+var managedHeaders = map[string]bool{
+	"Content-Type":                           true,
+	"Content-Length":                         true,
+	"Host":                                   true,
+	"User-Agent":                             true,
+	http.CanonicalHeaderKey(requestIDHeader): true,
+	http.CanonicalHeaderKey(traceparentHeader): true,
+	"Tracestate": true,
+}
+
+// CheckHeaders returns an error naming a header in headers that the client
+// manages itself. Authorization is managed when service account auth is used.
+func CheckHeaders(headers map[string]string, useServiceAccountAuth bool) error {
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		if isManagedHeader(k, useServiceAccountAuth) {
+			return fmt.Errorf("header %q is set by the provider and cannot be overridden", k)
+		}
+	}
+	return nil
+}
+
+func isManagedHeader(name string, useServiceAccountAuth bool) bool {
+	name = http.CanonicalHeaderKey(name)
+	return managedHeaders[name] || (useServiceAccountAuth && name == "Authorization")
+}
+
+// roundTrip sends req after applying the User-Agent, the client's default headers,
+// the given per-call headers and the auth header, in that order of precedence.
+// Default and per-call headers never replace the headers the client manages.
+func (c *DagGeneratorAPIClient) roundTrip(ctx context.Context, req *http.Request, headers map[string]string) (*http.Response, error) {
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+	for _, extra := range []map[string]string{c.DefaultHeaders, headers} {
+		for k, v := range extra {
+			if !isManagedHeader(k, c.useServiceAccountAuth) {
+				req.Header.Set(k, v)
+			}
+		}
+	}
+
+	if err := c.addAuthHeader(ctx, req); err != nil {
+		return nil, err
+	}
+
+	requestID := req.Header.Get(requestIDHeader)
+	if requestID == "" {
+		requestID = newRequestID()
+		req.Header.Set(requestIDHeader, requestID)
+	}
+
+	ctx, span := tracing.Tracer().Start(ctx, req.Method+" "+req.URL.Path,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.URLFull(req.URL.Redacted()),
+			attribute.String("mirage.request_id", requestID),
+		),
+	)
+	defer span.End()
+
+	// Prefer the active trace so backend spans join it; otherwise derive a
+	// standalone traceparent from the request ID.
+	if span.SpanContext().IsValid() {
+		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	}
+	if req.Header.Get(traceparentHeader) == "" {
+		req.Header.Set(traceparentHeader, newTraceparent(requestID))
+	}
+
+	logFields := map[string]interface{}{
+		"method":     req.Method,
+		"url":        req.URL.Redacted(),
+		"request_id": requestID,
+	}
+	tflog.Debug(ctx, "Sending backend request", logFields)
+
+	release, err := c.limiter.acquire(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("%s %s (request ID: %s): %w", req.Method, req.URL.Path, requestID, err)
+	}
+
+	resp, retries, err := c.send(ctx, req)
+	span.SetAttributes(attribute.Int("mirage.retry_count", retries))
+	if err != nil {
+		release()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		tflog.Debug(ctx, "Backend request failed", mergeFields(logFields, map[string]interface{}{
+			"error":   err.Error(),
+			"retries": retries,
+		}))
+		return nil, fmt.Errorf("%s %s (request ID: %s): %w", req.Method, req.URL.Path, requestID, err)
+	}
+
+	backendRequestID := responseRequestID(resp)
+	span.SetAttributes(
+		semconv.HTTPResponseStatusCode(resp.StatusCode),
+		attribute.String("mirage.backend_request_id", backendRequestID),
+	)
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+
+	tflog.Debug(ctx, "Received backend response", mergeFields(logFields, map[string]interface{}{
+		"status":             resp.StatusCode,
+		"backend_request_id": backendRequestID,
+		"retries":            retries,
+	}))
+	return resp, nil
+}
+
+func mergeFields(base, extra map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base)+len(extra))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range extra {
+		merged[k] = v
+	}
+	return merged
+}
+
 // DagGeneratorService handles the API calls for the dag_generator resource.
 type DagGeneratorService struct {
 	Client *DagGeneratorAPIClient
+	// Headers are sent with every request and override the client's default headers.
+	Headers map[string]string
+}
+
+func (s *DagGeneratorService) do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	return s.Client.do(ctx, req, s.Headers)
 }
 
 // GenerateResponse matches the JSON from the backend's /generate endpoint.
@@ -88,29 +312,73 @@ type GenerateResponse struct {
 
 // StatusResponse matches the JSON from the backend's /status endpoint.
 type StatusResponse struct {
-	Checksum   string `json:"checksum"`
-	Generation string `json:"generation"`
+	Checksum     string `json:"checksum"`
+	Generation   string `json:"generation"`
+	Size         int64  `json:"size"`
+	ContentType  string `json:"content_type"`
+	CacheControl string `json:"cache_control"`
+	LastModified string `json:"last_modified"`
+	// Metadata holds the custom metadata (labels) of the file.
+	Metadata map[string]string `json:"metadata"`
+	// Content is only returned when requested with StatusOptions.IncludeContent.
+	Content string `json:"content"`
+}
+
+// StatusOptions controls what GetStatusWithOptions asks the backend to return.
+type StatusOptions struct {
+	IncludeContent bool
 }
 
+// ErrNotFound is matched by errors.Is when the backend reports that a file
+// does not exist.
+var ErrNotFound = errors.New("file not found")
+
 // TemplateStatusResponse matches the JSON from the backend's /template-status endpoint.
 type TemplateStatusResponse struct {
-	Checksum         string `json:"checksum"`
-	LastModified     string `json:"last_modified"`
-	Generation       string `json:"generation"`
-	Exists           bool   `json:"exists"`
+	Checksum     string `json:"checksum"`
+	LastModified string `json:"last_modified"`
+	Generation   string `json:"generation"`
+	Exists       bool   `json:"exists"`
+}
+
+// GenerateRequest is the payload of the backend's /generate endpoint.
+type GenerateRequest struct {
+	TemplateGCSPath string `json:"template_gcs_path"`
+	TemplateContent string `json:"template_content"`
+	TargetGCSPath   string `json:"target_gcs_path"`
+	ContextJSON     string `json:"context_json"`
+	// TemplateFiles holds additional templates, keyed by relative path, that
+	// the main template can reference with include, import or extends.
+	TemplateFiles map[string]string `json:"template_files,omitempty"`
+	// ContentType, CacheControl and Metadata set the corresponding object
+	// metadata of the generated file. The storage default applies when empty.
+	ContentType  string            `json:"content_type,omitempty"`
+	CacheControl string            `json:"cache_control,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	// ProvenanceHeader is a comment block to prepend to the rendered file,
+	// recording how it was generated. Empty means no header.
+	ProvenanceHeader string `json:"provenance_header,omitempty"`
+	// ValidatePython asks for the rendered file to be checked as Python
+	// before it is written. A file that does not parse fails the request and
+	// leaves the target untouched. Like IfGenerationMatch, it is only honored
+	// when the provider renders the file itself and is never sent to the
+	// backend.
+	ValidatePython bool `json:"-"`
+	// StrictUndefined asks for the template to be rendered with Jinja2's
+	// StrictUndefined, so using a variable the context does not define fails
+	// the request instead of rendering as empty.
+	StrictUndefined bool `json:"strict_undefined,omitempty"`
+	// IfGenerationMatch makes the write conditional on the target's current
+	// generation. It is only honored when the provider writes to storage
+	// directly and is never sent to the backend.
+	IfGenerationMatch string `json:"-"`
 }
 
 // Generate calls the backend to create or update a file.
-func (s *DagGeneratorService) Generate(ctx context.Context, templatePath, templateContent, targetPath string, contextJSON string) (*GenerateResponse, error) {
+func (s *DagGeneratorService) Generate(ctx context.Context, genReq GenerateRequest) (*GenerateResponse, error) {
 	url := fmt.Sprintf("%s/generate", s.Client.BaseURL)
 
-	payload := map[string]interface{}{
-		"template_gcs_path": templatePath,
-		"template_content":  templateContent,
-		"target_gcs_path":   targetPath,
-		"context_json":      contextJSON,
-	}
-	body, err := json.Marshal(payload)
+	body, err := json.Marshal(genReq)
 	if err != nil {
 		return nil, err
 	}
@@ -121,11 +389,7 @@ func (s *DagGeneratorService) Generate(ctx context.Context, templatePath, templa
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	if err := s.Client.addAuthHeader(ctx, req); err != nil {
-		return nil, err
-	}
-
-	resp, err := s.Client.HTTPClient.Do(req)
+	resp, err := s.do(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -135,8 +399,7 @@ func (s *DagGeneratorService) Generate(ctx context.Context, templatePath, templa
 	}(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		respBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("backend returned status %d: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp)
 	}
 
 	var genResp GenerateResponse
@@ -149,18 +412,24 @@ func (s *DagGeneratorService) Generate(ctx context.Context, templatePath, templa
 
 // GetStatus retrieves the current checksum and generation for a file.
 func (s *DagGeneratorService) GetStatus(ctx context.Context, path string) (*StatusResponse, error) {
-	url := fmt.Sprintf("%s/status?target_gcs_path=%s", s.Client.BaseURL, path)
+	return s.GetStatusWithOptions(ctx, path, StatusOptions{})
+}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, err
+// GetStatusWithOptions retrieves the metadata and, optionally, the content of a file.
+// It returns ErrNotFound if the file does not exist.
+func (s *DagGeneratorService) GetStatusWithOptions(ctx context.Context, path string, opts StatusOptions) (*StatusResponse, error) {
+	query := neturl.Values{"target_gcs_path": {path}}
+	if opts.IncludeContent {
+		query.Set("include_content", "true")
 	}
+	url := fmt.Sprintf("%s/status?%s", s.Client.BaseURL, query.Encode())
 
-	if err := s.Client.addAuthHeader(ctx, req); err != nil {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
 		return nil, err
 	}
 
-	resp, err := s.Client.HTTPClient.Do(req)
+	resp, err := s.do(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -169,12 +438,8 @@ func (s *DagGeneratorService) GetStatus(ctx context.Context, path string) (*Stat
 		_ = Body.Close()
 	}(resp.Body)
 
-	if resp.StatusCode == http.StatusNotFound {
-		return nil, fmt.Errorf("file not found")
-	}
 	if resp.StatusCode != http.StatusOK {
-		respBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("backend returned status %d: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp)
 	}
 
 	var statusResp StatusResponse
@@ -194,11 +459,7 @@ func (s *DagGeneratorService) GetTemplateStatus(ctx context.Context, templatePat
 		return nil, err
 	}
 
-	if err := s.Client.addAuthHeader(ctx, req); err != nil {
-		return nil, err
-	}
-
-	resp, err := s.Client.HTTPClient.Do(req)
+	resp, err := s.do(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -211,8 +472,7 @@ func (s *DagGeneratorService) GetTemplateStatus(ctx context.Context, templatePat
 		return &TemplateStatusResponse{Exists: false}, nil
 	}
 	if resp.StatusCode != http.StatusOK {
-		respBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("backend returned status %d: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp)
 	}
 
 	var templateStatusResp TemplateStatusResponse
@@ -242,11 +502,7 @@ func (s *DagGeneratorService) Delete(ctx context.Context, path string) error {
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	if err := s.Client.addAuthHeader(ctx, req); err != nil {
-		return err
-	}
-
-	resp, err := s.Client.HTTPClient.Do(req)
+	resp, err := s.do(ctx, req)
 	if err != nil {
 		return err
 	}
@@ -256,8 +512,7 @@ func (s *DagGeneratorService) Delete(ctx context.Context, path string) error {
 	}(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("backend returned status %d: %s", resp.StatusCode, string(respBody))
+		return newAPIError(resp)
 	}
 
 	return nil
@@ -0,0 +1,163 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordingServer is a backend that answers every request with status and
+// keeps the headers of the requests it received.
+type recordingServer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	headers []http.Header
+}
+
+func newRecordingServer(t *testing.T, status int, respHeaders map[string]string) *recordingServer {
+	t.Helper()
+	s := &recordingServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		s.mu.Lock()
+		s.headers = append(s.headers, req.Header.Clone())
+		s.mu.Unlock()
+		for k, v := range respHeaders {
+			w.Header().Set(k, v)
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("{}"))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// last returns the headers of the last request the server received.
+func (s *recordingServer) last(t *testing.T) http.Header {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.headers) == 0 {
+		t.Fatal("the server received no request")
+	}
+	return s.headers[len(s.headers)-1]
+}
+
+func newTestService(t *testing.T, baseURL string, opts ClientOptions, headers map[string]string) *DagGeneratorService {
+	t.Helper()
+	c, err := NewDagGeneratorAPIClientWithOptions(baseURL, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &DagGeneratorService{Client: c, Headers: headers}
+}
+
+func TestUserAgent(t *testing.T) {
+	tests := []struct {
+		provider, terraform string
+		want                string
+	}{
+		{"1.4.0", "1.9.5", "terraform-provider-mirage/1.4.0 terraform/1.9.5"},
+		{"1.4.0", "", "terraform-provider-mirage/1.4.0"},
+		{"", "1.9.5", "terraform-provider-mirage/dev terraform/1.9.5"},
+	}
+	for _, tt := range tests {
+		if got := UserAgent(tt.provider, tt.terraform); got != tt.want {
+			t.Errorf("UserAgent(%q, %q) = %q, want %q", tt.provider, tt.terraform, got, tt.want)
+		}
+	}
+}
+
+func TestRequestHeaders(t *testing.T) {
+	srv := newRecordingServer(t, http.StatusOK, nil)
+	svc := newTestService(t, srv.URL, ClientOptions{
+		UserAgent:      UserAgent("1.4.0", "1.9.5"),
+		DefaultHeaders: map[string]string{"X-Team": "data", "X-Env": "prod"},
+	}, map[string]string{
+		"X-Env":        "staging",
+		"User-Agent":   "curl/8.0",
+		"X-Request-ID": "chosen-by-the-resource",
+	})
+
+	if _, err := svc.GetStatus(context.Background(), "gs://bucket/dag.py"); err != nil {
+		t.Fatal(err)
+	}
+	h := srv.last(t)
+	for name, want := range map[string]string{
+		"User-Agent": "terraform-provider-mirage/1.4.0 terraform/1.9.5",
+		"X-Team":     "data",
+		"X-Env":      "staging",
+	} {
+		if got := h.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if got := h.Get("X-Request-ID"); got == "chosen-by-the-resource" || got == "" {
+		t.Errorf("X-Request-ID = %q, want one generated by the client", got)
+	}
+}
+
+func TestCheckHeaders(t *testing.T) {
+	tests := []struct {
+		name                  string
+		headers               map[string]string
+		useServiceAccountAuth bool
+		wantErr               string
+	}{
+		{name: "custom headers", headers: map[string]string{"X-Team": "data", "Authorization": "Bearer token"}},
+		{name: "user agent", headers: map[string]string{"User-Agent": "curl/8.0"}, wantErr: `"User-Agent"`},
+		{name: "request ID in any case", headers: map[string]string{"x-request-id": "1"}, wantErr: `"x-request-id"`},
+		{name: "traceparent", headers: map[string]string{"traceparent": "00-1-2-01"}, wantErr: `"traceparent"`},
+		{name: "content type", headers: map[string]string{"Content-Type": "text/plain"}, wantErr: `"Content-Type"`},
+		{name: "authorization with service account auth", headers: map[string]string{"Authorization": "Bearer token"}, useServiceAccountAuth: true, wantErr: `"Authorization"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckHeaders(tt.headers, tt.useServiceAccountAuth)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want it to name %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewClientRejectsManagedDefaultHeaders(t *testing.T) {
+	_, err := NewDagGeneratorAPIClientWithOptions("http://backend.invalid", ClientOptions{
+		DefaultHeaders: map[string]string{"X-Request-ID": "1"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "default_headers") {
+		t.Errorf("err = %v, want a default_headers error", err)
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	srv := newRecordingServer(t, http.StatusNotFound, map[string]string{"X-Request-ID": "backend-404"})
+	svc := newTestService(t, srv.URL, ClientOptions{}, nil)
+
+	_, statusErr := svc.GetStatus(context.Background(), "gs://bucket/dag.py")
+	deleteErr := svc.Delete(context.Background(), "gs://bucket/dag.py")
+	for name, err := range map[string]error{"GetStatus": statusErr, "Delete": deleteErr} {
+		if !errors.Is(err, ErrNotFound) || !strings.Contains(err.Error(), "(request ID: backend-404)") {
+			t.Errorf("%s: err = %v, want ErrNotFound with the request ID", name, err)
+		}
+	}
+}
+
+func TestServerErrorIsNotNotFound(t *testing.T) {
+	srv := newRecordingServer(t, http.StatusInternalServerError, nil)
+	svc := newTestService(t, srv.URL, ClientOptions{}, nil)
+
+	if _, err := svc.GetStatus(context.Background(), "gs://bucket/dag.py"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("err = %v, want an error other than ErrNotFound", err)
+	}
+}
@@ -0,0 +1,122 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// CheckHealth probes the backend's /healthz endpoint. It bypasses the circuit
+// breaker so it can be used to decide whether the backend is reachable.
+func (c *DagGeneratorAPIClient) CheckHealth(ctx context.Context) error {
+	url := fmt.Sprintf("%s/healthz", c.BaseURL)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.roundTrip(ctx, req, nil)
+	if err != nil {
+		return fmt.Errorf("health check failed: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check failed: %w", newAPIError(resp))
+	}
+	return nil
+}
+
+// ProbeHealth reports whether the backend's /healthz endpoint answers. When
+// the client runs a health check before its first request, that check is
+// reused, so /healthz is probed only once per client.
+func (c *DagGeneratorAPIClient) ProbeHealth(ctx context.Context) error {
+	if !c.HealthCheck {
+		return c.CheckHealth(ctx)
+	}
+	healthErr, err := c.checkHealthOnce(ctx)
+	if err != nil {
+		return err
+	}
+	return healthErr
+}
+
+// checkAvailable runs the one-off health check, if enabled, and consults the
+// circuit breaker before a request is sent.
+func (c *DagGeneratorAPIClient) checkAvailable(ctx context.Context) error {
+	if c.HealthCheck {
+		healthErr, err := c.checkHealthOnce(ctx)
+		if err != nil {
+			return err
+		}
+		if c.breaker == nil && healthErr != nil {
+			return fmt.Errorf("backend %s is unavailable: %w", c.BaseURL, healthErr)
+		}
+	}
+	return c.breaker.allow(ctx, c.BaseURL)
+}
+
+// checkHealthOnce returns the outcome of the health check, running it if no
+// earlier call has. A probe cut short by ctx is not remembered: it is
+// returned as err, and the next caller probes again.
+func (c *DagGeneratorAPIClient) checkHealthOnce(ctx context.Context) (healthErr, err error) {
+	c.healthMu.Lock()
+	defer c.healthMu.Unlock()
+
+	if c.healthChecked {
+		return c.healthErr, nil
+	}
+	if err := c.CheckHealth(ctx); err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		c.healthErr = err
+		c.breaker.trip(ctx, c.BaseURL, err)
+	}
+	c.healthChecked = true
+	return c.healthErr, nil
+}
+
+// BackendInfo matches the JSON from the backend's /info endpoint.
+type BackendInfo struct {
+	APIVersion  string   `json:"api_version"`
+	BuildCommit string   `json:"build_commit"`
+	Features    []string `json:"features"`
+	Principal   string   `json:"principal"`
+}
+
+// GetBackendInfo retrieves the backend's version, supported features and the
+// principal it authenticated the caller as.
+func (s *DagGeneratorService) GetBackendInfo(ctx context.Context) (*BackendInfo, error) {
+	url := fmt.Sprintf("%s/info", s.Client.BaseURL)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp)
+	}
+
+	var info BackendInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
@@ -0,0 +1,92 @@
+package client
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"golang.org/x/time/rate"
+)
+
+// requestLimiter caps the number of in-flight backend requests and the rate
+// at which new ones are sent. A nil limiter imposes no limits.
+type requestLimiter struct {
+	slots chan struct{}
+	rate  *rate.Limiter
+}
+
+// newRequestLimiter returns nil when neither limit is set.
+func newRequestLimiter(maxConcurrent int, requestsPerSecond float64) *requestLimiter {
+	if maxConcurrent <= 0 && requestsPerSecond <= 0 {
+		return nil
+	}
+
+	l := &requestLimiter{}
+	if maxConcurrent > 0 {
+		l.slots = make(chan struct{}, maxConcurrent)
+	}
+	if requestsPerSecond > 0 {
+		burst := int(requestsPerSecond)
+		if burst < 1 {
+			burst = 1
+		}
+		l.rate = rate.NewLimiter(rate.Limit(requestsPerSecond), burst)
+	}
+	return l
+}
+
+// acquire blocks until a concurrency slot is free and returns the function
+// that releases it.
+func (l *requestLimiter) acquire(ctx context.Context) (func(), error) {
+	if l == nil || l.slots == nil {
+		return func() {}, nil
+	}
+
+	start := time.Now()
+	select {
+	case l.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if waited := time.Since(start); waited >= time.Millisecond {
+		tflog.Debug(ctx, "Waited for a backend concurrency slot", map[string]interface{}{
+			"wait_ms": waited.Milliseconds(),
+		})
+	}
+
+	var once sync.Once
+	return func() { once.Do(func() { <-l.slots }) }, nil
+}
+
+// wait blocks until the rate limit allows another request.
+func (l *requestLimiter) wait(ctx context.Context) error {
+	if l == nil || l.rate == nil {
+		return nil
+	}
+
+	start := time.Now()
+	if err := l.rate.Wait(ctx); err != nil {
+		return err
+	}
+	if waited := time.Since(start); waited >= time.Millisecond {
+		tflog.Debug(ctx, "Waited for the backend rate limiter", map[string]interface{}{
+			"wait_ms": waited.Milliseconds(),
+		})
+	}
+	return nil
+}
+
+// releasingBody releases a concurrency slot once the response body is closed,
+// so a request counts as in flight until its body has been consumed.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
@@ -0,0 +1,88 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMaxConcurrentRequests(t *testing.T) {
+	const limit, calls = 2, 8
+
+	var mu sync.Mutex
+	inFlight, peak := 0, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		inFlight++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		_, _ = w.Write([]byte("{}"))
+	}))
+	t.Cleanup(srv.Close)
+	svc := newTestService(t, srv.URL, ClientOptions{MaxConcurrentRequests: limit}, nil)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, calls)
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := svc.GetStatus(context.Background(), "gs://bucket/dag.py")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if peak != limit {
+		t.Errorf("peak in-flight requests = %d, want %d", peak, limit)
+	}
+}
+
+func TestMaxConcurrentRequestsCancelled(t *testing.T) {
+	l := newRequestLimiter(1, 0)
+	release, err := l.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := l.acquire(ctx); err != context.DeadlineExceeded {
+		t.Errorf("err = %v, want %v while every slot is taken", err, context.DeadlineExceeded)
+	}
+}
+
+func TestRequestsPerSecond(t *testing.T) {
+	// The burst equals the rate, so 12 requests at 10 per second take at least
+	// 200ms: the first 10 go at once and the rest are spaced 100ms apart.
+	const rps, calls = 10, 12
+
+	srv := newRecordingServer(t, http.StatusOK, nil)
+	svc := newTestService(t, srv.URL, ClientOptions{RequestsPerSecond: rps}, nil)
+
+	start := time.Now()
+	for i := 0; i < calls; i++ {
+		if _, err := svc.GetStatus(context.Background(), "gs://bucket/dag.py"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 180*time.Millisecond {
+		t.Errorf("%d requests at %d per second took %v, want at least 200ms", calls, rps, elapsed)
+	}
+}
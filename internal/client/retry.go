@@ -0,0 +1,73 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 10 * time.Second
+)
+
+// send performs req, resending it up to MaxRetries times after network errors
+// and retryable status codes. It returns the final response and the number of
+// retries made.
+func (c *DagGeneratorAPIClient) send(ctx context.Context, req *http.Request) (*http.Response, int, error) {
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, attempt, err
+			}
+			req.Body = body
+		}
+
+		if err := c.limiter.wait(ctx); err != nil {
+			return nil, attempt, err
+		}
+
+		resp, err := c.HTTPClient.Do(req)
+		canRetry := attempt < c.MaxRetries && (req.GetBody != nil || req.Body == nil || req.Body == http.NoBody)
+		if !canRetry || (err == nil && !retryableStatus(resp.StatusCode)) {
+			return resp, attempt, err
+		}
+		if err != nil && ctx.Err() != nil {
+			return nil, attempt, err
+		}
+
+		delay := retryDelay(attempt, resp)
+		if resp != nil {
+			_ = resp.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, attempt, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryDelay backs off exponentially, honouring a Retry-After header in seconds.
+func retryDelay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs >= 0 {
+			return min(time.Duration(secs)*time.Second, retryMaxDelay)
+		}
+	}
+	if attempt >= 5 {
+		return retryMaxDelay
+	}
+	return min(retryBaseDelay<<attempt, retryMaxDelay)
+}
@@ -0,0 +1,68 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+)
+
+// UploadTemplateResponse matches the JSON from the backend's /upload-template endpoint.
+type UploadTemplateResponse struct {
+	Checksum   string `json:"checksum"`
+	Generation string `json:"generation"`
+}
+
+// UploadTemplate writes template content to a GCS path through the backend.
+func (s *DagGeneratorService) UploadTemplate(ctx context.Context, templatePath, templateContent string) (*UploadTemplateResponse, error) {
+	url := fmt.Sprintf("%s/upload-template", s.Client.BaseURL)
+
+	payload := map[string]interface{}{
+		"template_gcs_path": templatePath,
+		"template_content":  templateContent,
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp)
+	}
+
+	var uploadResp UploadTemplateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&uploadResp); err != nil {
+		return nil, err
+	}
+
+	return &uploadResp, nil
+}
+
+// CRC32C returns the checksum of data in the format used by GCS object
+// metadata: the base64 encoding of the big-endian CRC32C value.
+func CRC32C(data []byte) string {
+	sum := crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli))
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], sum)
+	return base64.StdEncoding.EncodeToString(b[:])
+}
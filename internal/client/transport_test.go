@@ -0,0 +1,123 @@
+package client
+
+import (
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// certPEM returns the certificate of the TLS server srv, PEM-encoded.
+func certPEM(srv *httptest.Server) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}))
+}
+
+func okServer(t *testing.T, tls bool) *httptest.Server {
+	t.Helper()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	})
+	srv := httptest.NewUnstartedServer(handler)
+	if tls {
+		srv.StartTLS()
+	} else {
+		srv.Start()
+	}
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestNewHTTPClientCustomCA(t *testing.T) {
+	srv := okServer(t, true)
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, []byte(certPEM(srv)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		cfg     TransportConfig
+		wantErr bool
+	}{
+		{name: "system roots only", cfg: TransportConfig{}, wantErr: true},
+		{name: "CA file", cfg: TransportConfig{CACertFile: caFile}},
+		{name: "CA PEM", cfg: TransportConfig{CACertPEM: certPEM(srv)}},
+		{name: "insecure", cfg: TransportConfig{InsecureSkipVerify: true}},
+		{name: "minimum TLS version", cfg: TransportConfig{CACertPEM: certPEM(srv), MinTLSVersion: "1.2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpClient, err := NewHTTPClient(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := httpClient.Get(srv.URL)
+			if tt.wantErr {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatal("the request succeeded without trusting the server's certificate")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+		})
+	}
+}
+
+func TestNewHTTPClientInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  TransportConfig
+		want string
+	}{
+		{name: "missing CA file", cfg: TransportConfig{CACertFile: filepath.Join(t.TempDir(), "missing.pem")}, want: "reading CA bundle"},
+		{name: "CA PEM without certificates", cfg: TransportConfig{CACertPEM: "not a certificate"}, want: "no valid certificates"},
+		{name: "TLS version", cfg: TransportConfig{MinTLSVersion: "1.4"}, want: "unsupported TLS version"},
+		{name: "proxy URL", cfg: TransportConfig{ProxyURL: "http://proxy:port"}, want: "invalid proxy URL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewHTTPClient(tt.cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewHTTPClientProxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		proxied = append(proxied, req.URL.String())
+		_, _ = io.WriteString(w, "proxied")
+	}))
+	t.Cleanup(proxy.Close)
+
+	// The target host is never resolved: the proxy answers for it.
+	httpClient, err := NewHTTPClient(TransportConfig{ProxyURL: proxy.URL, NoProxy: []string{"direct.example"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := httpClient.Get("http://backend.example/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "proxied" || len(proxied) != 1 || proxied[0] != "http://backend.example/healthz" {
+		t.Errorf("body = %q, proxied = %q, want the request to go through the proxy", body, proxied)
+	}
+
+	transport := httpClient.Transport.(*http.Transport)
+	req, _ := http.NewRequest(http.MethodGet, "http://direct.example/healthz", nil)
+	if u, err := transport.Proxy(req); err != nil || u != nil {
+		t.Errorf("proxy for a no_proxy host = %v, %v, want none", u, err)
+	}
+}
@@ -0,0 +1,1284 @@
+package pysyntax
+
+// keywords are the reserved words, which cannot be used as names.
+var keywords = map[string]bool{
+	"False": true, "None": true, "True": true, "and": true, "as": true,
+	"assert": true, "async": true, "await": true, "break": true,
+	"class": true, "continue": true, "def": true, "del": true, "elif": true,
+	"else": true, "except": true, "finally": true, "for": true, "from": true,
+	"global": true, "if": true, "import": true, "in": true, "is": true,
+	"lambda": true, "nonlocal": true, "not": true, "or": true, "pass": true,
+	"raise": true, "return": true, "try": true, "while": true, "with": true,
+	"yield": true,
+}
+
+// futureFeatures are the features from __future__ imports can enable.
+var futureFeatures = map[string]bool{
+	"nested_scopes": true, "generators": true, "division": true,
+	"absolute_import": true, "with_statement": true, "print_function": true,
+	"unicode_literals": true, "barry_as_FLUFL": true, "generator_stop": true,
+	"annotations": true,
+}
+
+var augAssign = map[string]bool{
+	"+=": true, "-=": true, "*=": true, "/=": true, "//=": true, "%=": true,
+	"@=": true, "&=": true, "|=": true, "^=": true, ">>=": true, "<<=": true,
+	"**=": true,
+}
+
+var comparisons = map[string]bool{
+	"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true,
+}
+
+// expr describes a parsed expression: where it starts, whether it is
+// starred and whether it can be assigned to. what names the expression in
+// errors when it cannot.
+type expr struct {
+	tok     token
+	target  bool
+	starred bool
+	what    string
+}
+
+func targetExpr(tok token) expr {
+	return expr{tok: tok, target: true}
+}
+
+func valueExpr(tok token, what string) expr {
+	return expr{tok: tok, what: what}
+}
+
+type scopeKind int
+
+const (
+	moduleScope scopeKind = iota
+	classScope
+	functionScope
+	asyncFunctionScope
+)
+
+// scope is the innermost module, class or function body being parsed, for
+// the checks CPython makes when compiling: return, yield, await, break and
+// continue are only allowed in some places.
+type scope struct {
+	kind scopeKind
+	// loops is the number of loops around the statement being parsed.
+	loops int
+}
+
+// parser is a recursive descent parser for the Python 3 grammar. It
+// backtracks only to tell the soft keyword match and parenthesized with
+// items from ordinary expressions.
+type parser struct {
+	toks  []token
+	pos   int
+	scope scope
+	// parens counts the open parentheses, which may turn out to hold a
+	// generator expression, where await is allowed in any function.
+	parens int
+	// futureAllowed is set while from __future__ imports are allowed, before
+	// any other statement but a docstring.
+	futureAllowed bool
+}
+
+func (p *parser) peek() token {
+	return p.peekAt(0)
+}
+
+// peekAt returns the token n tokens ahead, reporting the scanning error if
+// the source could not be scanned that far.
+func (p *parser) peekAt(n int) token {
+	tok := p.toks[len(p.toks)-1]
+	if p.pos+n < len(p.toks) {
+		tok = p.toks[p.pos+n]
+	}
+	if tok.kind == tokError {
+		panic(tok.err)
+	}
+	return tok
+}
+
+func (p *parser) next() token {
+	tok := p.peek()
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// at reports whether the next token is the operator or keyword s.
+func (p *parser) at(s string) bool {
+	return is(p.peek(), s)
+}
+
+func is(tok token, s string) bool {
+	return (tok.kind == tokOp || tok.kind == tokName) && tok.val == s
+}
+
+func (p *parser) atKind(kind tokenKind) bool {
+	return p.peek().kind == kind
+}
+
+// accept consumes the next token if it is the operator or keyword s.
+func (p *parser) accept(s string) bool {
+	if p.at(s) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(s string) {
+	if !p.accept(s) {
+		p.fail(p.peek(), "expected '%s'", s)
+	}
+}
+
+// fail reports a syntax error at tok. Unexpected tokens are reported the way
+// CPython reports them.
+func (p *parser) fail(tok token, format string, args ...any) {
+	switch tok.kind {
+	case tokIndent:
+		fail(tok.line, tok.col, "unexpected indent")
+	case tokEOF:
+		fail(tok.line, tok.col, "unexpected EOF while parsing")
+	}
+	fail(tok.line, tok.col, format, args...)
+}
+
+func (p *parser) invalid() {
+	p.fail(p.peek(), "invalid syntax")
+}
+
+// try runs f and reports whether it parsed without error, rewinding to where
+// it started if not.
+func (p *parser) try(f func()) (ok bool) {
+	start := p.pos
+	defer func() {
+		if r := recover(); r != nil {
+			if _, isSyntaxErr := r.(*Error); !isSyntaxErr {
+				panic(r)
+			}
+			p.pos = start
+			ok = false
+		}
+	}()
+	f()
+	return true
+}
+
+func (p *parser) inFunction() bool {
+	return p.scope.kind == functionScope || p.scope.kind == asyncFunctionScope
+}
+
+// name consumes an identifier.
+func (p *parser) name() token {
+	tok := p.peek()
+	if tok.kind != tokName || keywords[tok.val] {
+		p.invalid()
+	}
+	return p.next()
+}
+
+func (p *parser) atName() bool {
+	tok := p.peek()
+	return tok.kind == tokName && !keywords[tok.val]
+}
+
+// parse parses the whole source and returns the first syntax error.
+func (p *parser) parse() (err *Error) {
+	defer func() {
+		if r := recover(); r != nil {
+			syntaxErr, ok := r.(*Error)
+			if !ok {
+				panic(r)
+			}
+			err = syntaxErr
+		}
+	}()
+	p.file()
+	return nil
+}
+
+// file parses a whole module.
+func (p *parser) file() {
+	p.futureAllowed = true
+	for first := true; !p.atKind(tokEOF); first = false {
+		if p.atKind(tokNewline) {
+			p.next()
+			continue
+		}
+		docstring := first && p.atKind(tokString)
+		if !docstring && !(p.at("from") && is(p.peekAt(1), "__future__")) {
+			p.futureAllowed = false
+		}
+		p.statement()
+	}
+}
+
+func (p *parser) statement() {
+	tok := p.peek()
+	if tok.kind == tokIndent || tok.kind == tokDedent {
+		p.fail(tok, "invalid syntax")
+	}
+	switch {
+	case is(tok, "if"):
+		p.ifStatement()
+	case is(tok, "while"):
+		p.next()
+		p.namedExpression()
+		p.loopBody("'while' statement", tok)
+		p.elseBlock()
+	case is(tok, "for"):
+		p.forStatement()
+	case is(tok, "try"):
+		p.tryStatement()
+	case is(tok, "with"):
+		p.withStatement()
+	case is(tok, "def"):
+		p.funcDef(false)
+	case is(tok, "class"):
+		p.classDef()
+	case is(tok, "async"):
+		p.next()
+		switch next := p.peek(); {
+		case is(next, "def"):
+			p.funcDef(true)
+		case is(next, "for"), is(next, "with"):
+			if p.scope.kind != asyncFunctionScope {
+				p.fail(tok, "'async %s' outside async function", next.val)
+			}
+			if next.val == "for" {
+				p.forStatement()
+			} else {
+				p.withStatement()
+			}
+		default:
+			p.invalid()
+		}
+	case is(tok, "@"):
+		p.decorated()
+	case is(tok, "match") && p.matchStatement():
+	default:
+		p.simpleStatements()
+	}
+}
+
+// block parses the body of a compound statement after its colon. what and
+// header name the statement in errors.
+func (p *parser) block(what string, header token) {
+	p.expect(":")
+	if !p.atKind(tokNewline) {
+		p.simpleStatements()
+		return
+	}
+	p.next()
+	if !p.atKind(tokIndent) {
+		fail(p.peek().line, p.peek().col, "expected an indented block after %s on line %d", what, header.line)
+	}
+	p.next()
+	for !p.atKind(tokDedent) && !p.atKind(tokEOF) {
+		p.statement()
+	}
+	p.next()
+}
+
+// loopBody parses the body of a loop, where break and continue are allowed.
+func (p *parser) loopBody(what string, header token) {
+	p.scope.loops++
+	p.block(what, header)
+	p.scope.loops--
+}
+
+// body parses the body of a function or class definition in a new scope.
+func (p *parser) body(kind scopeKind, what string, header token) {
+	outer := p.scope
+	p.scope = scope{kind: kind}
+	p.block(what, header)
+	p.scope = outer
+}
+
+func (p *parser) elseBlock() {
+	if tok := p.peek(); p.accept("else") {
+		p.block("'else' statement", tok)
+	}
+}
+
+func (p *parser) ifStatement() {
+	tok := p.next()
+	p.namedExpression()
+	p.block("'if' statement", tok)
+	for tok := p.peek(); p.accept("elif"); tok = p.peek() {
+		p.namedExpression()
+		p.block("'elif' statement", tok)
+	}
+	p.elseBlock()
+}
+
+func (p *parser) forStatement() {
+	tok := p.next()
+	p.targets("in")
+	p.expect("in")
+	p.starExpressions()
+	p.loopBody("'for' statement", tok)
+	p.elseBlock()
+}
+
+func (p *parser) tryStatement() {
+	tok := p.next()
+	p.block("'try' statement", tok)
+	handled := false
+	for tok := p.peek(); p.accept("except"); tok = p.peek() {
+		handled = true
+		star := p.accept("*")
+		if !p.at(":") {
+			p.expression()
+			if p.accept("as") {
+				p.name()
+			}
+		} else if star {
+			p.fail(p.peek(), "expected one or more exception types")
+		}
+		p.block("'except' statement", tok)
+	}
+	if handled {
+		p.elseBlock()
+	}
+	if tok := p.peek(); p.accept("finally") {
+		p.block("'finally' statement", tok)
+	} else if !handled {
+		p.fail(p.peek(), "expected 'except' or 'finally' block")
+	}
+}
+
+func (p *parser) withStatement() {
+	tok := p.next()
+	parenthesized := p.at("(") && p.try(func() {
+		p.next()
+		for {
+			p.withItem()
+			if !p.accept(",") || p.at(")") {
+				break
+			}
+		}
+		p.expect(")")
+		if !p.at(":") {
+			p.invalid()
+		}
+	})
+	if !parenthesized {
+		for {
+			p.withItem()
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+	p.block("'with' statement", tok)
+}
+
+func (p *parser) withItem() {
+	p.expression()
+	if p.accept("as") {
+		p.target()
+	}
+}
+
+func (p *parser) funcDef(async bool) {
+	tok := p.next()
+	p.name()
+	p.typeParams()
+	p.expect("(")
+	p.params(")", true)
+	p.expect(")")
+	if p.accept("->") {
+		p.expression()
+	}
+	kind := functionScope
+	if async {
+		kind = asyncFunctionScope
+	}
+	p.body(kind, "function definition", tok)
+}
+
+func (p *parser) classDef() {
+	tok := p.next()
+	p.name()
+	p.typeParams()
+	if p.accept("(") {
+		p.arguments()
+	}
+	p.body(classScope, "class definition", tok)
+}
+
+// typeParams parses an optional PEP 695 type parameter list.
+func (p *parser) typeParams() {
+	if !p.accept("[") {
+		return
+	}
+	for {
+		if !p.accept("*") {
+			p.accept("**")
+		}
+		p.name()
+		if p.accept(":") {
+			p.expression()
+		}
+		if p.accept("=") {
+			p.expression()
+		}
+		if !p.accept(",") || p.at("]") {
+			break
+		}
+	}
+	p.expect("]")
+}
+
+// params parses a parameter list up to closer, which is not consumed.
+// Lambda parameters have no annotations.
+func (p *parser) params(closer string, annotations bool) {
+	annotation := func() {
+		if annotations && p.accept(":") {
+			if p.accept("*") {
+				p.bitwiseOr()
+				return
+			}
+			p.expression()
+		}
+	}
+	for !p.at(closer) {
+		switch {
+		case p.accept("/"):
+		case p.accept("**"):
+			p.name()
+			annotation()
+		case p.at("*"):
+			star := p.next()
+			if p.atName() {
+				p.name()
+				annotation()
+			} else if p.at(closer) || (p.at(",") && (is(p.peekAt(1), "**") || is(p.peekAt(1), closer))) {
+				p.fail(star, "named arguments must follow bare *")
+			}
+		default:
+			p.name()
+			annotation()
+			if p.accept("=") {
+				p.expression()
+			}
+		}
+		if !p.accept(",") {
+			break
+		}
+	}
+}
+
+func (p *parser) decorated() {
+	for p.accept("@") {
+		p.namedExpression()
+		if !p.atKind(tokNewline) {
+			p.invalid()
+		}
+		p.next()
+	}
+	switch {
+	case p.at("def"):
+		p.funcDef(false)
+	case p.at("class"):
+		p.classDef()
+	case p.at("async") && is(p.peekAt(1), "def"):
+		p.next()
+		p.funcDef(true)
+	default:
+		p.invalid()
+	}
+}
+
+// matchStatement parses a match statement if the soft keyword match starts
+// one, and reports whether it did. Case patterns are only checked for
+// balance: anything up to the colon of the case block is accepted.
+func (p *parser) matchStatement() bool {
+	if !p.try(func() {
+		p.next()
+		p.starNamedExpressions()
+		p.expect(":")
+		if !p.atKind(tokNewline) || p.peekAt(1).kind != tokIndent || !is(p.peekAt(2), "case") {
+			p.invalid()
+		}
+	}) {
+		return false
+	}
+	p.next()
+	p.next()
+	for p.at("case") {
+		caseTok := p.next()
+		if p.at(":") {
+			p.invalid()
+		}
+		for depth := 0; depth > 0 || !p.at(":"); {
+			switch next := p.next(); {
+			case next.kind == tokNewline || next.kind == tokEOF:
+				p.fail(next, "expected ':'")
+			case is(next, "(") || is(next, "[") || is(next, "{"):
+				depth++
+			case is(next, ")") || is(next, "]") || is(next, "}"):
+				depth--
+			}
+		}
+		p.block("'case' statement", caseTok)
+	}
+	if !p.atKind(tokDedent) {
+		p.invalid()
+	}
+	p.next()
+	return true
+}
+
+func (p *parser) simpleStatements() {
+	for {
+		p.simpleStatement()
+		if !p.accept(";") || p.atKind(tokNewline) {
+			break
+		}
+	}
+	if !p.atKind(tokNewline) {
+		p.invalid()
+	}
+	p.next()
+}
+
+func (p *parser) simpleStatement() {
+	tok := p.peek()
+	switch {
+	case is(tok, "pass"):
+		p.next()
+	case is(tok, "break"):
+		if p.scope.loops == 0 {
+			p.fail(tok, "'break' outside loop")
+		}
+		p.next()
+	case is(tok, "continue"):
+		if p.scope.loops == 0 {
+			p.fail(tok, "'continue' not properly in loop")
+		}
+		p.next()
+	case is(tok, "return"):
+		if !p.inFunction() {
+			p.fail(tok, "'return' outside function")
+		}
+		p.next()
+		if p.startsExpression() {
+			p.starExpressions()
+		}
+	case is(tok, "raise"):
+		p.next()
+		if p.startsExpression() {
+			p.expression()
+			if p.accept("from") {
+				p.expression()
+			}
+		}
+	case is(tok, "global"), is(tok, "nonlocal"):
+		if tok.val == "nonlocal" && p.scope.kind == moduleScope {
+			p.fail(tok, "nonlocal declaration not allowed at module level")
+		}
+		p.next()
+		for {
+			p.name()
+			if !p.accept(",") {
+				break
+			}
+		}
+	case is(tok, "del"):
+		p.next()
+		for {
+			if star := p.peek(); p.accept("*") {
+				p.fail(star, "cannot delete starred")
+			}
+			if e := p.bitwiseOr(); !e.target {
+				fail(e.tok.line, e.tok.col, "cannot delete %s", e.what)
+			}
+			if !p.accept(",") || !p.startsExpression() {
+				break
+			}
+		}
+	case is(tok, "assert"):
+		p.next()
+		p.expression()
+		if p.accept(",") {
+			p.expression()
+		}
+	case is(tok, "import"):
+		p.next()
+		for {
+			p.dottedName()
+			if p.accept("as") {
+				p.name()
+			}
+			if !p.accept(",") {
+				break
+			}
+		}
+	case is(tok, "from"):
+		p.importFrom()
+	case is(tok, "type") && p.peekAt(1).kind == tokName && (is(p.peekAt(2), "=") || is(p.peekAt(2), "[")):
+		p.next()
+		p.name()
+		p.typeParams()
+		p.expect("=")
+		p.expression()
+	default:
+		p.expressionStatement()
+	}
+}
+
+func (p *parser) dottedName() {
+	p.name()
+	for p.accept(".") {
+		p.name()
+	}
+}
+
+func (p *parser) importFrom() {
+	tok := p.next()
+	relative := false
+	for p.accept(".") || p.accept("...") {
+		relative = true
+	}
+	future := !relative && p.at("__future__") && !is(p.peekAt(1), ".")
+	if future && !p.futureAllowed {
+		p.fail(tok, "from __future__ imports must occur at the beginning of the file")
+	}
+	if !relative || !p.at("import") {
+		p.dottedName()
+	}
+	p.expect("import")
+	if p.accept("*") {
+		if future {
+			p.fail(tok, "future feature * is not defined")
+		}
+		return
+	}
+	parenthesized := p.accept("(")
+	for {
+		name := p.name()
+		if future && !futureFeatures[name.val] {
+			p.fail(tok, "future feature %s is not defined", name.val)
+		}
+		if p.accept("as") {
+			p.name()
+		}
+		if !p.accept(",") {
+			break
+		}
+		if parenthesized && p.at(")") {
+			break
+		}
+		if !parenthesized && !p.atName() {
+			p.fail(p.peek(), "trailing comma not allowed without surrounding parentheses")
+		}
+	}
+	if parenthesized {
+		p.expect(")")
+	}
+}
+
+func (p *parser) expressionStatement() {
+	var e expr
+	if p.at("yield") {
+		e = p.yieldExpression()
+	} else {
+		e = p.starExpressions()
+	}
+
+	switch tok := p.peek(); {
+	case is(tok, ":"):
+		p.assignable(e)
+		p.next()
+		p.expression()
+		if p.accept("=") {
+			p.assignedValue()
+		}
+	case tok.kind == tokOp && augAssign[tok.val]:
+		if !e.target || e.what != "" {
+			fail(e.tok.line, e.tok.col, "'%s' is an illegal expression for augmented assignment", e.what)
+		}
+		p.next()
+		p.assignedValue()
+	default:
+		for p.at("=") {
+			p.assignable(e)
+			p.next()
+			e = p.assignedValue()
+		}
+	}
+}
+
+func (p *parser) assignedValue() expr {
+	if p.at("yield") {
+		return p.yieldExpression()
+	}
+	return p.starExpressions()
+}
+
+// assignable reports an error if e cannot be assigned to.
+func (p *parser) assignable(e expr) {
+	if !e.target {
+		fail(e.tok.line, e.tok.col, "cannot assign to %s", e.what)
+	}
+}
+
+// targets parses a comma-separated list of assignment targets, as in a for
+// statement, which ends before the keyword end.
+func (p *parser) targets(end string) {
+	for {
+		p.target()
+		if !p.accept(",") || p.at(end) {
+			break
+		}
+	}
+}
+
+// target parses an assignment target. Targets stop short of comparisons, so
+// the in of a for statement is not taken as part of one.
+func (p *parser) target() {
+	if p.accept("*") {
+		p.assignable(p.bitwiseOr())
+		return
+	}
+	p.assignable(p.bitwiseOr())
+}
+
+func (p *parser) yieldExpression() expr {
+	tok := p.next()
+	if !p.inFunction() {
+		p.fail(tok, "'yield' outside function")
+	}
+	if p.accept("from") {
+		p.expression()
+	} else if p.startsExpression() {
+		p.starExpressions()
+	}
+	return valueExpr(tok, "yield expression")
+}
+
+// startsExpression reports whether the next token can start an expression.
+func (p *parser) startsExpression() bool {
+	tok := p.peek()
+	switch tok.kind {
+	case tokName:
+		switch tok.val {
+		case "not", "lambda", "await", "True", "False", "None":
+			return true
+		}
+		return !keywords[tok.val]
+	case tokNumber, tokString:
+		return true
+	case tokOp:
+		switch tok.val {
+		case "(", "[", "{", "-", "+", "~", "*", "...":
+			return true
+		}
+	}
+	return false
+}
+
+// starExpressions parses one or more expressions, which may be starred,
+// forming a tuple if there is more than one or a trailing comma.
+func (p *parser) starExpressions() expr {
+	first := p.starExpression()
+	if !p.at(",") {
+		if first.starred {
+			if p.at("=") {
+				fail(first.tok.line, first.tok.col, "starred assignment target must be in a list or tuple")
+			}
+			fail(first.tok.line, first.tok.col, "can't use starred expression here")
+		}
+		return first
+	}
+	tuple := expr{tok: first.tok, target: first.target, what: "tuple"}
+	if !first.target {
+		tuple.what = first.what
+	}
+	for p.accept(",") && p.startsExpression() {
+		e := p.starExpression()
+		if tuple.target && !e.target {
+			tuple = expr{tok: e.tok, what: e.what}
+		}
+	}
+	if tuple.target {
+		tuple.what = "tuple"
+	}
+	return tuple
+}
+
+func (p *parser) starExpression() expr {
+	if tok := p.peek(); p.accept("*") {
+		return p.starred(tok)
+	}
+	return p.expression()
+}
+
+// starred parses the operand of a starred expression whose star, at tok, has
+// been consumed.
+func (p *parser) starred(tok token) expr {
+	e := p.bitwiseOr()
+	e.tok = tok
+	e.starred = true
+	if e.target {
+		e.what = "starred"
+	}
+	return e
+}
+
+func (p *parser) starNamedExpressions() {
+	for {
+		p.starNamedExpression()
+		if !p.accept(",") || !p.startsExpression() {
+			break
+		}
+	}
+}
+
+func (p *parser) starNamedExpression() expr {
+	if tok := p.peek(); p.accept("*") {
+		return p.starred(tok)
+	}
+	return p.namedExpression()
+}
+
+// namedExpression parses an expression that may be an assignment expression
+// (name := value).
+func (p *parser) namedExpression() expr {
+	if tok := p.peek(); p.atName() && is(p.peekAt(1), ":=") {
+		p.next()
+		p.next()
+		p.expression()
+		return valueExpr(tok, "named expression")
+	}
+	return p.expression()
+}
+
+func (p *parser) expression() expr {
+	tok := p.peek()
+	if p.accept("lambda") {
+		p.params(":", false)
+		p.expect(":")
+		outer, parens := p.scope, p.parens
+		p.scope, p.parens = scope{kind: functionScope}, 0
+		p.expression()
+		p.scope, p.parens = outer, parens
+		return valueExpr(tok, "lambda")
+	}
+	e := p.disjunction()
+	if p.accept("if") {
+		p.disjunction()
+		if !p.accept("else") {
+			p.fail(p.peek(), "expected 'else' after 'if' expression")
+		}
+		p.expression()
+		return valueExpr(tok, "conditional expression")
+	}
+	return e
+}
+
+func (p *parser) disjunction() expr {
+	e := p.conjunction()
+	for p.accept("or") {
+		p.conjunction()
+		e = valueExpr(e.tok, "expression")
+	}
+	return e
+}
+
+func (p *parser) conjunction() expr {
+	e := p.inversion()
+	for p.accept("and") {
+		p.inversion()
+		e = valueExpr(e.tok, "expression")
+	}
+	return e
+}
+
+func (p *parser) inversion() expr {
+	if tok := p.peek(); p.accept("not") {
+		p.inversion()
+		return valueExpr(tok, "expression")
+	}
+	return p.comparison()
+}
+
+func (p *parser) comparison() expr {
+	e := p.bitwiseOr()
+	for {
+		tok := p.peek()
+		switch {
+		case tok.kind == tokOp && comparisons[tok.val], is(tok, "in"):
+			p.next()
+		case is(tok, "not") && is(p.peekAt(1), "in"):
+			p.next()
+			p.next()
+		case is(tok, "is"):
+			p.next()
+			p.accept("not")
+		default:
+			return e
+		}
+		p.bitwiseOr()
+		e = valueExpr(e.tok, "comparison")
+	}
+}
+
+// binaryLevels are the binary operators from lowest to highest precedence,
+// below the unary operators.
+var binaryLevels = [][]string{
+	{"|"},
+	{"^"},
+	{"&"},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "//", "%", "@"},
+}
+
+func (p *parser) bitwiseOr() expr {
+	return p.binary(0)
+}
+
+func (p *parser) binary(level int) expr {
+	if level == len(binaryLevels) {
+		return p.factor()
+	}
+	e := p.binary(level + 1)
+	for {
+		matched := false
+		for _, op := range binaryLevels[level] {
+			if p.accept(op) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return e
+		}
+		p.binary(level + 1)
+		e = valueExpr(e.tok, "expression")
+	}
+}
+
+func (p *parser) factor() expr {
+	if tok := p.peek(); p.accept("+") || p.accept("-") || p.accept("~") {
+		p.factor()
+		return valueExpr(tok, "expression")
+	}
+	return p.power()
+}
+
+func (p *parser) power() expr {
+	tok := p.peek()
+	var e expr
+	if p.accept("await") {
+		switch {
+		case p.scope.kind == asyncFunctionScope, p.parens > 0:
+		case p.scope.kind == functionScope:
+			p.fail(tok, "'await' outside async function")
+		default:
+			p.fail(tok, "'await' outside function")
+		}
+		p.primary()
+		e = valueExpr(tok, "await expression")
+	} else {
+		e = p.primary()
+	}
+	if p.accept("**") {
+		p.factor()
+		return valueExpr(tok, "expression")
+	}
+	return e
+}
+
+func (p *parser) primary() expr {
+	e := p.atom()
+	for {
+		switch {
+		case p.accept("."):
+			p.name()
+			e = targetExpr(e.tok)
+		case p.accept("("):
+			p.arguments()
+			e = valueExpr(e.tok, "function call")
+		case p.accept("["):
+			p.slices()
+			e = targetExpr(e.tok)
+		default:
+			return e
+		}
+	}
+}
+
+// arguments parses call arguments after the opening parenthesis, up to and
+// including the closing one.
+func (p *parser) arguments() {
+	p.parens++
+	defer func() { p.parens-- }()
+
+	// keyword describes the keyword argument or unpacking that no positional
+	// argument may follow.
+	var keyword string
+	var last token
+	for !p.at(")") {
+		tok := p.peek()
+		last = tok
+		switch {
+		case p.accept("*"):
+			if keyword == "keyword argument unpacking" {
+				p.fail(tok, "iterable argument unpacking follows keyword argument unpacking")
+			}
+			p.expression()
+		case p.accept("**"):
+			p.expression()
+			keyword = "keyword argument unpacking"
+		case p.atName() && is(p.peekAt(1), "="):
+			p.next()
+			p.next()
+			last = p.peek()
+			p.expression()
+			if keyword == "" {
+				keyword = "keyword argument"
+			}
+		default:
+			p.namedExpression()
+			if p.at("for") || p.at("async") {
+				p.comprehension()
+			} else if p.at("=") {
+				p.fail(tok, "expression cannot contain assignment, perhaps you meant \"==\"?")
+			}
+			if keyword != "" {
+				p.fail(tok, "positional argument follows %s", keyword)
+			}
+		}
+		if !p.accept(",") {
+			break
+		}
+	}
+	p.closing(")", last)
+}
+
+// dictValue parses the colon and value of a dictionary entry and returns
+// where the value starts.
+func (p *parser) dictValue() token {
+	colon := p.next()
+	if !p.startsExpression() {
+		p.fail(colon, "expression expected after dictionary key and ':'")
+	}
+	value := p.peek()
+	p.expression()
+	return value
+}
+
+// closing consumes the closing bracket of a call or display whose last
+// element starts at last. An expression in its place most likely follows a
+// missing comma, which is reported at the element before it, like CPython.
+func (p *parser) closing(closer string, last token) {
+	if !p.at(closer) && p.startsExpression() {
+		p.fail(last, "invalid syntax. Perhaps you forgot a comma?")
+	}
+	p.expect(closer)
+}
+
+// slices parses a subscript after the opening bracket, up to and including
+// the closing one.
+func (p *parser) slices() {
+	for {
+		last := p.peek()
+		if p.at("*") {
+			p.starExpression()
+		} else {
+			if !p.at(":") {
+				p.namedExpression()
+			}
+			if p.accept(":") {
+				if !p.at("]") && !p.at(",") && !p.at(":") {
+					p.expression()
+				}
+				if p.accept(":") && !p.at("]") && !p.at(",") {
+					p.expression()
+				}
+			}
+		}
+		if !p.accept(",") || p.at("]") {
+			p.closing("]", last)
+			return
+		}
+	}
+}
+
+// comprehension parses the for and if clauses of a comprehension.
+func (p *parser) comprehension() {
+	for p.at("for") || p.at("async") && is(p.peekAt(1), "for") {
+		p.accept("async")
+		p.next()
+		p.targets("in")
+		p.expect("in")
+		p.disjunction()
+		for p.accept("if") {
+			p.disjunction()
+		}
+	}
+}
+
+func (p *parser) atom() expr {
+	tok := p.peek()
+	switch tok.kind {
+	case tokName:
+		switch tok.val {
+		case "True", "False", "None":
+			p.next()
+			return valueExpr(tok, tok.val)
+		}
+		p.name()
+		return targetExpr(tok)
+	case tokNumber:
+		p.next()
+		return valueExpr(tok, "literal")
+	case tokString:
+		p.strings()
+		return valueExpr(tok, "literal")
+	case tokOp:
+		switch tok.val {
+		case "...":
+			p.next()
+			return valueExpr(tok, "ellipsis")
+		case "(":
+			return p.parenthesized()
+		case "[":
+			return p.list()
+		case "{":
+			return p.dictOrSet()
+		}
+	}
+	p.invalid()
+	panic("unreachable")
+}
+
+// strings parses adjacent string literals, which are concatenated.
+func (p *parser) strings() {
+	first := p.next()
+	for p.atKind(tokString) {
+		if tok := p.next(); tok.bytes != first.bytes {
+			p.fail(tok, "cannot mix bytes and nonbytes literals")
+		}
+	}
+}
+
+func (p *parser) parenthesized() expr {
+	tok := p.next()
+	p.parens++
+	defer func() { p.parens-- }()
+	if p.accept(")") {
+		return expr{tok: tok, target: true, what: "tuple"}
+	}
+	if p.at("yield") {
+		p.yieldExpression()
+		p.expect(")")
+		return valueExpr(tok, "yield expression")
+	}
+	first := p.starNamedExpression()
+	if p.at("for") || p.at("async") {
+		p.comprehension()
+		p.expect(")")
+		return valueExpr(tok, "generator expression")
+	}
+	if !p.at(",") {
+		p.closing(")", first.tok)
+		if first.starred {
+			fail(first.tok.line, first.tok.col, "can't use starred expression here")
+		}
+		return first
+	}
+	return p.sequence(tok, first, ")", "tuple")
+}
+
+func (p *parser) list() expr {
+	tok := p.next()
+	if p.accept("]") {
+		return expr{tok: tok, target: true, what: "list"}
+	}
+	first := p.starNamedExpression()
+	if p.at("for") || p.at("async") {
+		p.comprehension()
+		p.expect("]")
+		return valueExpr(tok, "list comprehension")
+	}
+	return p.sequence(tok, first, "]", "list")
+}
+
+// sequence parses the rest of a tuple or list display after its first
+// element, up to and including closer. It is a target if all its elements
+// are.
+func (p *parser) sequence(tok token, first expr, closer, what string) expr {
+	e := expr{tok: tok, target: first.target, what: what}
+	if !first.target {
+		e = valueExpr(first.tok, first.what)
+	}
+	last := first.tok
+	for p.accept(",") && !p.at(closer) {
+		last = p.peek()
+		if elem := p.starNamedExpression(); e.target && !elem.target {
+			e = valueExpr(elem.tok, elem.what)
+		}
+	}
+	p.closing(closer, last)
+	return e
+}
+
+func (p *parser) dictOrSet() expr {
+	tok := p.next()
+	if p.accept("}") {
+		return valueExpr(tok, "dict literal")
+	}
+
+	dict := false
+	last := p.peek()
+	if p.accept("**") {
+		dict = true
+		p.bitwiseOr()
+	} else {
+		key := p.starNamedExpression()
+		if p.at(":") {
+			if key.starred {
+				p.invalid()
+			}
+			dict = true
+			last = p.dictValue()
+		}
+	}
+	if p.at("for") || p.at("async") {
+		p.comprehension()
+		p.expect("}")
+		if dict {
+			return valueExpr(tok, "dict comprehension")
+		}
+		return valueExpr(tok, "set comprehension")
+	}
+
+	for p.accept(",") && !p.at("}") {
+		last = p.peek()
+		switch {
+		case dict && p.accept("**"):
+			p.bitwiseOr()
+		case dict:
+			p.expression()
+			if !p.at(":") {
+				p.fail(last, "':' expected after dictionary key")
+			}
+			last = p.dictValue()
+		default:
+			p.starNamedExpression()
+		}
+	}
+	p.closing("}", last)
+	if dict {
+		return valueExpr(tok, "dict literal")
+	}
+	return valueExpr(tok, "set display")
+}
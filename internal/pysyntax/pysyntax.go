@@ -0,0 +1,58 @@
+// Package pysyntax checks that source code is syntactically valid Python 3,
+// without a Python interpreter.
+//
+// The checker tokenizes the source the way CPython does, including
+// indentation, brackets, string prefixes and f-strings, and parses the tokens
+// against the Python 3 grammar, rejecting what makes a generated file fail to
+// import: unterminated strings, unbalanced brackets, bad indentation,
+// malformed statements and expressions, and return, yield, break and continue
+// outside a function or loop.
+//
+// It does not build a syntax tree, so it accepts some programs CPython
+// rejects in its later compilation passes, such as a function with two
+// parameters of the same name, a keyword argument repeated in a call or a
+// name assigned before its global declaration. The expressions in f-string
+// replacement fields are scanned for balanced brackets and quotes but not
+// parsed.
+package pysyntax
+
+import "fmt"
+
+// Error is a syntax error at a position in the source. Line and Col are
+// 1-based, and Col counts characters rather than bytes.
+type Error struct {
+	Line int
+	Col  int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Col, e.Msg)
+}
+
+// Check reports the first syntax error in src, as an *Error, or nil if src is
+// valid Python.
+func Check(src string) error {
+	toks := scan(src)
+	p := &parser{toks: toks}
+	if err := p.parse(); err != nil {
+		if last := toks[len(toks)-1]; last.kind == tokError && err.Msg != "unexpected indent" {
+			switch last.errKind {
+			case errScan:
+				return last.err
+			case errUnclosed:
+				if err.Line > last.err.Line {
+					return last.err
+				}
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// fail aborts scanning or parsing with a syntax error, which scan and parse
+// recover.
+func fail(line, col int, format string, args ...any) {
+	panic(&Error{Line: line, Col: col, Msg: fmt.Sprintf(format, args...)})
+}
@@ -0,0 +1,177 @@
+package pysyntax
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckValid(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{name: "empty", src: ""},
+		{name: "dag", src: `from __future__ import annotations
+
+"""Orders DAG."""
+
+from datetime import datetime
+
+from airflow import DAG
+from airflow.operators.bash import BashOperator
+
+with DAG(
+    dag_id="orders",
+    start_date=datetime(2024, 1, 1),
+    schedule="@daily",
+    catchup=False,
+    tags=["orders", "generated"],
+) as dag:
+    extract = BashOperator(task_id="extract", bash_command="echo extract")
+    load = BashOperator(task_id="load", bash_command="echo load")
+    extract >> load
+`},
+		{name: "f-strings", src: `name = "orders"
+width = 10
+a = f"{name!r:>{width}}"
+b = f'{"nested"}' f"{ {'k': 1}['k'] }"
+c = rf"\d+{name}"
+d = f"{name=}"
+e = f"{'a' if name else "b"}"
+f = f"""{
+    name
+}"""
+g = f"{{literal}} {width:#x}"
+`},
+		{name: "match", src: `match command.split():
+    case [action]:
+        pass
+    case ["go", direction] | ["move", direction]:
+        pass
+    case {"x": x, **rest}:
+        pass
+    case Point(x=0, y=0):
+        pass
+    case [1, *others] if others:
+        pass
+    case _:
+        pass
+match = 1
+case = match
+`},
+		{name: "walrus", src: `if (n := len(items)) > 10:
+    print(n)
+data = [y for x in values if (y := f(x)) is not None]
+while chunk := read():
+    pass
+`},
+		{name: "PEP 695", src: `type Point[T] = tuple[T, T]
+type IntList = list[int]
+
+
+def first[T](xs: list[T]) -> T:
+    return xs[0]
+
+
+class Box[T: (int, str), *Ts, **P]:
+    pass
+type = 1
+`},
+		{name: "except*", src: `try:
+    run()
+except* ValueError as e:
+    pass
+except* (TypeError, KeyError):
+    pass
+else:
+    pass
+finally:
+    pass
+`},
+		{name: "functions", src: `import functools
+
+
+@functools.cache
+def f(a, /, b: int = 1, *args, c, d=2, **kwargs) -> None:
+    global counter
+    counter += 1
+    yield from g()
+    return
+
+
+async def main():
+    async with lock:
+        async for item in stream():
+            await item
+    return [x async for x in stream()]
+
+
+def keyword_only(*, a):
+    def inner():
+        nonlocal a
+        a = lambda x, *y, **z: (x, y, z)
+    return inner
+
+
+first, *rest = [1, 2, 3]
+print(*rest, sep="", **options)
+del first, rest[0], options.sep
+`},
+		{name: "line continuation", src: "x = 1 + \\\n    2\ns = '''multi\nline'''\n"},
+		{name: "no trailing newline", src: "x = 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Check(tt.src); err != nil {
+				t.Errorf("Check: %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckInvalid(t *testing.T) {
+	tests := []struct {
+		src  string
+		want Error
+	}{
+		{src: "x = (1,\n", want: Error{Line: 1, Col: 5, Msg: "'(' was never closed"}},
+		{src: "x = 'é' + (\n", want: Error{Line: 1, Col: 11, Msg: "'(' was never closed"}},
+		{src: "x = )\n", want: Error{Line: 1, Col: 5, Msg: "unmatched ')'"}},
+		{src: "x = 'abc\n", want: Error{Line: 1, Col: 5, Msg: "unterminated string literal (detected at line 1)"}},
+		{src: "s = '''abc\n\n\n", want: Error{Line: 1, Col: 5, Msg: "unterminated triple-quoted string literal (detected at line 3)"}},
+		{src: "def f(:\n    pass\n", want: Error{Line: 1, Col: 7, Msg: "invalid syntax"}},
+		{src: "x = 1 +\n", want: Error{Line: 1, Col: 8, Msg: "invalid syntax"}},
+		{src: "import a.\n", want: Error{Line: 1, Col: 10, Msg: "invalid syntax"}},
+		{src: "if x\n    pass\n", want: Error{Line: 1, Col: 5, Msg: "expected ':'"}},
+		{src: "if True:\npass\n", want: Error{Line: 2, Col: 1, Msg: "expected an indented block after 'if' statement on line 1"}},
+		{src: "def f():\n  x = 1\n    y = 2\n", want: Error{Line: 3, Col: 5, Msg: "unexpected indent"}},
+		{src: "x = {1: 2, 3}\n", want: Error{Line: 1, Col: 12, Msg: "':' expected after dictionary key"}},
+		{src: "a, b += 1\n", want: Error{Line: 1, Col: 1, Msg: "'tuple' is an illegal expression for augmented assignment"}},
+		{src: "f() = 1\n", want: Error{Line: 1, Col: 1, Msg: "cannot assign to function call"}},
+		{src: "del f()\n", want: Error{Line: 1, Col: 5, Msg: "cannot delete function call"}},
+		{src: "del *a\n", want: Error{Line: 1, Col: 5, Msg: "cannot delete starred"}},
+		{src: "f(**k, *a)\n", want: Error{Line: 1, Col: 8, Msg: "iterable argument unpacking follows keyword argument unpacking"}},
+		{src: "try:\n    pass\nexcept* :\n    pass\n", want: Error{Line: 3, Col: 9, Msg: "expected one or more exception types"}},
+		{src: "def f(*, **k): pass\n", want: Error{Line: 1, Col: 7, Msg: "named arguments must follow bare *"}},
+		{src: "def f(*): pass\n", want: Error{Line: 1, Col: 7, Msg: "named arguments must follow bare *"}},
+		{src: "return 1\n", want: Error{Line: 1, Col: 1, Msg: "'return' outside function"}},
+		{src: "class C:\n    return 1\n", want: Error{Line: 2, Col: 5, Msg: "'return' outside function"}},
+		{src: "yield 1\n", want: Error{Line: 1, Col: 1, Msg: "'yield' outside function"}},
+		{src: "for x in y:\n    pass\nelse:\n  break\n", want: Error{Line: 4, Col: 3, Msg: "'break' outside loop"}},
+		{src: "while True:\n    continue\ncontinue\n", want: Error{Line: 3, Col: 1, Msg: "'continue' not properly in loop"}},
+		{src: "nonlocal x\n", want: Error{Line: 1, Col: 1, Msg: "nonlocal declaration not allowed at module level"}},
+		{src: "x = 1\nfrom __future__ import annotations\n", want: Error{Line: 2, Col: 1, Msg: "from __future__ imports must occur at the beginning of the file"}},
+		{src: "from __future__ import braces\n", want: Error{Line: 1, Col: 1, Msg: "future feature braces is not defined"}},
+	}
+	for _, tt := range tests {
+		err := Check(tt.src)
+		var got *Error
+		if !errors.As(err, &got) {
+			t.Errorf("Check(%q) = %v, want %v", tt.src, err, &tt.want)
+			continue
+		}
+		if *got != tt.want {
+			t.Errorf("Check(%q) = %v, want %v", tt.src, got, &tt.want)
+		}
+	}
+}
@@ -0,0 +1,685 @@
+package pysyntax
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokName
+	tokNumber
+	tokString
+	tokOp
+	tokNewline
+	tokIndent
+	tokDedent
+	// tokError ends the tokens of source that could not be scanned to the
+	// end.
+	tokError
+)
+
+// errorKind tells how a scanning error interacts with a parse error found
+// earlier in the source. Like CPython, which scans the rest of the source
+// after a parse error, most scanning errors take precedence.
+type errorKind int
+
+const (
+	// errScan takes precedence over an earlier parse error.
+	errScan errorKind = iota
+	// errUnclosed, a bracket left open at the end of the source, takes
+	// precedence over parse errors on later lines.
+	errUnclosed
+	// errLate is only reported if the parser reaches it. Indentation and
+	// escape sequence errors are of this kind.
+	errLate
+)
+
+type token struct {
+	kind tokenKind
+	val  string
+	line int
+	col  int
+	// bytes is set on string literals with a b prefix.
+	bytes bool
+	// err and errKind describe the error of a tokError.
+	err     *Error
+	errKind errorKind
+}
+
+// operators lists the operator and delimiter tokens, longest first so the
+// scanner takes the longest match.
+var operators = []string{
+	"**=", "//=", ">>=", "<<=", "...",
+	"->", ":=", "**", "//", "<<", ">>", "<=", ">=", "==", "!=",
+	"+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", "@=",
+	"+", "-", "*", "/", "%", "@", "&", "|", "^", "~", "<", ">",
+	"(", ")", "[", "]", "{", "}", ",", ":", ";", ".", "=",
+}
+
+var closers = map[rune]rune{')': '(', ']': '[', '}': '{'}
+
+// tabSize is the tab stop CPython uses to measure indentation.
+const tabSize = 8
+
+// scanner splits Python source into tokens, emitting NEWLINE at the end of
+// each logical line and INDENT and DEDENT where the indentation changes.
+type scanner struct {
+	src  []rune
+	pos  int
+	line int
+	col  int
+
+	// indents and altIndents are the open indentation levels, measured with
+	// tabs to the next multiple of tabSize and with tabs as one column. The
+	// two disagreeing means tabs and spaces are mixed ambiguously.
+	indents    []int
+	altIndents []int
+	// brackets are the open brackets. Newlines inside them are ignored.
+	brackets []token
+	// lineStart is set when the next character starts a logical line.
+	lineStart bool
+	// errKind is the kind of the scanning error being reported.
+	errKind errorKind
+
+	toks []token
+}
+
+// scan returns the tokens of src. If src cannot be scanned to the end, the
+// tokens end with a tokError instead of tokEOF.
+func scan(src string) (toks []token) {
+	s := &scanner{
+		line:       1,
+		col:        1,
+		indents:    []int{0},
+		altIndents: []int{0},
+		lineStart:  true,
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err, ok := r.(*Error)
+			if !ok {
+				panic(r)
+			}
+			toks = append(s.toks, token{kind: tokError, line: err.Line, col: err.Col, err: err, errKind: s.errKind})
+		}
+	}()
+
+	if !utf8.ValidString(src) {
+		line, col := 1, 1
+		for i, r := range src {
+			if r == utf8.RuneError {
+				if _, size := utf8.DecodeRuneInString(src[i:]); size == 1 {
+					break
+				}
+			}
+			if r == '\n' {
+				line, col = line+1, 1
+			} else {
+				col++
+			}
+		}
+		fail(line, col, "invalid UTF-8")
+	}
+	src = strings.TrimPrefix(src, "\ufeff")
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	src = strings.ReplaceAll(src, "\r", "\n")
+	s.src = []rune(src)
+
+	s.run()
+	return s.toks
+}
+
+func (s *scanner) peek(n int) rune {
+	if s.pos+n >= len(s.src) {
+		return 0
+	}
+	return s.src[s.pos+n]
+}
+
+func (s *scanner) advance() {
+	if s.src[s.pos] == '\n' {
+		s.line++
+		s.col = 1
+	} else {
+		s.col++
+	}
+	s.pos++
+}
+
+func (s *scanner) emit(kind tokenKind, val string, line, col int) {
+	s.toks = append(s.toks, token{kind: kind, val: val, line: line, col: col})
+}
+
+func (s *scanner) run() {
+	for {
+		if s.lineStart && len(s.brackets) == 0 {
+			if !s.indentation() {
+				break
+			}
+		}
+		if s.pos >= len(s.src) {
+			break
+		}
+
+		c := s.src[s.pos]
+		line, col := s.line, s.col
+		switch {
+		case c == ' ' || c == '\t' || c == '\f':
+			s.advance()
+		case c == '#':
+			s.skipComment()
+		case c == '\n':
+			if len(s.brackets) == 0 {
+				s.emit(tokNewline, "", line, col)
+				s.lineStart = true
+			}
+			s.advance()
+		case c == '\\':
+			s.advance()
+			if s.pos >= len(s.src) {
+				fail(line, col, "unexpected EOF while parsing")
+			}
+			if s.src[s.pos] != '\n' {
+				fail(line, col, "unexpected character after line continuation character")
+			}
+			s.advance()
+		case c == '\x00':
+			fail(line, col, "source code cannot contain null bytes")
+		case isDigit(c) || c == '.' && isDigit(s.peek(1)):
+			s.number()
+		case c == '"' || c == '\'':
+			s.str("", line, col)
+		case isIdentStart(c):
+			start := s.pos
+			for s.pos < len(s.src) && isIdentChar(s.src[s.pos]) {
+				s.advance()
+			}
+			name := string(s.src[start:s.pos])
+			if q := s.peek(0); (q == '"' || q == '\'') && isStringPrefix(name) {
+				s.str(name, line, col)
+			} else {
+				s.emit(tokName, name, line, col)
+			}
+		default:
+			s.operator(c, line, col)
+		}
+	}
+
+	if len(s.brackets) > 0 {
+		open := s.brackets[len(s.brackets)-1]
+		s.errKind = errUnclosed
+		fail(open.line, open.col, "'%s' was never closed", open.val)
+	}
+	if n := len(s.toks); n > 0 && s.toks[n-1].kind != tokNewline && s.toks[n-1].kind != tokDedent {
+		s.emit(tokNewline, "", s.line, s.col)
+	}
+	for len(s.indents) > 1 {
+		s.indents = s.indents[:len(s.indents)-1]
+		s.emit(tokDedent, "", s.line, s.col)
+	}
+	s.emit(tokEOF, "", s.line, s.col)
+}
+
+// indentation measures the indentation of the line at s.pos, skipping blank
+// and comment-only lines, and emits INDENT or DEDENT tokens for it. It
+// returns false at the end of the source.
+func (s *scanner) indentation() bool {
+	for {
+		col, alt := 0, 0
+		// As in CPython, indentation continues across line continuations,
+		// up to the first one found after some indentation.
+		contCol, contAlt := 0, 0
+	measure:
+		for ; s.pos < len(s.src); s.advance() {
+			switch s.src[s.pos] {
+			case '\\':
+				if s.peek(1) != '\n' {
+					break measure
+				}
+				if contCol == 0 {
+					contCol, contAlt = col, alt
+				}
+				s.advance()
+			case ' ':
+				col++
+				alt++
+			case '\t':
+				col = (col/tabSize + 1) * tabSize
+				alt++
+			case '\f':
+				col, alt = 0, 0
+			default:
+				break measure
+			}
+		}
+		if contCol != 0 {
+			col, alt = contCol, contAlt
+		}
+		if s.pos >= len(s.src) {
+			return false
+		}
+		switch s.src[s.pos] {
+		case '#':
+			s.skipComment()
+			if s.pos >= len(s.src) {
+				return false
+			}
+			s.advance()
+			continue
+		case '\n':
+			s.advance()
+			continue
+		}
+
+		s.lineStart = false
+		top := len(s.indents) - 1
+		switch {
+		case col == s.indents[top]:
+			if alt != s.altIndents[top] {
+				s.lateFail(s.line, s.col, "inconsistent use of tabs and spaces in indentation")
+			}
+		case col > s.indents[top]:
+			if alt <= s.altIndents[top] {
+				s.lateFail(s.line, s.col, "inconsistent use of tabs and spaces in indentation")
+			}
+			s.indents = append(s.indents, col)
+			s.altIndents = append(s.altIndents, alt)
+			s.emit(tokIndent, "", s.line, s.col)
+		default:
+			for len(s.indents) > 1 && col < s.indents[len(s.indents)-1] {
+				s.indents = s.indents[:len(s.indents)-1]
+				s.altIndents = s.altIndents[:len(s.altIndents)-1]
+				s.emit(tokDedent, "", s.line, s.col)
+			}
+			top = len(s.indents) - 1
+			if col != s.indents[top] {
+				s.lateFail(s.line, s.col, "unindent does not match any outer indentation level")
+			}
+			if alt != s.altIndents[top] {
+				s.lateFail(s.line, s.col, "inconsistent use of tabs and spaces in indentation")
+			}
+		}
+		return true
+	}
+}
+
+// lateFail reports a scanning error of kind errLate.
+func (s *scanner) lateFail(line, col int, format string, args ...any) {
+	s.errKind = errLate
+	fail(line, col, format, args...)
+}
+
+func (s *scanner) skipComment() {
+	for s.pos < len(s.src) && s.src[s.pos] != '\n' {
+		s.advance()
+	}
+}
+
+func (s *scanner) operator(c rune, line, col int) {
+	for _, op := range operators {
+		if s.hasPrefix(op) {
+			for range op {
+				s.advance()
+			}
+			s.bracket(c, op, line, col)
+			s.emit(tokOp, op, line, col)
+			return
+		}
+	}
+	if c < utf8.RuneSelf && unicode.IsPrint(c) {
+		fail(line, col, "invalid syntax")
+	}
+	fail(line, col, "invalid character '%c' (U+%04X)", c, c)
+}
+
+// bracket tracks the opening and closing brackets.
+func (s *scanner) bracket(c rune, op string, line, col int) {
+	switch op {
+	case "(", "[", "{":
+		s.brackets = append(s.brackets, token{kind: tokOp, val: op, line: line, col: col})
+	case ")", "]", "}":
+		if len(s.brackets) == 0 {
+			fail(line, col, "unmatched '%s'", op)
+		}
+		open := s.brackets[len(s.brackets)-1]
+		if open.val != string(closers[c]) {
+			if open.line != line {
+				fail(line, col, "closing parenthesis '%s' does not match opening parenthesis '%s' on line %d", op, open.val, open.line)
+			}
+			fail(line, col, "closing parenthesis '%s' does not match opening parenthesis '%s'", op, open.val)
+		}
+		s.brackets = s.brackets[:len(s.brackets)-1]
+	}
+}
+
+func (s *scanner) hasPrefix(prefix string) bool {
+	i := s.pos
+	for _, r := range prefix {
+		if i >= len(s.src) || s.src[i] != r {
+			return false
+		}
+		i++
+	}
+	return true
+}
+
+// number scans a numeric literal.
+func (s *scanner) number() {
+	start, line, col := s.pos, s.line, s.col
+	digits := func(valid func(rune) bool) {
+		for s.pos < len(s.src) && (valid(s.src[s.pos]) || s.src[s.pos] == '_') {
+			s.advance()
+		}
+	}
+
+	radix := false
+	if s.src[s.pos] == '0' {
+		switch unicode.ToLower(s.peek(1)) {
+		case 'x':
+			s.advance()
+			s.advance()
+			digits(isHexDigit)
+			radix = true
+		case 'o':
+			s.advance()
+			s.advance()
+			digits(func(r rune) bool { return r >= '0' && r <= '7' })
+			radix = true
+		case 'b':
+			s.advance()
+			s.advance()
+			digits(func(r rune) bool { return r == '0' || r == '1' })
+			radix = true
+		}
+	}
+	if radix {
+		if s.pos-start == 2 || s.src[s.pos-1] == '_' {
+			fail(line, col, "invalid %s literal", radixName(s.src[start+1]))
+		}
+		if s.pos < len(s.src) && isIdentChar(s.src[s.pos]) {
+			fail(line, col, "invalid %s literal", radixName(s.src[start+1]))
+		}
+		s.emit(tokNumber, string(s.src[start:s.pos]), line, col)
+		return
+	}
+
+	digits(isDigit)
+	integer := true
+	if s.peek(0) == '.' {
+		integer = false
+		s.advance()
+		digits(isDigit)
+	}
+	if r := s.peek(0); r == 'e' || r == 'E' {
+		integer = false
+		s.advance()
+		if r := s.peek(0); r == '+' || r == '-' {
+			s.advance()
+		}
+		if !isDigit(s.peek(0)) {
+			fail(line, col, "invalid decimal literal")
+		}
+		digits(isDigit)
+	}
+	if r := s.peek(0); r == 'j' || r == 'J' {
+		integer = false
+		s.advance()
+	}
+
+	lit := string(s.src[start:s.pos])
+	if strings.HasSuffix(lit, "_") || strings.Contains(lit, "__") || strings.Contains(lit, "_.") || strings.Contains(lit, "._") {
+		fail(line, col, "invalid decimal literal")
+	}
+	if s.pos < len(s.src) && isIdentChar(s.src[s.pos]) && !s.keywordFollows() {
+		fail(line, col, "invalid decimal literal")
+	}
+	if integer && len(lit) > 1 && lit[0] == '0' && strings.Trim(lit, "0_") != "" {
+		fail(line, col, "leading zeros in decimal integer literals are not permitted; use an 0o prefix for octal integers")
+	}
+	s.emit(tokNumber, lit, line, col)
+}
+
+// keywordFollows reports whether a keyword that CPython still accepts
+// directly after a number, as in 1if x else 2, starts at s.pos.
+func (s *scanner) keywordFollows() bool {
+	for _, kw := range []string{"and", "else", "for", "if", "in", "is", "not", "or"} {
+		if s.hasPrefix(kw) {
+			next := s.pos + len(kw)
+			if next >= len(s.src) || !isIdentChar(s.src[next]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func radixName(r rune) string {
+	switch unicode.ToLower(r) {
+	case 'x':
+		return "hexadecimal"
+	case 'o':
+		return "octal"
+	default:
+		return "binary"
+	}
+}
+
+// str scans a string literal whose prefix, if any, has been consumed.
+// Unterminated strings are reported at line and col, where the literal
+// starts.
+func (s *scanner) str(prefix string, line, col int) {
+	start := s.pos - len([]rune(prefix))
+	s.quoted(prefix, line, col)
+	s.toks = append(s.toks, token{
+		kind:  tokString,
+		val:   string(s.src[start:s.pos]),
+		line:  line,
+		col:   col,
+		bytes: strings.ContainsAny(prefix, "bB"),
+	})
+}
+
+// quoted scans a string literal with the given prefix from its opening
+// quote. In f-strings, replacement fields may contain nested strings of any
+// quote, as Python 3.12 allows.
+func (s *scanner) quoted(prefix string, line, col int) {
+	prefix = strings.ToLower(prefix)
+	fstring := strings.ContainsRune(prefix, 'f')
+	raw := strings.ContainsRune(prefix, 'r')
+	bytes := strings.ContainsRune(prefix, 'b')
+
+	q := s.src[s.pos]
+	triple := s.peek(1) == q && s.peek(2) == q
+	unterminated := func() {
+		if triple {
+			// As in CPython, the newline ending the last line does not
+			// start another.
+			detected := s.line
+			if s.pos >= len(s.src) && s.src[len(s.src)-1] == '\n' {
+				detected--
+			}
+			fail(line, col, "unterminated triple-quoted string literal (detected at line %d)", detected)
+		}
+		fail(line, col, "unterminated string literal (detected at line %d)", s.line)
+	}
+
+	if triple {
+		s.advance()
+		s.advance()
+	}
+	s.advance()
+	for {
+		if s.pos >= len(s.src) {
+			unterminated()
+		}
+		c := s.src[s.pos]
+		switch {
+		case c == '\\':
+			s.advance()
+			if s.pos >= len(s.src) {
+				continue
+			}
+			switch e := s.src[s.pos]; {
+			case fstring && (e == '{' || e == '}'):
+				// A backslash does not escape a brace.
+			case raw:
+				s.advance()
+			case e == 'x':
+				s.advance()
+				s.hexEscape(2, "\\xXX", line, col)
+			case e == 'u' && !bytes:
+				s.advance()
+				s.hexEscape(4, "\\uXXXX", line, col)
+			case e == 'U' && !bytes:
+				s.advance()
+				s.hexEscape(8, "\\UXXXXXXXX", line, col)
+			case e == 'N' && !bytes:
+				// A named Unicode escape, \N{...}, whose braces are not a
+				// replacement field.
+				s.advance()
+				if s.peek(0) != '{' {
+					s.lateFail(line, col, "malformed \\N character escape")
+				}
+				for s.pos < len(s.src) && s.src[s.pos] != '}' && s.src[s.pos] != q && s.src[s.pos] != '\n' {
+					s.advance()
+				}
+				if s.peek(0) != '}' {
+					s.lateFail(line, col, "malformed \\N character escape")
+				}
+				s.advance()
+			default:
+				s.advance()
+			}
+			continue
+		case c == '\n' && !triple:
+			unterminated()
+		case c == q:
+			if !triple {
+				s.advance()
+				return
+			}
+			if s.peek(1) == q && s.peek(2) == q {
+				s.advance()
+				s.advance()
+				s.advance()
+				return
+			}
+		case fstring && c == '{':
+			if s.peek(1) == '{' {
+				s.advance()
+				break
+			}
+			s.advance()
+			s.replacementField(unterminated)
+			continue
+		case fstring && c == '}':
+			if s.peek(1) != '}' {
+				fail(s.line, s.col, "f-string: single '}' is not allowed")
+			}
+			s.advance()
+		}
+		s.advance()
+	}
+}
+
+// hexEscape consumes the n hex digits of an escape sequence in the string
+// literal starting at line and col.
+func (s *scanner) hexEscape(n int, form string, line, col int) {
+	for i := 0; i < n; i++ {
+		if !isHexDigit(s.peek(0)) {
+			s.lateFail(line, col, "truncated %s escape", form)
+		}
+		s.advance()
+	}
+}
+
+// replacementField scans an f-string replacement field after its opening
+// brace, up to and including its closing brace.
+func (s *scanner) replacementField(unterminated func()) {
+	depth := 0
+	for {
+		if s.pos >= len(s.src) {
+			unterminated()
+		}
+		c := s.src[s.pos]
+		line, col := s.line, s.col
+		switch {
+		case c == '(' || c == '[' || c == '{':
+			depth++
+		case c == ')' || c == ']':
+			depth--
+		case c == '}':
+			if depth == 0 {
+				s.advance()
+				return
+			}
+			depth--
+		case c == ':' && depth == 0:
+			s.advance()
+			s.formatSpec(unterminated)
+			return
+		case c == '"' || c == '\'':
+			s.quoted("", line, col)
+			continue
+		case isIdentStart(c):
+			start := s.pos
+			for s.pos < len(s.src) && isIdentChar(s.src[s.pos]) {
+				s.advance()
+			}
+			if q := s.peek(0); (q == '"' || q == '\'') && isStringPrefix(string(s.src[start:s.pos])) {
+				s.quoted(string(s.src[start:s.pos]), line, col)
+			}
+			continue
+		}
+		s.advance()
+	}
+}
+
+// formatSpec scans the format specification of a replacement field, which
+// may itself contain replacement fields, up to and including the closing
+// brace of the field.
+func (s *scanner) formatSpec(unterminated func()) {
+	for {
+		if s.pos >= len(s.src) {
+			unterminated()
+		}
+		switch s.src[s.pos] {
+		case '{':
+			s.advance()
+			s.replacementField(unterminated)
+			continue
+		case '}':
+			s.advance()
+			return
+		}
+		s.advance()
+	}
+}
+
+func isStringPrefix(s string) bool {
+	switch strings.ToLower(s) {
+	case "r", "u", "b", "br", "rb", "f", "fr", "rf":
+		return true
+	}
+	return false
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+func isHexDigit(r rune) bool {
+	return isDigit(r) || r >= 'a' && r <= 'f' || r >= 'A' && r <= 'F'
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.Is(unicode.Nl, r) || unicode.Is(unicode.Other_ID_Start, r)
+}
+
+func isIdentChar(r rune) bool {
+	return isIdentStart(r) || unicode.IsDigit(r) || unicode.In(r, unicode.Mn, unicode.Mc, unicode.Pc, unicode.Other_ID_Continue)
+}
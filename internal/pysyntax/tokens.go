@@ -0,0 +1,134 @@
+package pysyntax
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// TokenKind is the kind of a Token.
+type TokenKind int
+
+const (
+	Name TokenKind = iota + 1
+	Number
+	String
+	Op
+	// Newline ends each logical line. Newlines inside brackets, blank lines
+	// and comments produce no tokens.
+	Newline
+	// Indent and Dedent mark where the indentation of a logical line
+	// increases or decreases.
+	Indent
+	Dedent
+)
+
+// Token is a token of Python source. Text is the token as written, including
+// the prefix and quotes of a string literal. Line and Col are 1-based, and
+// Col counts characters rather than bytes.
+type Token struct {
+	Kind TokenKind
+	Text string
+	Line int
+	Col  int
+}
+
+var tokenKinds = map[tokenKind]TokenKind{
+	tokName:    Name,
+	tokNumber:  Number,
+	tokString:  String,
+	tokOp:      Op,
+	tokNewline: Newline,
+	tokIndent:  Indent,
+	tokDedent:  Dedent,
+}
+
+// Tokens returns the tokens of src, or the first syntax error in src, as an
+// *Error, if src is not valid Python.
+func Tokens(src string) ([]Token, error) {
+	if err := Check(src); err != nil {
+		return nil, err
+	}
+	var out []Token
+	for _, t := range scan(src) {
+		if kind, ok := tokenKinds[t.kind]; ok {
+			out = append(out, Token{Kind: kind, Text: t.val, Line: t.line, Col: t.col})
+		}
+	}
+	return out, nil
+}
+
+// StringValue returns the value of the string literal text, as in a String
+// token. It reports false for f-strings and bytes literals, whose value is
+// not a plain string.
+func StringValue(text string) (string, bool) {
+	i := strings.IndexAny(text, `"'`)
+	if i < 0 {
+		return "", false
+	}
+	prefix := strings.ToLower(text[:i])
+	if strings.ContainsAny(prefix, "fb") {
+		return "", false
+	}
+	body := text[i:]
+	quote := body[:1]
+	if strings.HasPrefix(body, strings.Repeat(quote, 3)) && len(body) >= 6 {
+		quote = body[:3]
+	}
+	body = body[len(quote) : len(body)-len(quote)]
+	if strings.ContainsRune(prefix, 'r') || !strings.Contains(body, `\`) {
+		return body, true
+	}
+
+	var b strings.Builder
+	for len(body) > 0 {
+		c := body[0]
+		if c != '\\' || len(body) == 1 {
+			b.WriteByte(c)
+			body = body[1:]
+			continue
+		}
+		e := body[1]
+		body = body[2:]
+		switch e {
+		case '\n':
+		case '\\', '\'', '"':
+			b.WriteByte(e)
+		case 'a':
+			b.WriteByte('\a')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'v':
+			b.WriteByte('\v')
+		case 'x', 'u', 'U':
+			n := map[byte]int{'x': 2, 'u': 4, 'U': 8}[e]
+			r, err := strconv.ParseUint(body[:min(n, len(body))], 16, 32)
+			if err != nil || n > len(body) || r > utf8.MaxRune {
+				return "", false
+			}
+			b.WriteRune(rune(r))
+			body = body[n:]
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			n := 1
+			for n < 3 && n < len(body)+1 && body[n-1] >= '0' && body[n-1] <= '7' {
+				n++
+			}
+			r, _ := strconv.ParseUint(string(e)+body[:n-1], 8, 32)
+			b.WriteRune(rune(r))
+			body = body[n-1:]
+		default:
+			// Unknown escapes, and named ones, are kept as written.
+			b.WriteByte('\\')
+			b.WriteByte(e)
+		}
+	}
+	return b.String(), true
+}
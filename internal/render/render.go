@@ -0,0 +1,313 @@
+// Package render renders Jinja2 templates inside the provider, for targets
+// that are written without going through the backend service.
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nikolalohinski/gonja/v2"
+	"github.com/nikolalohinski/gonja/v2/exec"
+	"github.com/nikolalohinski/gonja/v2/loaders"
+	"github.com/nikolalohinski/gonja/v2/nodes"
+)
+
+// mainTemplate is the name the main template is registered under. It cannot
+// collide with template_files keys, which are relative paths.
+const mainTemplate = "/"
+
+// Options are the options of Render.
+type Options struct {
+	// StrictUndefined makes rendering fail with an *UndefinedError when the
+	// template uses a variable, attribute or item the context does not define,
+	// as with Jinja2's StrictUndefined, instead of rendering it as empty. Tests
+	// such as "is defined" and the default filter still accept it.
+	StrictUndefined bool
+}
+
+// Render renders template with the JSON object in contextJSON. files holds
+// additional templates, keyed by slash-separated relative path, that the
+// template can include, import or extend.
+func Render(template string, files map[string]string, contextJSON string, opts Options) (string, error) {
+	data, err := decodeContext(contextJSON)
+	if err != nil {
+		return "", err
+	}
+
+	cfg := gonja.DefaultConfig.Inherit()
+	cfg.StrictUndefined = opts.StrictUndefined
+	loader := &bundleLoader{main: template, files: files}
+	tpl, err := exec.NewTemplate(mainTemplate, cfg, loader, gonja.DefaultEnvironment)
+	if err != nil {
+		return "", err
+	}
+	out, err := tpl.ExecuteToString(exec.NewContext(data))
+	if err != nil && opts.StrictUndefined {
+		if uerr := undefinedError(err, loader); uerr != nil {
+			return "", uerr
+		}
+	}
+	return out, err
+}
+
+// UndefinedError is returned by Render with StrictUndefined when the template
+// uses something the context does not define.
+type UndefinedError struct {
+	// Name is the undefined expression, such as dag_id or task.retries.
+	Name string
+	// Template is the file of the bundle the use is in, or "" for the main
+	// template, and Line the line of the use. When the file cannot be told,
+	// as for an include by a computed name, they locate the statement that
+	// leads to it.
+	Template string
+	Line     int
+}
+
+func (e *UndefinedError) Error() string {
+	if e.Template != "" {
+		return fmt.Sprintf("%s, line %d: %s is undefined", e.Template, e.Line, e.Name)
+	}
+	return fmt.Sprintf("line %d: %s is undefined", e.Line, e.Name)
+}
+
+// gonja reports an undefined expression in the innermost of a chain of
+// messages, each outer one naming the statement it is nested in. The patterns
+// below match the parts of those messages that locate it, and
+// TestUndefinedErrorMessages pins their format.
+var (
+	// undefinedPattern matches gonja's errors for undefined names, attributes
+	// and items. The expression cannot span a colon, so an outer "unable to
+	// evaluate target" message is skipped for the inner one.
+	undefinedPattern = regexp.MustCompile(`(?i)unable to evaluate name "([^"]+)"|unable to evaluate ([^:]+?): (?:attribute '[^']*'|item .*) not found`)
+	// framePattern matches the line of a statement, and the include, macro
+	// call or block that leads to another template.
+	framePattern = regexp.MustCompile(` at line (\d+)|IncludeControlStructure\(Filename=(.*?) Line=\d+ Col=\d+\)|Unable to execute macro '([^']*)'|BlockControlStructure\(Line=(\d+) Col=(\d+)\)`)
+)
+
+// undefinedError returns the *UndefinedError for err, an error executing the
+// templates of loader in strict mode, or nil if err is about something else.
+func undefinedError(err error, loader *bundleLoader) *UndefinedError {
+	msg := err.Error()
+	loc := undefinedPattern.FindStringSubmatchIndex(msg)
+	if loc == nil {
+		return nil
+	}
+	uerr := &UndefinedError{}
+	if loc[2] >= 0 {
+		uerr.Name = msg[loc[2]:loc[3]]
+	} else {
+		uerr.Name = msg[loc[4]:loc[5]]
+	}
+
+	a := &analyzer{loader: loader, parsed: map[string]*nodes.Template{}}
+	template := a.root()
+	line := 0
+frames:
+	for _, m := range framePattern.FindAllStringSubmatch(msg[:loc[0]], -1) {
+		next, ok := "", true
+		switch {
+		case m[1] != "":
+			line, _ = strconv.Atoi(m[1])
+			continue
+		case m[2] != "":
+			var name string
+			name, ok = unquote(m[2])
+			next = resolveName(name)
+		case m[3] != "":
+			next, ok = a.macroTemplate(m[3])
+		default:
+			blockLine, _ := strconv.Atoi(m[4])
+			blockCol, _ := strconv.Atoi(m[5])
+			next, ok = a.blockTemplate(template, blockLine, blockCol)
+		}
+		if !ok {
+			break frames
+		}
+		template, line = next, 0
+	}
+	if line == 0 {
+		return nil
+	}
+	if template != mainTemplate {
+		uerr.Template = template
+	}
+	uerr.Line = line
+	return uerr
+}
+
+// unquote returns the value of s, a string literal as gonja prints it, and
+// false if s is some other expression.
+func unquote(s string) (string, bool) {
+	if len(s) < 2 || (s[0] != '\'' && s[0] != '"') || s[len(s)-1] != s[0] {
+		return "", false
+	}
+	return s[1 : len(s)-1], true
+}
+
+// root returns the template rendering starts from: the main template, or the
+// last of the templates it extends.
+func (a *analyzer) root() string {
+	name := mainTemplate
+	seen := map[string]bool{}
+	for !seen[name] {
+		seen[name] = true
+		parent, ok := a.parent(name)
+		if !ok {
+			break
+		}
+		name = parent
+	}
+	return name
+}
+
+// parent returns the template that name extends, if any.
+func (a *analyzer) parent(name string) (string, bool) {
+	tpl, err := a.parse(name)
+	if err != nil || tpl == nil {
+		return "", false
+	}
+	for _, n := range tpl.Nodes {
+		if cs, ok := n.(*nodes.ControlStructureBlock); ok {
+			if e, ok := cs.ControlStructure.(*extendsNode); ok {
+				return resolveName(e.filename), true
+			}
+		}
+	}
+	return "", false
+}
+
+// macroTemplate returns the template that defines the macro called name: the
+// main template or one it extends, or else the first file of the bundle.
+func (a *analyzer) macroTemplate(name string) (string, bool) {
+	var candidates []string
+	seen := map[string]bool{}
+	for t, ok := mainTemplate, true; ok && !seen[t]; t, ok = a.parent(t) {
+		seen[t] = true
+		candidates = append(candidates, t)
+	}
+	var files []string
+	for f := range a.loader.files {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	for _, t := range append(candidates, files...) {
+		if tpl, err := a.parse(t); err == nil && tpl != nil && tpl.Macros[name] != nil {
+			return t, true
+		}
+	}
+	return "", false
+}
+
+// blockTemplate returns the template whose body is rendered for the block
+// statement at line and col of template: the first of the main template and
+// those it extends to define a block of the same name.
+func (a *analyzer) blockTemplate(template string, line, col int) (string, bool) {
+	blocks := func(name string) map[string]blockOverride {
+		tpl, err := a.parse(name)
+		if err != nil || tpl == nil {
+			return nil
+		}
+		out := map[string]blockOverride{}
+		collectBlocks(tpl.Nodes, name, out)
+		return out
+	}
+	var block string
+	for name, b := range blocks(template) {
+		if b.block.location.Line == line && b.block.location.Col == col {
+			block = name
+		}
+	}
+	if block == "" {
+		return "", false
+	}
+	seen := map[string]bool{}
+	for t, ok := mainTemplate, true; ok && !seen[t]; t, ok = a.parent(t) {
+		seen[t] = true
+		if _, defined := blocks(t)[block]; defined {
+			return t, true
+		}
+	}
+	return "", false
+}
+
+// decodeContext decodes contextJSON, a JSON object or "".
+func decodeContext(contextJSON string) (map[string]any, error) {
+	data := map[string]any{}
+	if contextJSON == "" {
+		return data, nil
+	}
+	dec := json.NewDecoder(strings.NewReader(contextJSON))
+	dec.UseNumber()
+	if err := dec.Decode(&data); err != nil {
+		return nil, fmt.Errorf("context_json is not a JSON object: %w", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("context_json has data after the JSON object")
+	}
+	return numbers(data).(map[string]any), nil
+}
+
+// numbers converts the json.Numbers in v to int64 or float64, so integers
+// render as 1 rather than 1.0, as they do with Python's Jinja2.
+func numbers(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		for k, e := range v {
+			v[k] = numbers(e)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = numbers(e)
+		}
+	}
+	return v
+}
+
+// bundleLoader serves the main template and its bundle from memory. Like
+// Jinja2's loaders, names are resolved from the bundle root rather than
+// relative to the including template.
+type bundleLoader struct {
+	main  string
+	files map[string]string
+}
+
+var _ loaders.Loader = &bundleLoader{}
+
+func (l *bundleLoader) Read(name string) (io.Reader, error) {
+	if name == mainTemplate {
+		return strings.NewReader(l.main), nil
+	}
+	content, ok := l.files[name]
+	if !ok {
+		return nil, fmt.Errorf("template %q not found", name)
+	}
+	return bytes.NewBufferString(content), nil
+}
+
+func (l *bundleLoader) Resolve(name string) (string, error) {
+	if name == mainTemplate {
+		return name, nil
+	}
+	resolved := path.Clean(strings.TrimPrefix(name, "/"))
+	if _, ok := l.files[resolved]; !ok {
+		return "", fmt.Errorf("template %q not found", name)
+	}
+	return resolved, nil
+}
+
+func (l *bundleLoader) Inherit(string) (loaders.Loader, error) {
+	return l, nil
+}
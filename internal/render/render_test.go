@@ -0,0 +1,126 @@
+package render
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestUndefinedErrorMessages renders templates in strict mode with
+// something undefined in every kind of place, so a gonja upgrade that
+// changes the messages undefinedError reads fails here.
+func TestUndefinedErrorMessages(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		files    map[string]string
+		want     UndefinedError
+	}{
+		{
+			name:     "name",
+			template: "a\n{{ dag_id }}",
+			want:     UndefinedError{Name: "dag_id", Line: 2},
+		},
+		{
+			name:     "attribute",
+			template: "a\n{{ task.retries }}",
+			want:     UndefinedError{Name: "task.retries", Line: 2},
+		},
+		{
+			name:     "item",
+			template: "a\n{{ task['retries'] }}",
+			want:     UndefinedError{Name: "task['retries']", Line: 2},
+		},
+		{
+			name:     "attribute of an attribute",
+			template: "{{ task.retries.count }}",
+			want:     UndefinedError{Name: "task.retries", Line: 1},
+		},
+		{
+			name:     "filter argument",
+			template: "\n{{ dag_id | upper }}",
+			want:     UndefinedError{Name: "dag_id", Line: 2},
+		},
+		{
+			name:     "if condition",
+			template: "a\n{% if paused %}1{% endif %}",
+			want:     UndefinedError{Name: "paused", Line: 2},
+		},
+		{
+			name:     "for body",
+			template: "a\n{% for t in tasks %}\n{{ t }}{{ owner }}{% endfor %}",
+			want:     UndefinedError{Name: "owner", Line: 3},
+		},
+		{
+			name:     "included file",
+			template: "a\n\n{% include 'sub.j2' %}",
+			files:    map[string]string{"sub.j2": "s\n\n\n{{ missing }}"},
+			want:     UndefinedError{Name: "missing", Template: "sub.j2", Line: 4},
+		},
+		{
+			name:     "file included by an included file",
+			template: "{% include 'a.j2' %}",
+			files: map[string]string{
+				"a.j2":         "\n{% include 'sensors/b.j2' %}",
+				"sensors/b.j2": "\n\n{{ bucket }}",
+			},
+			want: UndefinedError{Name: "bucket", Template: "sensors/b.j2", Line: 3},
+		},
+		{
+			name:     "include by a computed name",
+			template: "{% set f = 'sub.j2' %}\n{% include f %}",
+			files:    map[string]string{"sub.j2": "\n\n{{ missing }}"},
+			want:     UndefinedError{Name: "missing", Line: 2},
+		},
+		{
+			name:     "imported macro",
+			template: "a\n{% import 'macros.j2' as m %}{{ m.sensor() }}",
+			files:    map[string]string{"macros.j2": "{% macro sensor() %}\n\n{{ bucket }}{% endmacro %}"},
+			want:     UndefinedError{Name: "bucket", Template: "macros.j2", Line: 3},
+		},
+		{
+			name:     "macro of the main template",
+			template: "{% macro sensor() %}\n{{ bucket }}{% endmacro %}\n{{ sensor() }}",
+			want:     UndefinedError{Name: "bucket", Line: 2},
+		},
+		{
+			name:     "block of an extending template",
+			template: "{% extends 'base.j2' %}{% block body %}\n{{ schedule }}{% endblock %}",
+			files:    map[string]string{"base.j2": "x\n{% block body %}{% endblock %}"},
+			want:     UndefinedError{Name: "schedule", Line: 2},
+		},
+		{
+			name:     "extended template",
+			template: "{% extends 'base.j2' %}",
+			files:    map[string]string{"base.j2": "x\n\n{{ owner }}"},
+			want:     UndefinedError{Name: "owner", Template: "base.j2", Line: 3},
+		},
+		{
+			name:     "block the extending template does not override",
+			template: "{% extends 'base.j2' %}{% block body %}b{% endblock %}",
+			files:    map[string]string{"base.j2": "{% block head %}\n{{ owner }}{% endblock %}{% block body %}{% endblock %}"},
+			want:     UndefinedError{Name: "owner", Template: "base.j2", Line: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Render(tt.template, tt.files, `{"task": {}, "tasks": [1]}`, Options{StrictUndefined: true})
+			var uerr *UndefinedError
+			if !errors.As(err, &uerr) {
+				t.Fatalf("err = %v, want an *UndefinedError", err)
+			}
+			if *uerr != tt.want {
+				t.Errorf("err = %+v, want %+v", *uerr, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderUndefinedWithoutStrict(t *testing.T) {
+	out, err := Render("[{{ dag_id }}]", nil, "", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "[]" {
+		t.Errorf("out = %q, want []", out)
+	}
+}
@@ -0,0 +1,291 @@
+package render
+
+import (
+	"fmt"
+
+	"github.com/nikolalohinski/gonja/v2/builtins"
+	"github.com/nikolalohinski/gonja/v2/exec"
+	"github.com/nikolalohinski/gonja/v2/nodes"
+	"github.com/nikolalohinski/gonja/v2/parser"
+	"github.com/nikolalohinski/gonja/v2/tokens"
+)
+
+// The parsers below stand in for gonja's own for the tags whose nodes keep
+// their arguments unexported, so Variables can see what they use. They accept
+// the same syntax, and their nodes are only analysed, never rendered.
+
+// analysisStatements are gonja's control structures, with the parsers below
+// in place of the opaque ones.
+var analysisStatements = exec.NewControlStructureSet(map[string]parser.ControlStructureParser{}).
+	Update(builtins.ControlStructures).
+	Update(exec.NewControlStructureSet(map[string]parser.ControlStructureParser{
+		"set":     parseSet,
+		"with":    parseWith,
+		"block":   parseBlock,
+		"filter":  parseFilter,
+		"include": parseInclude,
+		"import":  parseImport,
+		"extends": parseExtends,
+	}))
+
+// setNode is {% set target = value %}, or {% set target %}body{% endset %}.
+type setNode struct {
+	location    *tokens.Token
+	target      nodes.Expression
+	value       nodes.Expression
+	condition   nodes.Expression
+	alternative nodes.Expression
+	body        *nodes.Wrapper
+}
+
+func (n *setNode) Position() *tokens.Token { return n.location }
+func (n *setNode) String() string          { return fmt.Sprintf("set(%s)", n.target) }
+
+func parseSet(p *parser.Parser, args *parser.Parser) (nodes.ControlStructure, error) {
+	n := &setNode{location: p.Current()}
+	target, err := args.ParseVariableOrLiteral()
+	if err != nil {
+		return nil, err
+	}
+	switch target.(type) {
+	case *nodes.Name, *nodes.Call, *nodes.GetItem, *nodes.GetAttribute:
+		n.target = target
+	default:
+		return nil, args.Error(fmt.Sprintf("unexpected set target %s", target), target.Position())
+	}
+
+	if args.Match(tokens.Assign) == nil {
+		if !args.End() {
+			return nil, args.Error("Expected '=' or end of tag for block-set.", args.Current())
+		}
+		body, endargs, err := p.WrapUntil("endset")
+		if err != nil {
+			return nil, err
+		}
+		if !endargs.End() {
+			return nil, endargs.Error("endset takes no arguments", nil)
+		}
+		n.body = body
+		return n, nil
+	}
+
+	if n.value, err = args.ParseExpression(); err != nil {
+		return nil, err
+	}
+	if n.condition, n.alternative, err = args.ParseCondition(); err != nil {
+		return nil, err
+	}
+	if !args.End() {
+		return nil, args.Error("Malformed 'set' tag args.", args.Current())
+	}
+	return n, nil
+}
+
+// withNode is {% with name = value, ... %}body{% endwith %}.
+type withNode struct {
+	location *tokens.Token
+	names    []string
+	values   []nodes.Expression
+	body     *nodes.Wrapper
+}
+
+func (n *withNode) Position() *tokens.Token { return n.location }
+func (n *withNode) String() string          { return fmt.Sprintf("with(%v)", n.names) }
+
+func parseWith(p *parser.Parser, args *parser.Parser) (nodes.ControlStructure, error) {
+	n := &withNode{location: p.Current()}
+	body, endargs, err := p.WrapUntil("endwith")
+	if err != nil {
+		return nil, err
+	}
+	if !endargs.End() {
+		return nil, endargs.Error("Arguments not allowed here.", nil)
+	}
+	n.body = body
+
+	for !args.End() {
+		name := args.Match(tokens.Name)
+		if name == nil {
+			return nil, args.Error("Expected an identifier", args.Current())
+		}
+		if args.Match(tokens.Assign) == nil {
+			return nil, args.Error("Expected '='.", args.Current())
+		}
+		value, err := args.ParseExpression()
+		if err != nil {
+			return nil, err
+		}
+		n.names = append(n.names, name.Val)
+		n.values = append(n.values, value)
+		if args.Match(tokens.Comma) == nil {
+			break
+		}
+	}
+	if !args.End() {
+		return nil, args.Error("Malformed 'with' tag args.", args.Current())
+	}
+	return n, nil
+}
+
+// blockNode is {% block name %}body{% endblock %}.
+type blockNode struct {
+	location *tokens.Token
+	name     string
+	body     *nodes.Wrapper
+}
+
+func (n *blockNode) Position() *tokens.Token { return n.location }
+func (n *blockNode) String() string          { return fmt.Sprintf("block(%s)", n.name) }
+
+func parseBlock(p *parser.Parser, args *parser.Parser) (nodes.ControlStructure, error) {
+	n := &blockNode{location: p.Current()}
+	name := args.Match(tokens.Name)
+	if name == nil || !args.End() {
+		return nil, args.Error("Tag 'block' takes exactly 1 argument (an identifier).", args.Current())
+	}
+	n.name = name.Val
+
+	body, endargs, err := p.WrapUntil("endblock")
+	if err != nil {
+		return nil, err
+	}
+	if !endargs.End() {
+		endName := endargs.Match(tokens.Name)
+		if endName == nil || endName.Val != n.name || !endargs.End() {
+			return nil, endargs.Error(fmt.Sprintf("Only the block's name (%s) is allowed as argument of 'endblock'.", n.name), nil)
+		}
+	}
+	n.body = body
+	return n, nil
+}
+
+// filterNode is {% filter name(args) | ... %}body{% endfilter %}.
+type filterNode struct {
+	location *tokens.Token
+	filters  []*nodes.FilterCall
+	body     *nodes.Wrapper
+}
+
+func (n *filterNode) Position() *tokens.Token { return n.location }
+func (n *filterNode) String() string          { return "filter" }
+
+func parseFilter(p *parser.Parser, args *parser.Parser) (nodes.ControlStructure, error) {
+	n := &filterNode{location: p.Current()}
+	body, _, err := p.WrapUntil("endfilter")
+	if err != nil {
+		return nil, err
+	}
+	n.body = body
+
+	for !args.End() {
+		filter, err := args.ParseFilter()
+		if err != nil {
+			return nil, err
+		}
+		n.filters = append(n.filters, filter)
+		if args.Match(tokens.Pipe) == nil {
+			break
+		}
+	}
+	if !args.End() {
+		return nil, args.Error("Malformed filter-tag args.", args.Current())
+	}
+	return n, nil
+}
+
+// includeNode is {% include filename %}.
+type includeNode struct {
+	location    *tokens.Token
+	filename    nodes.Expression
+	withContext bool
+}
+
+func (n *includeNode) Position() *tokens.Token { return n.location }
+func (n *includeNode) String() string          { return fmt.Sprintf("include(%s)", n.filename) }
+
+func parseInclude(p *parser.Parser, args *parser.Parser) (nodes.ControlStructure, error) {
+	n := &includeNode{location: p.Current(), withContext: true}
+	filename, err := args.ParseExpression()
+	if err != nil {
+		return nil, err
+	}
+	n.filename = filename
+
+	if args.MatchName("ignore") != nil {
+		if args.MatchName("missing") == nil {
+			args.Stream().Backup()
+		}
+	}
+	n.withContext = matchContext(args, n.withContext)
+	if !args.End() {
+		return nil, args.Error("Malformed 'include'-tag args.", args.Current())
+	}
+	return n, nil
+}
+
+// importNode is {% import filename as name %}.
+type importNode struct {
+	location    *tokens.Token
+	filename    nodes.Expression
+	as          string
+	withContext bool
+}
+
+func (n *importNode) Position() *tokens.Token { return n.location }
+func (n *importNode) String() string          { return fmt.Sprintf("import(%s)", n.filename) }
+
+func parseImport(p *parser.Parser, args *parser.Parser) (nodes.ControlStructure, error) {
+	n := &importNode{location: p.Current()}
+	filename, err := args.ParseExpression()
+	if err != nil {
+		return nil, err
+	}
+	n.filename = filename
+	if args.MatchName("as") == nil {
+		return nil, args.Error(`Expected "as" keyword`, args.Current())
+	}
+	as := args.Match(tokens.Name)
+	if as == nil {
+		return nil, args.Error("Expected macro alias name (identifier)", args.Current())
+	}
+	n.as = as.Val
+	n.withContext = matchContext(args, false)
+	return n, nil
+}
+
+// extendsNode is {% extends filename %}.
+type extendsNode struct {
+	location *tokens.Token
+	filename string
+}
+
+func (n *extendsNode) Position() *tokens.Token { return n.location }
+func (n *extendsNode) String() string          { return fmt.Sprintf("extends(%s)", n.filename) }
+
+func parseExtends(p *parser.Parser, args *parser.Parser) (nodes.ControlStructure, error) {
+	n := &extendsNode{location: p.Current()}
+	filename := args.Match(tokens.String)
+	if filename == nil {
+		return nil, args.Error("tag 'extends' requires a template filename as string", args.Current())
+	}
+	n.filename = filename.Val
+	matchContext(args, true)
+	if !args.End() {
+		return nil, args.Error("tag 'extends' only takes 1 argument", args.Current())
+	}
+	return n, nil
+}
+
+// matchContext consumes a trailing "with context" or "without context" and
+// returns whether the context is passed, def if neither is given.
+func matchContext(args *parser.Parser, def bool) bool {
+	tok := args.MatchName("with", "without")
+	if tok == nil {
+		return def
+	}
+	if args.MatchName("context") == nil {
+		args.Stream().Backup()
+		return def
+	}
+	return tok.Val == "with"
+}
@@ -0,0 +1,830 @@
+package render
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/nikolalohinski/gonja/v2"
+	controlStructures "github.com/nikolalohinski/gonja/v2/builtins/control_structures"
+	"github.com/nikolalohinski/gonja/v2/nodes"
+	"github.com/nikolalohinski/gonja/v2/parser"
+	"github.com/nikolalohinski/gonja/v2/tokens"
+)
+
+// Reference is a value of the context that a template uses.
+type Reference struct {
+	// Path leads from a top-level key of the context to the value. An
+	// element of a list is "[]", so task.task_id in
+	// {% for task in tasks %} is tasks, [], task_id.
+	Path []string
+	// Template is the file of the bundle the value is first used in, or ""
+	// for the main template, and Line the line of that use.
+	Template string
+	Line     int
+	// Optional reports whether the template does without the value: it is
+	// only used behind "is defined", the default filter or a condition, in a
+	// branch of an if, or in a file or macro that may not be rendered.
+	Optional bool
+}
+
+// String returns the path of r as it is written in a template, such as
+// tasks[].task_id.
+func (r Reference) String() string {
+	var b strings.Builder
+	for i, seg := range r.Path {
+		switch {
+		case seg == "[]":
+			b.WriteString("[]")
+		case !isIdentifier(seg):
+			fmt.Fprintf(&b, "[%q]", seg)
+		default:
+			if i > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(seg)
+		}
+	}
+	return b.String()
+}
+
+// Usage is the use a template makes of its context.
+type Usage struct {
+	// References holds each value used, ordered by path.
+	References []Reference
+	// Complete is false when the template includes, imports or extends a
+	// file missing from the bundle, so the values it uses are not all known.
+	Complete bool
+}
+
+// Required returns the paths of the values the template cannot do without,
+// sorted. A path is left out when a longer one that goes through it is
+// listed, since that value must be there too.
+func (u *Usage) Required() []string {
+	var required []Reference
+	for _, r := range u.References {
+		if !r.Optional {
+			required = append(required, r)
+		}
+	}
+	var paths []string
+	for i, r := range required {
+		if i+1 < len(required) && hasPrefix(required[i+1].Path, r.Path) {
+			continue
+		}
+		paths = append(paths, r.String())
+	}
+	return paths
+}
+
+// Missing is a value the template requires that the context does not have.
+type Missing struct {
+	Reference
+	// Location is where the value is missing in the context, such as
+	// tasks, 1, task_id for the task_id of the second element of tasks.
+	Location []string
+}
+
+// Check compares the template's use of the context with contextJSON. It
+// returns the required values the context lacks and, if u is Complete, the
+// sorted top-level keys the template never uses.
+func (u *Usage) Check(contextJSON string) (missing []Missing, unused []string, err error) {
+	data, err := decodeContext(contextJSON)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	seen := map[string]bool{}
+	used := map[string]bool{}
+	for _, r := range u.References {
+		used[r.Path[0]] = true
+		if r.Optional {
+			continue
+		}
+		for _, loc := range missingAt(data, r.Path, nil) {
+			key := strings.Join(loc, "\x00")
+			if !seen[key] {
+				seen[key] = true
+				missing = append(missing, Missing{Reference: r, Location: loc})
+			}
+		}
+	}
+	if u.Complete {
+		for key := range data {
+			if !used[key] {
+				unused = append(unused, key)
+			}
+		}
+		sort.Strings(unused)
+	}
+	return missing, unused, nil
+}
+
+// missingAt returns the locations, under loc, where v lacks the value at p.
+// A value that is not an object has no keys, so a key of a string, number,
+// list or null is missing, as it is undefined in Jinja2. Methods are not
+// keys: a call such as name.upper() only uses name. Looping over an object
+// or a string is not checked.
+func missingAt(v any, p []string, loc []string) [][]string {
+	if len(p) == 0 {
+		return nil
+	}
+	var out [][]string
+	switch v := v.(type) {
+	case map[string]any:
+		if p[0] == "[]" {
+			return nil
+		}
+		loc = append(loc[:len(loc):len(loc)], p[0])
+		e, ok := v[p[0]]
+		if !ok {
+			return [][]string{loc}
+		}
+		out = missingAt(e, p[1:], loc)
+	case []any:
+		if p[0] != "[]" {
+			return [][]string{append(loc[:len(loc):len(loc)], p[0])}
+		}
+		for i, e := range v {
+			out = append(out, missingAt(e, p[1:], append(loc[:len(loc):len(loc)], fmt.Sprint(i)))...)
+		}
+	default:
+		if p[0] != "[]" {
+			return [][]string{append(loc[:len(loc):len(loc)], p[0])}
+		}
+	}
+	return out
+}
+
+// Variables parses template and the files of its bundle it includes,
+// imports or extends, and returns the values of the context they use. Names
+// the template binds itself, such as loop variables, macro arguments and
+// {% set %} targets, are not part of the context, but the attributes used
+// through a loop variable are traced back to the list it iterates over.
+func Variables(template string, files map[string]string) (*Usage, error) {
+	a := &analyzer{
+		loader:    &bundleLoader{main: template, files: files},
+		parsed:    map[string]*nodes.Template{},
+		refs:      map[string]int{},
+		including: map[string]bool{mainTemplate: true},
+		usage:     &Usage{Complete: true},
+	}
+	a.push()
+	if err := a.walkTemplate(mainTemplate, nil); err != nil {
+		return nil, err
+	}
+
+	refs := a.usage.References
+	for i := range refs {
+		for _, g := range a.guards {
+			if hasPrefix(refs[i].Path, g) {
+				refs[i].Optional = true
+			}
+		}
+	}
+	sort.SliceStable(refs, func(i, j int) bool { return lessPath(refs[i].Path, refs[j].Path) })
+	return a.usage, nil
+}
+
+// binding is a name a template binds. path is the value of the context it
+// stands for, or nil if it is not one.
+type binding struct {
+	path []string
+}
+
+// blockOverride is a block of an extending template, which replaces the
+// block of the same name in the template it extends.
+type blockOverride struct {
+	block    *blockNode
+	template string
+}
+
+// analyzer walks the templates of a bundle to find the context they use.
+type analyzer struct {
+	loader *bundleLoader
+	parsed map[string]*nodes.Template
+
+	usage     *Usage
+	refs      map[string]int
+	guards    [][]string
+	including map[string]bool
+
+	// template is the file being walked, and overrides the blocks that
+	// replace its own.
+	template  string
+	overrides map[string]blockOverride
+	// optional is positive while walking what may not be rendered.
+	optional int
+	scopes   []map[string]binding
+}
+
+func (a *analyzer) push() { a.scopes = append(a.scopes, map[string]binding{}) }
+func (a *analyzer) pop()  { a.scopes = a.scopes[:len(a.scopes)-1] }
+func (a *analyzer) bind(name string, path []string) {
+	a.scopes[len(a.scopes)-1][name] = binding{path: path}
+}
+
+func (a *analyzer) lookup(name string) (binding, bool) {
+	for i := len(a.scopes) - 1; i >= 0; i-- {
+		if b, ok := a.scopes[i][name]; ok {
+			return b, true
+		}
+	}
+	return binding{}, false
+}
+
+// parse returns the parsed template called name, or nil if the bundle has no
+// such file.
+func (a *analyzer) parse(name string) (*nodes.Template, error) {
+	if tpl, ok := a.parsed[name]; ok {
+		return tpl, nil
+	}
+	source, ok := a.loader.files[name]
+	if name == mainTemplate {
+		source, ok = a.loader.main, true
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	cfg := gonja.DefaultConfig
+	p := parser.NewParser(name, tokens.LexAll(source, cfg), cfg, a.loader, analysisStatements)
+	tpl, err := p.Parse()
+	if err != nil {
+		if name == mainTemplate {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	a.parsed[name] = tpl
+	return tpl, nil
+}
+
+// walkTemplate walks the file called name, with overrides replacing its
+// blocks. A template that extends another only sets up names outside its
+// blocks, and is rendered as the template it extends with its blocks in
+// place.
+func (a *analyzer) walkTemplate(name string, overrides map[string]blockOverride) error {
+	tpl, err := a.parse(name)
+	if err != nil {
+		return err
+	}
+	if tpl == nil {
+		a.usage.Complete = false
+		return nil
+	}
+
+	prevTemplate, prevOverrides := a.template, a.overrides
+	a.template, a.overrides = name, overrides
+	defer func() { a.template, a.overrides = prevTemplate, prevOverrides }()
+
+	for macro := range tpl.Macros {
+		a.bind(macro, nil)
+	}
+	var extends *extendsNode
+	for _, n := range tpl.Nodes {
+		if cs, ok := n.(*nodes.ControlStructureBlock); ok {
+			if e, ok := cs.ControlStructure.(*extendsNode); ok {
+				extends = e
+			}
+		}
+	}
+	if extends == nil {
+		for _, n := range tpl.Nodes {
+			if err := a.node(n); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	merged := map[string]blockOverride{}
+	collectBlocks(tpl.Nodes, name, merged)
+	for k, v := range overrides {
+		merged[k] = v
+	}
+	for _, n := range tpl.Nodes {
+		cs, ok := n.(*nodes.ControlStructureBlock)
+		if !ok {
+			continue
+		}
+		switch cs.ControlStructure.(type) {
+		case *setNode, *importNode, *controlStructures.FromImportControlStructure, *controlStructures.MacroControlStructure:
+			if err := a.node(n); err != nil {
+				return err
+			}
+		}
+	}
+	parent := resolveName(extends.filename)
+	if a.including[parent] {
+		return nil
+	}
+	a.including[parent] = true
+	defer delete(a.including, parent)
+	return a.walkTemplate(parent, merged)
+}
+
+// collectBlocks adds the blocks in ns, including nested ones, to blocks.
+func collectBlocks(ns []nodes.Node, template string, blocks map[string]blockOverride) {
+	for _, n := range ns {
+		cs, ok := n.(*nodes.ControlStructureBlock)
+		if !ok {
+			continue
+		}
+		if b, ok := cs.ControlStructure.(*blockNode); ok {
+			blocks[b.name] = blockOverride{block: b, template: template}
+			collectBlocks(b.body.Nodes, template, blocks)
+		}
+	}
+}
+
+// walkFiles walks the files a template includes or imports by the
+// expression filename. A dynamic name could be any file of the bundle, so
+// all of them are walked, as optional.
+func (a *analyzer) walkFiles(filename nodes.Expression) error {
+	var names []string
+	switch f := filename.(type) {
+	case *nodes.String:
+		names = []string{f.Val}
+	case *nodes.List, *nodes.Tuple:
+		// The first of the files that exists is used.
+		a.optional++
+		defer func() { a.optional-- }()
+		var elems []nodes.Expression
+		if l, ok := f.(*nodes.List); ok {
+			elems = l.Val
+		} else {
+			elems = f.(*nodes.Tuple).Val
+		}
+		for _, e := range elems {
+			s, ok := e.(*nodes.String)
+			if !ok {
+				return a.walkFiles(nil)
+			}
+			names = append(names, s.Val)
+		}
+	default:
+		a.optional++
+		defer func() { a.optional-- }()
+		for name := range a.loader.files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	for _, name := range names {
+		name = resolveName(name)
+		if a.including[name] {
+			continue
+		}
+		a.including[name] = true
+		a.push()
+		err := a.walkTemplate(name, nil)
+		a.pop()
+		delete(a.including, name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveName returns the key of the bundle file a template refers to as
+// name, as bundleLoader resolves it.
+func resolveName(name string) string {
+	return path.Clean(strings.TrimPrefix(name, "/"))
+}
+
+func (a *analyzer) wrapper(w *nodes.Wrapper) error {
+	if w == nil {
+		return nil
+	}
+	for _, n := range w.Nodes {
+		if err := a.node(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *analyzer) node(n nodes.Node) error {
+	switch n := n.(type) {
+	case *nodes.Output:
+		// With a condition, either value may go unused.
+		a.condition(n.Condition)
+		a.expr(n.Expression, n.Condition != nil)
+		a.expr(n.Alternative, true)
+	case *nodes.ControlStructureBlock:
+		return a.statement(n.ControlStructure)
+	}
+	return nil
+}
+
+func (a *analyzer) statement(cs nodes.ControlStructure) error {
+	switch cs := cs.(type) {
+	case *controlStructures.IfControlStructure:
+		// Any branch may be left out.
+		a.optional++
+		defer func() { a.optional-- }()
+		for i, w := range cs.Wrappers {
+			if i < len(cs.Conditions) {
+				a.condition(cs.Conditions[i])
+			}
+			if err := a.wrapper(w); err != nil {
+				return err
+			}
+		}
+
+	case *controlStructures.ForControlStructure:
+		a.expr(cs.ObjectEvaluator, false)
+		a.push()
+		if cs.Value == "" {
+			var elem []string
+			if p, ok := a.resolve(cs.ObjectEvaluator); ok {
+				elem = append(p, "[]")
+			}
+			a.bind(cs.Key, elem)
+		} else {
+			a.bind(cs.Key, nil)
+			a.bind(cs.Value, nil)
+		}
+		a.bind("loop", nil)
+		a.condition(cs.IfCondition)
+		err := a.wrapper(cs.BodyWrapper)
+		a.pop()
+		if err != nil {
+			return err
+		}
+		return a.wrapper(cs.EmptyWrapper)
+
+	case *controlStructures.MacroControlStructure:
+		a.bind(cs.Name, nil)
+		a.push()
+		defer a.pop()
+		for _, kw := range cs.Kwargs {
+			a.expr(kw.Value, false)
+			if key, ok := kw.Key.(*nodes.String); ok {
+				a.bind(key.Val, nil)
+			}
+		}
+		for _, name := range []string{cs.VarArgsName, cs.KwArgsName, "varargs", "kwargs", "caller"} {
+			if name != "" {
+				a.bind(name, nil)
+			}
+		}
+		return a.wrapper(cs.Wrapper)
+
+	case *controlStructures.CallControlStructure:
+		a.expr(cs.Call, false)
+		return a.wrapper(cs.Body)
+
+	case *controlStructures.DoControlStructure:
+		a.expr(cs.Expression, false)
+
+	case *controlStructures.AutoescapeControlStructure:
+		return a.wrapper(cs.Wrapper)
+
+	case *controlStructures.TransControlStructure:
+		a.push()
+		defer a.pop()
+		for name, value := range cs.Variables {
+			a.expr(value, false)
+			a.bind(name, nil)
+		}
+		if err := a.wrapper(cs.SingularBody); err != nil {
+			return err
+		}
+		return a.wrapper(cs.PluralBody)
+
+	case *controlStructures.FromImportControlStructure:
+		a.expr(cs.FilenameExpression, false)
+		for name := range cs.As {
+			a.bind(name, nil)
+		}
+		if cs.WithContext {
+			return a.walkImport(cs.FilenameExpression)
+		}
+
+	case *importNode:
+		a.expr(cs.filename, false)
+		a.bind(cs.as, nil)
+		if cs.withContext {
+			return a.walkImport(cs.filename)
+		}
+
+	case *includeNode:
+		a.expr(cs.filename, false)
+		if cs.withContext {
+			return a.walkFiles(cs.filename)
+		}
+
+	case *setNode:
+		a.expr(cs.value, false)
+		a.condition(cs.condition)
+		a.expr(cs.alternative, false)
+		if err := a.wrapper(cs.body); err != nil {
+			return err
+		}
+		if name, ok := cs.target.(*nodes.Name); ok {
+			var alias []string
+			if cs.condition == nil {
+				alias, _ = a.resolve(cs.value)
+			}
+			a.bind(name.Name.Val, alias)
+		} else {
+			a.target(cs.target)
+		}
+
+	case *withNode:
+		aliases := make([][]string, len(cs.values))
+		for i, value := range cs.values {
+			a.expr(value, false)
+			aliases[i], _ = a.resolve(value)
+		}
+		a.push()
+		defer a.pop()
+		for i, name := range cs.names {
+			a.bind(name, aliases[i])
+		}
+		return a.wrapper(cs.body)
+
+	case *filterNode:
+		for _, f := range cs.filters {
+			a.args(f.Args, f.Kwargs)
+		}
+		return a.wrapper(cs.body)
+
+	case *blockNode:
+		o, ok := a.overrides[cs.name]
+		if !ok || o.block == cs {
+			return a.wrapper(cs.body)
+		}
+		prev := a.template
+		a.template = o.template
+		err := a.wrapper(o.block.body)
+		a.template = prev
+		if err != nil {
+			return err
+		}
+		// The block's own body is only rendered if the override calls super().
+		a.optional++
+		defer func() { a.optional-- }()
+		return a.wrapper(cs.body)
+	}
+	return nil
+}
+
+// walkImport walks the macros of a template imported with context. They
+// use the context only if they are called, so what they use is optional.
+func (a *analyzer) walkImport(filename nodes.Expression) error {
+	a.optional++
+	defer func() { a.optional-- }()
+	return a.walkFiles(filename)
+}
+
+// target walks the target of a {% set %} that assigns an attribute or item,
+// such as ns.found, which uses the object it is assigned on.
+func (a *analyzer) target(t nodes.Expression) {
+	switch t := t.(type) {
+	case *nodes.GetAttribute:
+		a.expr(t.Node, false)
+	case *nodes.GetItem:
+		a.expr(t.Node, false)
+		a.expr(t.Arg, false)
+	default:
+		a.expr(t, false)
+	}
+}
+
+// condition walks a condition. A value tested for truth is optional, since
+// an undefined value is false.
+func (a *analyzer) condition(e nodes.Expression) {
+	switch e := e.(type) {
+	case *nodes.Name, *nodes.GetAttribute, *nodes.GetItem:
+		a.expr(e, true)
+	case *nodes.Negation:
+		a.condition(e.Term)
+	case *nodes.BinaryExpression:
+		if op := e.Operator.Token.Val; op == "and" || op == "or" {
+			a.condition(e.Left)
+			a.condition(e.Right)
+			return
+		}
+		a.expr(e, false)
+	default:
+		a.expr(e, false)
+	}
+}
+
+// guard makes the value e stands for, and the values under it, optional.
+func (a *analyzer) guard(e nodes.Node) {
+	if p, ok := a.resolve(e); ok {
+		a.guards = append(a.guards, p)
+	}
+}
+
+func (a *analyzer) args(args []nodes.Expression, kwargs map[string]nodes.Expression) {
+	for _, arg := range args {
+		a.expr(arg, false)
+	}
+	for _, arg := range kwargs {
+		a.expr(arg, false)
+	}
+}
+
+// expr walks an expression, whose values are optional if optional is set.
+func (a *analyzer) expr(e nodes.Node, optional bool) {
+	switch e := e.(type) {
+	case *nodes.Name, *nodes.GetAttribute, *nodes.GetItem:
+		a.use(e, optional)
+	case *nodes.GetSlice:
+		a.use(e.Node, optional)
+		a.expr(e.Start, false)
+		a.expr(e.End, false)
+		a.expr(e.Step, false)
+	case *nodes.Call:
+		// A method is called on its object, which is the value used.
+		if method, ok := e.Func.(*nodes.GetAttribute); ok {
+			a.expr(method.Node, optional)
+		} else {
+			a.expr(e.Func, optional)
+		}
+		a.args(e.Args, e.Kwargs)
+	case *nodes.FilteredExpression:
+		guarded := optional
+		for _, f := range e.Filters {
+			if f.Name == "default" || f.Name == "d" {
+				guarded = true
+			}
+			a.args(f.Args, f.Kwargs)
+		}
+		a.expr(e.Expression, guarded)
+	case *nodes.TestExpression:
+		switch e.Test.Name {
+		case "defined", "undefined":
+			a.guard(e.Expression)
+			a.expr(e.Expression, true)
+		default:
+			a.expr(e.Expression, optional)
+		}
+		a.args(e.Test.Args, e.Test.Kwargs)
+	case *nodes.BinaryExpression:
+		a.expr(e.Left, optional)
+		a.expr(e.Right, optional)
+	case *nodes.Negation:
+		a.expr(e.Term, optional)
+	case *nodes.UnaryExpression:
+		a.expr(e.Term, optional)
+	case *nodes.List:
+		for _, v := range e.Val {
+			a.expr(v, optional)
+		}
+	case *nodes.Tuple:
+		for _, v := range e.Val {
+			a.expr(v, optional)
+		}
+	case *nodes.Dict:
+		for _, p := range e.Pairs {
+			a.expr(p.Key, optional)
+			a.expr(p.Value, optional)
+		}
+	}
+}
+
+// chain splits a chain of attribute and item accesses into the name it
+// starts from and the path after it. The path stops at the first integer or
+// computed index, whose subscripts are returned in dynamic.
+func chain(e nodes.Node) (name *nodes.Name, path []string, dynamic []nodes.Node) {
+	complete := true
+	var walk func(nodes.Node)
+	walk = func(n nodes.Node) {
+		switch n := n.(type) {
+		case *nodes.Name:
+			name = n
+		case *nodes.GetAttribute:
+			walk(n.Node)
+			if n.Attribute == "" {
+				complete = false
+			} else if complete && name != nil {
+				path = append(path, n.Attribute)
+			}
+		case *nodes.GetItem:
+			walk(n.Node)
+			if s, ok := n.Arg.(*nodes.String); ok && complete && name != nil {
+				path = append(path, s.Val)
+			} else {
+				complete = false
+				dynamic = append(dynamic, n.Arg)
+			}
+		default:
+			dynamic = append(dynamic, n)
+		}
+	}
+	walk(e)
+	return name, path, dynamic
+}
+
+// resolve returns the path in the context of the value e stands for, if it
+// is one.
+func (a *analyzer) resolve(e nodes.Node) ([]string, bool) {
+	name, p, dynamic := chain(e)
+	if name == nil || len(dynamic) > 0 {
+		return nil, false
+	}
+	b, bound := a.lookup(name.Name.Val)
+	switch {
+	case bound && b.path == nil:
+		return nil, false
+	case bound:
+		return append(b.path[:len(b.path):len(b.path)], p...), true
+	case isGlobal(name.Name.Val):
+		return nil, false
+	}
+	return append([]string{name.Name.Val}, p...), true
+}
+
+// use records the value of the context that e, a chain of accesses, uses.
+func (a *analyzer) use(e nodes.Node, optional bool) {
+	name, p, dynamic := chain(e)
+	for _, d := range dynamic {
+		if d != nil && d != e {
+			a.expr(d, false)
+		}
+	}
+	if name == nil {
+		return
+	}
+	b, bound := a.lookup(name.Name.Val)
+	switch {
+	case bound && b.path == nil:
+		return
+	case bound:
+		p = append(b.path[:len(b.path):len(b.path)], p...)
+	case isGlobal(name.Name.Val):
+		// A context key of the same name takes the place of the global, so
+		// it is used, but the template does not need it.
+		p, optional = []string{name.Name.Val}, true
+	default:
+		p = append([]string{name.Name.Val}, p...)
+	}
+	a.record(p, name.Name.Line, optional)
+}
+
+func (a *analyzer) record(p []string, line int, optional bool) {
+	for len(p) > 1 && p[len(p)-1] == "[]" {
+		p = p[:len(p)-1]
+	}
+	optional = optional || a.optional > 0
+	key := strings.Join(p, "\x00")
+	if i, ok := a.refs[key]; ok {
+		ref := &a.usage.References[i]
+		ref.Optional = ref.Optional && optional
+		return
+	}
+	template := a.template
+	if template == mainTemplate {
+		template = ""
+	}
+	a.refs[key] = len(a.usage.References)
+	a.usage.References = append(a.usage.References, Reference{Path: p, Template: template, Line: line, Optional: optional})
+}
+
+// isGlobal reports whether name is one of the functions and variables
+// templates have without a context, such as range.
+func isGlobal(name string) bool {
+	return name == "self" || name == "super" || gonja.DefaultContext.Has(name)
+}
+
+func isIdentifier(s string) bool {
+	for i, r := range s {
+		if r != '_' && !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || i > 0 && '0' <= r && r <= '9') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// hasPrefix reports whether path p starts with prefix.
+func hasPrefix(p, prefix []string) bool {
+	if len(prefix) > len(p) {
+		return false
+	}
+	for i := range prefix {
+		if p[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// lessPath orders paths segment by segment, so a path comes right before
+// the paths that extend it.
+func lessPath(p, q []string) bool {
+	for i := 0; i < len(p) && i < len(q); i++ {
+		if p[i] != q[i] {
+			return p[i] < q[i]
+		}
+	}
+	return len(p) < len(q)
+}
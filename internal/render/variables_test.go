@@ -0,0 +1,185 @@
+package render
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVariablesRequired(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		files    map[string]string
+		want     []string
+	}{
+		{
+			name:     "names",
+			template: "{{ dag_id }} {{ schedule }}",
+			want:     []string{"dag_id", "schedule"},
+		},
+		{
+			name:     "attribute covers its object",
+			template: "{{ owner }} {{ owner.email }}",
+			want:     []string{"owner.email"},
+		},
+		{
+			name:     "loop variable",
+			template: "{% for task in tasks %}{{ task.task_id }}{% endfor %}",
+			want:     []string{"tasks[].task_id"},
+		},
+		{
+			name:     "method call uses its object",
+			template: "{{ dag_id.upper() }}",
+			want:     []string{"dag_id"},
+		},
+		{
+			name:     "optional uses",
+			template: "{% if retries is defined %}{{ retries }}{% endif %}{{ owner | default('data') }}{% if paused %}{{ reason }}{% endif %}{{ dag_id }}",
+			want:     []string{"dag_id"},
+		},
+		{
+			name:     "names bound by the template",
+			template: "{% set n = 3 %}{% macro m(x) %}{{ x }}{% endmacro %}{{ n }}{{ m(1) }}{{ range(2) }}",
+			want:     nil,
+		},
+		{
+			name:     "included file",
+			template: "{{ dag_id }}{% include 'sensors.j2' %}",
+			files:    map[string]string{"sensors.j2": "{{ sensor.bucket }}"},
+			want:     []string{"dag_id", "sensor.bucket"},
+		},
+		{
+			name:     "extended template",
+			template: "{% extends 'base.j2' %}{% block body %}{{ schedule }}{% endblock %}",
+			files:    map[string]string{"base.j2": "{{ dag_id }}{% block body %}{{ unused }}{% endblock %}"},
+			want:     []string{"dag_id", "schedule"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usage, err := Variables(tt.template, tt.files)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := usage.Required(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Required() = %q, want %q", got, tt.want)
+			}
+			if !usage.Complete {
+				t.Error("usage is not complete")
+			}
+		})
+	}
+}
+
+func TestVariablesMissingFile(t *testing.T) {
+	usage, err := Variables("{{ dag_id }}{% include 'sensors.j2' %}", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Complete {
+		t.Error("usage is complete without the included file")
+	}
+	_, unused, err := usage.Check(`{"dag_id": "orders", "sensor": {}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unused != nil {
+		t.Errorf("unused = %q, want none for an incomplete usage", unused)
+	}
+}
+
+func TestUsageCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		template   string
+		context    string
+		wantMissed []string
+		wantUnused []string
+	}{
+		{
+			name:     "complete context",
+			template: "{{ dag_id }}{% for t in tasks %}{{ t.task_id }}{% endfor %}",
+			context:  `{"dag_id": "orders", "tasks": [{"task_id": "extract"}]}`,
+		},
+		{
+			name:       "missing key",
+			template:   "{{ dag_id }}{{ schedule }}",
+			context:    `{"dag_id": "orders"}`,
+			wantMissed: []string{"schedule"},
+		},
+		{
+			name:       "missing key of each element",
+			template:   "{% for t in tasks %}{{ t.task_id }}{% endfor %}",
+			context:    `{"tasks": [{"task_id": "extract"}, {}, {}]}`,
+			wantMissed: []string{"tasks 1 task_id", "tasks 2 task_id"},
+		},
+		{
+			name:       "key of a string",
+			template:   "{{ dag_id.foo }}",
+			context:    `{"dag_id": "orders"}`,
+			wantMissed: []string{"dag_id foo"},
+		},
+		{
+			name:       "key of a number, a list and null",
+			template:   "{{ retries.count }}{{ tasks.first }}{{ owner.email }}",
+			context:    `{"retries": 3, "tasks": [], "owner": null}`,
+			wantMissed: []string{"owner email", "retries count", "tasks first"},
+		},
+		{
+			name:     "method of a string",
+			template: "{{ dag_id.upper() }}",
+			context:  `{"dag_id": "orders"}`,
+		},
+		{
+			name:     "loop over a string",
+			template: "{% for c in dag_id %}{{ c }}{% endfor %}",
+			context:  `{"dag_id": "orders"}`,
+		},
+		{
+			name:     "optional key",
+			template: "{{ owner | default('data') }}",
+			context:  `{}`,
+		},
+		{
+			name:       "unused keys",
+			template:   "{{ dag_id }}",
+			context:    `{"dag_id": "orders", "tags": [], "owner": "data"}`,
+			wantUnused: []string{"owner", "tags"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usage, err := Variables(tt.template, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			missing, unused, err := usage.Check(tt.context)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var missed []string
+			for _, m := range missing {
+				missed = append(missed, strings.Join(m.Location, " "))
+			}
+			if !reflect.DeepEqual(missed, tt.wantMissed) {
+				t.Errorf("missing = %q, want %q", missed, tt.wantMissed)
+			}
+			if !reflect.DeepEqual(unused, tt.wantUnused) {
+				t.Errorf("unused = %q, want %q", unused, tt.wantUnused)
+			}
+		})
+	}
+}
+
+func TestUsageCheckInvalidContext(t *testing.T) {
+	usage, err := Variables("{{ dag_id }}", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, context := range []string{`[]`, `{"a": 1} {}`} {
+		if _, _, err := usage.Check(context); err == nil {
+			t.Errorf("Check(%s) succeeded", context)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/client"
+)
+
+// AzureScheme prefixes paths in Azure Blob Storage, as az://container/blob.
+const AzureScheme = "az://"
+
+// azureChecksumKey is the blob metadata key the CRC32C checksum is kept under,
+// since Azure Blob Storage does not compute CRC32C checksums itself.
+const azureChecksumKey = "crc32c"
+
+// azureMetadataKey matches the metadata names Azure accepts: C# identifiers,
+// restricted to the ASCII that HTTP headers can carry.
+var azureMetadataKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// CheckAzureMetadataKey returns an error if k cannot name blob metadata, so a
+// key such as source-repo is rejected before anything is uploaded.
+func CheckAzureMetadataKey(k string) error {
+	if !azureMetadataKey.MatchString(k) {
+		return fmt.Errorf("metadata key %q is not a valid Azure metadata name: it must start with a letter or underscore and contain only letters, digits and underscores", k)
+	}
+	if strings.EqualFold(k, azureChecksumKey) {
+		return fmt.Errorf("metadata key %q is reserved for the content checksum", k)
+	}
+	return nil
+}
+
+// Azure stores files in an Azure Blob Storage account or in Azurite.
+//
+// The checksum of a blob is the CRC32C checksum recorded in its metadata when
+// the provider wrote it, and its ETag otherwise. The generation is the blob's
+// version ID when versioning is enabled and its ETag otherwise.
+type Azure struct {
+	client *azblob.Client
+}
+
+var _ Store = &Azure{}
+
+// NewAzure returns an Azure store for the storage account at endpoint, or at
+// https://<account>.blob.core.windows.net/ when endpoint is empty. When
+// AZURE_STORAGE_CONNECTION_STRING is set, as for Azurite, it selects the
+// account and its credentials instead. Otherwise requests are authenticated
+// with the Azure SDK's default credential chain.
+func NewAzure(httpClient *http.Client, account, endpoint string) (*Azure, error) {
+	opts := &azblob.ClientOptions{ClientOptions: policy.ClientOptions{Transport: httpClient}}
+
+	if conn := os.Getenv("AZURE_STORAGE_CONNECTION_STRING"); conn != "" {
+		c, err := azblob.NewClientFromConnectionString(conn, opts)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AZURE_STORAGE_CONNECTION_STRING: %w", err)
+		}
+		return &Azure{client: c}, nil
+	}
+
+	if endpoint == "" {
+		if account == "" {
+			return nil, errors.New("azure_storage_account or azure_endpoint is required for az:// paths")
+		}
+		endpoint = fmt.Sprintf("https://%s.blob.core.windows.net/", account)
+	}
+	cred, err := azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{ClientOptions: opts.ClientOptions})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find Azure credentials: %w", err)
+	}
+	c, err := azblob.NewClient(endpoint, cred, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Azure{client: c}, nil
+}
+
+func splitAzurePath(p string) (container, name string, err error) {
+	if !IsAzure(p) {
+		return "", "", fmt.Errorf("%q is not an %s path", p, AzureScheme)
+	}
+	container, name, ok := strings.Cut(strings.TrimPrefix(p, AzureScheme), "/")
+	if !ok || container == "" || name == "" {
+		return "", "", fmt.Errorf("%q must have the form %scontainer/blob", p, AzureScheme)
+	}
+	return container, name, nil
+}
+
+func (a *Azure) blobClient(p string) (*blockblob.Client, error) {
+	container, name, err := splitAzurePath(p)
+	if err != nil {
+		return nil, err
+	}
+	return a.client.ServiceClient().NewContainerClient(container).NewBlockBlobClient(name), nil
+}
+
+// azureError maps Blob Storage error codes to this package's errors.
+func azureError(err error, p string) error {
+	switch {
+	case bloberror.HasCode(err, bloberror.BlobNotFound, bloberror.ContainerNotFound):
+		return fmt.Errorf("%w: %s", ErrNotFound, p)
+	case bloberror.HasCode(err, bloberror.ConditionNotMet, bloberror.BlobAlreadyExists):
+		return fmt.Errorf("%w: %s", ErrPreconditionFailed, p)
+	}
+	var respErr *azcore.ResponseError
+	if errors.As(err, &respErr) {
+		switch respErr.StatusCode {
+		case http.StatusNotFound:
+			return fmt.Errorf("%w: %s", ErrNotFound, p)
+		case http.StatusPreconditionFailed:
+			return fmt.Errorf("%w: %s", ErrPreconditionFailed, p)
+		}
+	}
+	return err
+}
+
+func azureGeneration(versionID *string, etag *azcore.ETag) string {
+	if v := deref(versionID); v != "" {
+		return v
+	}
+	if etag == nil {
+		return ""
+	}
+	return strings.Trim(string(*etag), `"`)
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// Read returns the content of the blob at p.
+func (a *Azure) Read(ctx context.Context, p string) ([]byte, error) {
+	bc, err := a.blobClient(p)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := bc.DownloadStream(ctx, nil)
+	if err != nil {
+		return nil, azureError(err, p)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	return io.ReadAll(resp.Body)
+}
+
+// Write uploads content to p in a single request, with its MD5 hash so Azure
+// rejects a corrupted upload and its CRC32C checksum in the blob metadata. A
+// generation precondition is checked against the blob's current state and
+// enforced with If-Match on its ETag, so a concurrent write in between fails.
+func (a *Azure) Write(ctx context.Context, p string, content []byte, opts WriteOptions) (*Object, error) {
+	bc, err := a.blobClient(p)
+	if err != nil {
+		return nil, err
+	}
+
+	checksum := client.CRC32C(content)
+	contentType := opts.contentType(p)
+	sum := md5.Sum(content)
+
+	metadata := map[string]*string{azureChecksumKey: &checksum}
+	for k, v := range opts.Metadata {
+		if err := CheckAzureMetadataKey(k); err != nil {
+			return nil, err
+		}
+		metadata[k] = &v
+	}
+	headers := &blob.HTTPHeaders{BlobContentType: &contentType}
+	if opts.CacheControl != "" {
+		headers.BlobCacheControl = &opts.CacheControl
+	}
+
+	uploadOpts := &blockblob.UploadOptions{
+		Metadata:                metadata,
+		HTTPHeaders:             headers,
+		TransactionalValidation: blob.TransferValidationTypeMD5(sum[:]),
+	}
+
+	switch opts.IfGenerationMatch {
+	case "":
+	case "0":
+		etagAny := azcore.ETagAny
+		uploadOpts.AccessConditions = &blob.AccessConditions{
+			ModifiedAccessConditions: &blob.ModifiedAccessConditions{IfNoneMatch: &etagAny},
+		}
+	default:
+		props, err := bc.GetProperties(ctx, nil)
+		if err != nil {
+			err = azureError(err, p)
+			if errors.Is(err, ErrNotFound) {
+				return nil, fmt.Errorf("%w: %s no longer exists", ErrPreconditionFailed, p)
+			}
+			return nil, err
+		}
+		if current := azureGeneration(props.VersionID, props.ETag); current != opts.IfGenerationMatch {
+			return nil, fmt.Errorf("%w: %s is at generation %s, expected %s", ErrPreconditionFailed, p, current, opts.IfGenerationMatch)
+		}
+		uploadOpts.AccessConditions = &blob.AccessConditions{
+			ModifiedAccessConditions: &blob.ModifiedAccessConditions{IfMatch: props.ETag},
+		}
+	}
+
+	resp, err := bc.Upload(ctx, streaming.NopCloser(bytes.NewReader(content)), uploadOpts)
+	if err != nil {
+		return nil, azureError(err, p)
+	}
+
+	obj := &Object{
+		Checksum:     checksum,
+		Generation:   azureGeneration(resp.VersionID, resp.ETag),
+		Size:         int64(len(content)),
+		ContentType:  contentType,
+		CacheControl: opts.CacheControl,
+		Metadata:     lowerKeys(opts.Metadata),
+	}
+	if resp.LastModified != nil {
+		obj.LastModified = resp.LastModified.UTC().Format(time.RFC3339)
+	}
+	return obj, nil
+}
+
+// Stat returns the metadata of the blob at p.
+func (a *Azure) Stat(ctx context.Context, p string) (*Object, error) {
+	bc, err := a.blobClient(p)
+	if err != nil {
+		return nil, err
+	}
+	props, err := bc.GetProperties(ctx, nil)
+	if err != nil {
+		return nil, azureError(err, p)
+	}
+
+	var checksum string
+	metadata := map[string]string{}
+	for k, v := range props.Metadata {
+		if strings.EqualFold(k, azureChecksumKey) {
+			checksum = deref(v)
+		} else {
+			metadata[strings.ToLower(k)] = deref(v)
+		}
+	}
+	if checksum == "" && props.ETag != nil {
+		checksum = strings.Trim(string(*props.ETag), `"`)
+	}
+
+	obj := &Object{
+		Checksum:     checksum,
+		Generation:   azureGeneration(props.VersionID, props.ETag),
+		ContentType:  deref(props.ContentType),
+		CacheControl: deref(props.CacheControl),
+		Metadata:     metadata,
+	}
+	if props.ContentLength != nil {
+		obj.Size = *props.ContentLength
+	}
+	if props.LastModified != nil {
+		obj.LastModified = props.LastModified.UTC().Format(time.RFC3339)
+	}
+	return obj, nil
+}
+
+// Delete removes the blob at p. A missing blob is not an error.
+func (a *Azure) Delete(ctx context.Context, p string) error {
+	bc, err := a.blobClient(p)
+	if err != nil {
+		return err
+	}
+	if _, err := bc.Delete(ctx, nil); err != nil {
+		if err = azureError(err, p); errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package storage
+
+import "testing"
+
+func TestCheckAzureMetadataKey(t *testing.T) {
+	tests := []struct {
+		key     string
+		wantErr bool
+	}{
+		{key: "owner"},
+		{key: "source_repo"},
+		{key: "_private"},
+		{key: "team2"},
+		{key: "source-repo", wantErr: true},
+		{key: "2team", wantErr: true},
+		{key: "dag.id", wantErr: true},
+		{key: "", wantErr: true},
+		{key: "CRC32C", wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := CheckAzureMetadataKey(tt.key); (err != nil) != tt.wantErr {
+			t.Errorf("CheckAzureMetadataKey(%q) error = %v, wantErr %v", tt.key, err, tt.wantErr)
+		}
+	}
+}
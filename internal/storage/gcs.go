@@ -0,0 +1,286 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mm-aranda/terraform-provider-mirage/internal/client"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// GCSScheme prefixes paths in Google Cloud Storage.
+const GCSScheme = "gs://"
+
+// DefaultGCSEndpoint is the Cloud Storage JSON API endpoint.
+const DefaultGCSEndpoint = "https://storage.googleapis.com"
+
+// GCS stores files in Google Cloud Storage through its JSON API.
+type GCS struct {
+	// HTTPClient sends the requests. It is expected to add credentials, unless
+	// the endpoint is an emulator that does not need them.
+	HTTPClient *http.Client
+	// Endpoint is the base URL of the API, DefaultGCSEndpoint when empty.
+	Endpoint string
+}
+
+var _ Store = &GCS{}
+
+// gcsScope is the OAuth2 scope needed to read and write objects.
+const gcsScope = "https://www.googleapis.com/auth/devstorage.read_write"
+
+// NewGCS returns a GCS store that sends requests through base, authenticated
+// with Application Default Credentials. When STORAGE_EMULATOR_HOST is set,
+// as for the Google Cloud client libraries, requests go to that host without
+// credentials instead, which suits fake-gcs-server and test servers.
+func NewGCS(ctx context.Context, base *http.Client, endpoint string) (*GCS, error) {
+	if host := os.Getenv("STORAGE_EMULATOR_HOST"); host != "" {
+		if !strings.Contains(host, "://") {
+			host = "http://" + host
+		}
+		return &GCS{HTTPClient: base, Endpoint: host}, nil
+	}
+
+	ts, err := google.DefaultTokenSource(ctx, gcsScope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find GCP credentials for direct GCS access: %w", err)
+	}
+	httpClient := &http.Client{
+		Transport: &oauth2.Transport{Source: ts, Base: base.Transport},
+		Timeout:   base.Timeout,
+	}
+	return &GCS{HTTPClient: httpClient, Endpoint: endpoint}, nil
+}
+
+// gcsObject matches the object resource of the JSON API.
+type gcsObject struct {
+	Generation   string            `json:"generation"`
+	CRC32C       string            `json:"crc32c"`
+	Size         string            `json:"size"`
+	ContentType  string            `json:"contentType"`
+	CacheControl string            `json:"cacheControl"`
+	Updated      string            `json:"updated"`
+	Metadata     map[string]string `json:"metadata"`
+}
+
+func (o *gcsObject) object() (*Object, error) {
+	size, err := strconv.ParseInt(o.Size, 10, 64)
+	if err != nil && o.Size != "" {
+		return nil, fmt.Errorf("invalid object size %q: %w", o.Size, err)
+	}
+	return &Object{
+		Checksum:     o.CRC32C,
+		Generation:   o.Generation,
+		Size:         size,
+		ContentType:  o.ContentType,
+		CacheControl: o.CacheControl,
+		LastModified: o.Updated,
+		Metadata:     o.Metadata,
+	}, nil
+}
+
+func splitGCSPath(p string) (bucket, object string, err error) {
+	if !IsGCS(p) {
+		return "", "", fmt.Errorf("%q is not a %s path", p, GCSScheme)
+	}
+	bucket, object, ok := strings.Cut(strings.TrimPrefix(p, GCSScheme), "/")
+	if !ok || bucket == "" || object == "" {
+		return "", "", fmt.Errorf("%q must have the form %sbucket/object", p, GCSScheme)
+	}
+	return bucket, object, nil
+}
+
+func (g *GCS) endpoint() string {
+	if g.Endpoint == "" {
+		return DefaultGCSEndpoint
+	}
+	return strings.TrimSuffix(g.Endpoint, "/")
+}
+
+func (g *GCS) objectURL(p string, query url.Values) (string, error) {
+	bucket, object, err := splitGCSPath(p)
+	if err != nil {
+		return "", err
+	}
+	u := fmt.Sprintf("%s/storage/v1/b/%s/o/%s", g.endpoint(), url.PathEscape(bucket), url.PathEscape(object))
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u, nil
+}
+
+// do sends req and maps the JSON API's status codes to this package's errors.
+// The caller must close the body of the returned response.
+func (g *GCS) do(req *http.Request, p string) (*http.Response, error) {
+	resp, err := g.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+	body, _ := io.ReadAll(resp.Body)
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, p)
+	case http.StatusPreconditionFailed:
+		return nil, fmt.Errorf("%w: %s", ErrPreconditionFailed, p)
+	default:
+		return nil, fmt.Errorf("GCS returned status %d for %s: %s", resp.StatusCode, p, strings.TrimSpace(string(body)))
+	}
+}
+
+// Read returns the content of the object at p.
+func (g *GCS) Read(ctx context.Context, p string) ([]byte, error) {
+	u, err := g.objectURL(p, url.Values{"alt": {"media"}})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := g.do(req, p)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	return io.ReadAll(resp.Body)
+}
+
+// Write uploads content to p in a single multipart request. The CRC32C
+// checksum is sent with the metadata so GCS rejects a corrupted upload, and is
+// compared with the checksum GCS reports for the stored object.
+func (g *GCS) Write(ctx context.Context, p string, content []byte, opts WriteOptions) (*Object, error) {
+	bucket, object, err := splitGCSPath(p)
+	if err != nil {
+		return nil, err
+	}
+
+	checksum := client.CRC32C(content)
+	contentType := opts.contentType(object)
+
+	metadata, err := json.Marshal(struct {
+		Name         string            `json:"name"`
+		CRC32C       string            `json:"crc32c"`
+		ContentType  string            `json:"contentType"`
+		CacheControl string            `json:"cacheControl,omitempty"`
+		Metadata     map[string]string `json:"metadata,omitempty"`
+	}{
+		Name:         object,
+		CRC32C:       checksum,
+		ContentType:  contentType,
+		CacheControl: opts.CacheControl,
+		Metadata:     opts.Metadata,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/json; charset=UTF-8"}})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := part.Write(metadata); err != nil {
+		return nil, err
+	}
+	part, err = mw.CreatePart(textproto.MIMEHeader{"Content-Type": {contentType}})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := part.Write(content); err != nil {
+		return nil, err
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	query := url.Values{"uploadType": {"multipart"}}
+	if opts.IfGenerationMatch != "" {
+		query.Set("ifGenerationMatch", opts.IfGenerationMatch)
+	}
+	u := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?%s", g.endpoint(), url.PathEscape(bucket), query.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "multipart/related; boundary="+mw.Boundary())
+
+	resp, err := g.do(req, p)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var stored gcsObject
+	if err := json.NewDecoder(resp.Body).Decode(&stored); err != nil {
+		return nil, err
+	}
+	if stored.CRC32C != "" && stored.CRC32C != checksum {
+		return nil, fmt.Errorf("checksum mismatch after uploading %s: sent %s, stored %s", p, checksum, stored.CRC32C)
+	}
+	if stored.CRC32C == "" {
+		stored.CRC32C = checksum
+	}
+	return stored.object()
+}
+
+// Stat returns the metadata of the object at p.
+func (g *GCS) Stat(ctx context.Context, p string) (*Object, error) {
+	u, err := g.objectURL(p, nil)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := g.do(req, p)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var obj gcsObject
+	if err := json.NewDecoder(resp.Body).Decode(&obj); err != nil {
+		return nil, err
+	}
+	return obj.object()
+}
+
+// Delete removes the object at p. A missing object is not an error.
+func (g *GCS) Delete(ctx context.Context, p string) error {
+	u, err := g.objectURL(p, nil)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := g.do(req, p)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	_ = resp.Body.Close()
+	return nil
+}
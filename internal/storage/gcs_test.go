@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/mm-aranda/terraform-provider-mirage/internal/client"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/storage/storagetest"
+)
+
+func newTestGCS(t *testing.T) (*GCS, *storagetest.GCSServer) {
+	t.Helper()
+	server := storagetest.NewGCSServer(t)
+	return &GCS{HTTPClient: http.DefaultClient, Endpoint: server.URL}, server
+}
+
+func TestGCSWriteReadStatDelete(t *testing.T) {
+	ctx := context.Background()
+	gcs, server := newTestGCS(t)
+	const p = "gs://bucket/dags/orders.py"
+	content := []byte("dag_id = 'orders'\n")
+
+	written, err := gcs.Write(ctx, p, content, WriteOptions{
+		IfGenerationMatch: "0",
+		CacheControl:      "no-cache",
+		Metadata:          map[string]string{"owner": "data"},
+	})
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if want := client.CRC32C(content); written.Checksum != want {
+		t.Errorf("checksum = %q, want %q", written.Checksum, want)
+	}
+	if written.Generation == "" {
+		t.Error("generation is empty")
+	}
+	if got, ok := server.Object(p); !ok || string(got) != string(content) {
+		t.Errorf("stored %q, want %q", got, content)
+	}
+
+	read, err := gcs.Read(ctx, p)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if string(read) != string(content) {
+		t.Errorf("Read = %q, want %q", read, content)
+	}
+
+	stat, err := gcs.Stat(ctx, p)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if stat.Checksum != written.Checksum || stat.Generation != written.Generation {
+		t.Errorf("Stat = %+v, want checksum %s and generation %s", stat, written.Checksum, written.Generation)
+	}
+	if stat.ContentType != "text/x-python; charset=utf-8" && stat.ContentType != "text/x-python" {
+		t.Errorf("content type = %q, want the type of a .py file", stat.ContentType)
+	}
+	if stat.CacheControl != "no-cache" {
+		t.Errorf("cache control = %q, want no-cache", stat.CacheControl)
+	}
+	if stat.Metadata["owner"] != "data" {
+		t.Errorf("metadata = %v, want owner=data", stat.Metadata)
+	}
+
+	if err := gcs.Delete(ctx, p); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := gcs.Stat(ctx, p); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat after Delete: err = %v, want ErrNotFound", err)
+	}
+	if _, err := gcs.Read(ctx, p); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Read after Delete: err = %v, want ErrNotFound", err)
+	}
+	if err := gcs.Delete(ctx, p); err != nil {
+		t.Errorf("Delete of a missing object: %v", err)
+	}
+}
+
+func TestGCSWriteIfGenerationMatch(t *testing.T) {
+	ctx := context.Background()
+	gcs, server := newTestGCS(t)
+	const p = "gs://bucket/a.py"
+
+	first, err := gcs.Write(ctx, p, []byte("a = 1\n"), WriteOptions{IfGenerationMatch: "0"})
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if _, err := gcs.Write(ctx, p, []byte("a = 2\n"), WriteOptions{IfGenerationMatch: "0"}); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("Write over an existing object with generation 0: err = %v, want ErrPreconditionFailed", err)
+	}
+
+	// Someone else rewrites the object.
+	server.Put(p, []byte("a = 3\n"))
+	if _, err := gcs.Write(ctx, p, []byte("a = 2\n"), WriteOptions{IfGenerationMatch: first.Generation}); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("Write with a stale generation: err = %v, want ErrPreconditionFailed", err)
+	}
+	if got, _ := server.Object(p); string(got) != "a = 3\n" {
+		t.Errorf("a failed precondition overwrote the object with %q", got)
+	}
+}
+
+func TestGCSWriteServerError(t *testing.T) {
+	gcs, server := newTestGCS(t)
+	const p = "gs://bucket/a.py"
+	server.FailWrites(p, true)
+
+	_, err := gcs.Write(context.Background(), p, []byte("a = 1\n"), WriteOptions{})
+	if err == nil || errors.Is(err, ErrNotFound) || errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("Write: err = %v, want a server error", err)
+	}
+}
+
+func TestGCSObjectNamesAreEscaped(t *testing.T) {
+	ctx := context.Background()
+	gcs, server := newTestGCS(t)
+	const p = "gs://bucket/dags/team a/orders#1.py"
+
+	if _, err := gcs.Write(ctx, p, []byte("a = 1\n"), WriteOptions{}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if _, ok := server.Object(p); !ok {
+		t.Fatalf("the object was not stored at %s", p)
+	}
+	if _, err := gcs.Stat(ctx, p); err != nil {
+		t.Errorf("Stat: %v", err)
+	}
+}
+
+func TestSplitGCSPath(t *testing.T) {
+	tests := []struct {
+		p              string
+		bucket, object string
+		wantErr        bool
+	}{
+		{p: "gs://bucket/dags/a.py", bucket: "bucket", object: "dags/a.py"},
+		{p: "gs://bucket", wantErr: true},
+		{p: "gs://bucket/", wantErr: true},
+		{p: "gs:///a.py", wantErr: true},
+		{p: "s3://bucket/a.py", wantErr: true},
+	}
+	for _, tt := range tests {
+		bucket, object, err := splitGCSPath(tt.p)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("splitGCSPath(%q) error = %v, wantErr %v", tt.p, err, tt.wantErr)
+			continue
+		}
+		if bucket != tt.bucket || object != tt.object {
+			t.Errorf("splitGCSPath(%q) = %q, %q, want %q, %q", tt.p, bucket, object, tt.bucket, tt.object)
+		}
+	}
+}
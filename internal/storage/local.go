@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mm-aranda/terraform-provider-mirage/internal/client"
+)
+
+// LocalScheme prefixes paths on the local filesystem.
+const LocalScheme = "file://"
+
+// LocalFS stores files on the local filesystem. Paths are file:// URLs whose
+// remainder is an absolute path (file:///opt/dags/a.py) or a path relative to
+// the working directory (file://dags/a.py).
+type LocalFS struct{}
+
+var _ Store = LocalFS{}
+
+func localPath(p string) (string, error) {
+	if !IsLocal(p) {
+		return "", fmt.Errorf("%q is not a %s path", p, LocalScheme)
+	}
+	name := strings.TrimPrefix(p, LocalScheme)
+	if name == "" {
+		return "", fmt.Errorf("%q has no file path", p)
+	}
+	return filepath.FromSlash(name), nil
+}
+
+// Read returns the content of the file at p.
+func (LocalFS) Read(_ context.Context, p string) ([]byte, error) {
+	name, err := localPath(p)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, p)
+	}
+	return content, err
+}
+
+// Write atomically replaces the file at p with content, creating parent
+// directories as needed. Readers such as the Airflow scheduler never observe
+// a partially written file. The generation precondition is checked just
+// before the write, which is not atomic with it.
+func (l LocalFS) Write(ctx context.Context, p string, content []byte, opts WriteOptions) (*Object, error) {
+	name, err := localPath(p)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.ContentType != "" || opts.CacheControl != "" || len(opts.Metadata) > 0 {
+		return nil, fmt.Errorf("%s: local files cannot have a content type, cache control or metadata", p)
+	}
+
+	if opts.IfGenerationMatch != "" {
+		current := "0"
+		obj, err := l.Stat(ctx, p)
+		if err == nil {
+			current = obj.Generation
+		} else if !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+		if !localGenerationMatch(current, opts.IfGenerationMatch) {
+			return nil, fmt.Errorf("%w: %s is at generation %s, expected %s", ErrPreconditionFailed, p, current, opts.IfGenerationMatch)
+		}
+	}
+
+	dir := filepath.Dir(name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(name)+".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(content); err != nil {
+		_ = tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return nil, err
+	}
+
+	return l.Stat(ctx, p)
+}
+
+// Stat returns the metadata of the file at p. The generation combines the
+// file's modification time in nanoseconds with its size and CRC32C checksum,
+// so two writes within the timestamp granularity of the filesystem (a second
+// or more on some) still yield different generations unless they wrote the
+// same content.
+func (LocalFS) Stat(_ context.Context, p string) (*Object, error) {
+	name, err := localPath(p)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, p)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", p)
+	}
+
+	content, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Object{
+		Checksum:     client.CRC32C(content),
+		Generation:   fmt.Sprintf("%d-%d-%08x", info.ModTime().UnixNano(), info.Size(), crc32.Checksum(content, crc32c)),
+		Size:         info.Size(),
+		LastModified: info.ModTime().UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// crc32c is the Castagnoli table, the checksum GCS uses.
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// localGenerationMatch reports whether want names generation current. Earlier
+// versions used the modification time alone as the generation, which state
+// may still hold; it matches the generation it is a prefix of.
+func localGenerationMatch(current, want string) bool {
+	if current == want {
+		return true
+	}
+	return want != "0" && !strings.Contains(want, "-") && strings.HasPrefix(current, want+"-")
+}
+
+// Delete removes the file at p. A missing file is not an error.
+func (LocalFS) Delete(_ context.Context, p string) error {
+	name, err := localPath(p)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
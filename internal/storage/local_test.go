@@ -0,0 +1,204 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mm-aranda/terraform-provider-mirage/internal/client"
+)
+
+func TestLocalFSWriteReadStatDelete(t *testing.T) {
+	ctx := context.Background()
+	p := LocalScheme + filepath.ToSlash(filepath.Join(t.TempDir(), "dags", "a.py"))
+	content := []byte("print('hello')\n")
+
+	var l LocalFS
+	written, err := l.Write(ctx, p, content, WriteOptions{IfGenerationMatch: "0"})
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if want := client.CRC32C(content); written.Checksum != want {
+		t.Errorf("checksum = %q, want %q", written.Checksum, want)
+	}
+	if written.Generation == "" || written.Generation == "0" {
+		t.Errorf("generation = %q, want a pseudo-generation", written.Generation)
+	}
+	if written.Size != int64(len(content)) {
+		t.Errorf("size = %d, want %d", written.Size, len(content))
+	}
+
+	read, err := l.Read(ctx, p)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if string(read) != string(content) {
+		t.Errorf("Read = %q, want %q", read, content)
+	}
+
+	stat, err := l.Stat(ctx, p)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if stat.Checksum != written.Checksum || stat.Generation != written.Generation {
+		t.Errorf("Stat = %+v, want %+v", stat, written)
+	}
+
+	if err := l.Delete(ctx, p); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := l.Stat(ctx, p); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat after Delete: err = %v, want ErrNotFound", err)
+	}
+	if _, err := l.Read(ctx, p); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Read after Delete: err = %v, want ErrNotFound", err)
+	}
+	if err := l.Delete(ctx, p); err != nil {
+		t.Errorf("Delete of a missing file: %v", err)
+	}
+}
+
+func TestLocalFSWriteIfGenerationMatch(t *testing.T) {
+	ctx := context.Background()
+	p := LocalScheme + filepath.ToSlash(filepath.Join(t.TempDir(), "a.py"))
+
+	var l LocalFS
+	first, err := l.Write(ctx, p, []byte("a = 1\n"), WriteOptions{IfGenerationMatch: "0"})
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	if _, err := l.Write(ctx, p, []byte("a = 2\n"), WriteOptions{IfGenerationMatch: "0"}); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("Write over an existing file with generation 0: err = %v, want ErrPreconditionFailed", err)
+	}
+	if _, err := l.Write(ctx, p, []byte("a = 2\n"), WriteOptions{IfGenerationMatch: "1"}); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("Write with a stale generation: err = %v, want ErrPreconditionFailed", err)
+	}
+	if _, err := l.Write(ctx, p, []byte("a = 2\n"), WriteOptions{IfGenerationMatch: first.Generation}); err != nil {
+		t.Errorf("Write with the current generation: %v", err)
+	}
+}
+
+func TestLocalFSWriteLeavesNoTemporaryFiles(t *testing.T) {
+	dir := t.TempDir()
+	p := LocalScheme + filepath.ToSlash(filepath.Join(dir, "a.py"))
+
+	var l LocalFS
+	if _, err := l.Write(context.Background(), p, []byte("a = 1\n"), WriteOptions{}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "a.py" {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("directory holds %v, want only a.py", names)
+	}
+}
+
+func TestLocalFSRejectsObjectMetadata(t *testing.T) {
+	p := LocalScheme + filepath.ToSlash(filepath.Join(t.TempDir(), "a.py"))
+
+	var l LocalFS
+	for _, opts := range []WriteOptions{
+		{ContentType: "text/x-python"},
+		{CacheControl: "no-cache"},
+		{Metadata: map[string]string{"owner": "data"}},
+	} {
+		if _, err := l.Write(context.Background(), p, []byte("a = 1\n"), opts); err == nil {
+			t.Errorf("Write(%+v) succeeded, want an error", opts)
+		}
+	}
+}
+
+func TestLocalPath(t *testing.T) {
+	tests := []struct {
+		p       string
+		want    string
+		wantErr bool
+	}{
+		{p: "file:///opt/dags/a.py", want: filepath.FromSlash("/opt/dags/a.py")},
+		{p: "file://dags/a.py", want: filepath.FromSlash("dags/a.py")},
+		{p: "file://", wantErr: true},
+		{p: "gs://bucket/a.py", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := localPath(tt.p)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("localPath(%q) error = %v, wantErr %v", tt.p, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("localPath(%q) = %q, want %q", tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestLocalFSGenerationWithinTimestampGranularity(t *testing.T) {
+	ctx := context.Background()
+	name := filepath.Join(t.TempDir(), "a.py")
+	p := LocalScheme + filepath.ToSlash(name)
+
+	var l LocalFS
+	first, err := l.Write(ctx, p, []byte("a = 1\n"), WriteOptions{})
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A filesystem with coarse timestamps gives the next write the same
+	// modification time.
+	if _, err := l.Write(ctx, p, []byte("a = 2\n"), WriteOptions{}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := os.Chtimes(name, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	second, err := l.Stat(ctx, p)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if second.Generation == first.Generation {
+		t.Errorf("two writes of different content share generation %s", first.Generation)
+	}
+}
+
+func TestLocalFSWriteIfGenerationMatchLegacyGeneration(t *testing.T) {
+	ctx := context.Background()
+	name := filepath.Join(t.TempDir(), "a.py")
+	p := LocalScheme + filepath.ToSlash(name)
+
+	var l LocalFS
+	if _, err := l.Write(ctx, p, []byte("a = 1\n"), WriteOptions{}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// State written by earlier versions holds the modification time alone.
+	legacy := strconv.FormatInt(info.ModTime().UnixNano(), 10)
+	if _, err := l.Write(ctx, p, []byte("a = 2\n"), WriteOptions{IfGenerationMatch: legacy}); err != nil {
+		t.Errorf("Write with the current legacy generation: %v", err)
+	}
+	later := info.ModTime().Add(time.Second)
+	if err := os.Chtimes(name, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Write(ctx, p, []byte("a = 3\n"), WriteOptions{IfGenerationMatch: legacy}); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("Write with a stale legacy generation: err = %v, want ErrPreconditionFailed", err)
+	}
+}
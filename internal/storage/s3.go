@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/client"
+)
+
+// S3Scheme prefixes paths in Amazon S3 and S3-compatible stores.
+const S3Scheme = "s3://"
+
+// S3 stores files in Amazon S3 or an S3-compatible store such as MinIO.
+//
+// The checksum of an object is its CRC32C checksum when S3 has one, which is
+// the case for every object written by the provider, and its ETag otherwise.
+// The generation is the object's version ID in versioned buckets and its ETag
+// otherwise.
+type S3 struct {
+	client *s3.Client
+}
+
+var _ Store = &S3{}
+
+// NewS3 returns an S3 store using the AWS SDK's default credential chain and
+// region settings. A non-empty endpoint, such as a MinIO server, is used
+// with path-style addressing.
+func NewS3(ctx context.Context, httpClient *http.Client, endpoint string) (*S3, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+
+	return &S3{client: s3.NewFromConfig(cfg, func(o *s3.Options) {
+		o.HTTPClient = httpClient
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			o.UsePathStyle = true
+		}
+	})}, nil
+}
+
+func splitS3Path(p string) (bucket, key string, err error) {
+	if !IsS3(p) {
+		return "", "", fmt.Errorf("%q is not a %s path", p, S3Scheme)
+	}
+	bucket, key, ok := strings.Cut(strings.TrimPrefix(p, S3Scheme), "/")
+	if !ok || bucket == "" || key == "" {
+		return "", "", fmt.Errorf("%q must have the form %sbucket/key", p, S3Scheme)
+	}
+	return bucket, key, nil
+}
+
+// s3Error maps S3 error codes to this package's errors.
+func s3Error(err error, p string) error {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "NoSuchKey", "NotFound":
+			return fmt.Errorf("%w: %s", ErrNotFound, p)
+		case "PreconditionFailed", "ConditionalRequestConflict":
+			return fmt.Errorf("%w: %s", ErrPreconditionFailed, p)
+		}
+	}
+	return err
+}
+
+func s3Generation(versionID, etag *string) string {
+	if v := aws.ToString(versionID); v != "" && v != "null" {
+		return v
+	}
+	return strings.Trim(aws.ToString(etag), `"`)
+}
+
+// Read returns the content of the object at p.
+func (s *S3) Read(ctx context.Context, p string) ([]byte, error) {
+	bucket, key, err := splitS3Path(p)
+	if err != nil {
+		return nil, err
+	}
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		return nil, s3Error(err, p)
+	}
+	defer func() { _ = out.Body.Close() }()
+	return io.ReadAll(out.Body)
+}
+
+// Write uploads content to p with its CRC32C checksum, which S3 verifies. A
+// generation precondition is checked against the object's current state and
+// enforced with If-Match on its ETag, so a concurrent write in between fails.
+func (s *S3) Write(ctx context.Context, p string, content []byte, opts WriteOptions) (*Object, error) {
+	bucket, key, err := splitS3Path(p)
+	if err != nil {
+		return nil, err
+	}
+
+	checksum := client.CRC32C(content)
+	contentType := opts.contentType(key)
+
+	input := &s3.PutObjectInput{
+		Bucket:         aws.String(bucket),
+		Key:            aws.String(key),
+		Body:           bytes.NewReader(content),
+		ContentType:    aws.String(contentType),
+		ChecksumCRC32C: aws.String(checksum),
+		Metadata:       opts.Metadata,
+	}
+	if opts.CacheControl != "" {
+		input.CacheControl = aws.String(opts.CacheControl)
+	}
+
+	switch opts.IfGenerationMatch {
+	case "":
+	case "0":
+		input.IfNoneMatch = aws.String("*")
+	default:
+		head, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+		if err != nil {
+			err = s3Error(err, p)
+			if errors.Is(err, ErrNotFound) {
+				return nil, fmt.Errorf("%w: %s no longer exists", ErrPreconditionFailed, p)
+			}
+			return nil, err
+		}
+		if current := s3Generation(head.VersionId, head.ETag); current != opts.IfGenerationMatch {
+			return nil, fmt.Errorf("%w: %s is at generation %s, expected %s", ErrPreconditionFailed, p, current, opts.IfGenerationMatch)
+		}
+		input.IfMatch = head.ETag
+	}
+
+	out, err := s.client.PutObject(ctx, input)
+	if err != nil {
+		return nil, s3Error(err, p)
+	}
+	if stored := aws.ToString(out.ChecksumCRC32C); stored != "" && stored != checksum {
+		return nil, fmt.Errorf("checksum mismatch after uploading %s: sent %s, stored %s", p, checksum, stored)
+	}
+
+	return &Object{
+		Checksum:     checksum,
+		Generation:   s3Generation(out.VersionId, out.ETag),
+		Size:         int64(len(content)),
+		ContentType:  contentType,
+		CacheControl: opts.CacheControl,
+		LastModified: time.Now().UTC().Format(time.RFC3339),
+		Metadata:     lowerKeys(opts.Metadata),
+	}, nil
+}
+
+// Stat returns the metadata of the object at p.
+func (s *S3) Stat(ctx context.Context, p string) (*Object, error) {
+	bucket, key, err := splitS3Path(p)
+	if err != nil {
+		return nil, err
+	}
+	head, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:       aws.String(bucket),
+		Key:          aws.String(key),
+		ChecksumMode: types.ChecksumModeEnabled,
+	})
+	if err != nil {
+		return nil, s3Error(err, p)
+	}
+
+	checksum := aws.ToString(head.ChecksumCRC32C)
+	if checksum == "" {
+		checksum = strings.Trim(aws.ToString(head.ETag), `"`)
+	}
+	obj := &Object{
+		Checksum:     checksum,
+		Generation:   s3Generation(head.VersionId, head.ETag),
+		Size:         aws.ToInt64(head.ContentLength),
+		ContentType:  aws.ToString(head.ContentType),
+		CacheControl: aws.ToString(head.CacheControl),
+		Metadata:     lowerKeys(head.Metadata),
+	}
+	if head.LastModified != nil {
+		obj.LastModified = head.LastModified.UTC().Format(time.RFC3339)
+	}
+	return obj, nil
+}
+
+// Delete removes the object at p. A missing object is not an error.
+func (s *S3) Delete(ctx context.Context, p string) error {
+	bucket, key, err := splitS3Path(p)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		if err = s3Error(err, p); errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+// Package storage reads and writes generated files directly, for targets the
+// provider manages without going through the backend service.
+package storage
+
+import (
+	"context"
+	"errors"
+	"mime"
+	"path"
+	"strings"
+)
+
+// ErrNotFound is returned when an object does not exist.
+var ErrNotFound = errors.New("object not found")
+
+// ErrPreconditionFailed is returned when a conditional write or delete finds
+// a different generation than expected.
+var ErrPreconditionFailed = errors.New("object generation does not match the expected generation")
+
+// Object describes a stored file.
+type Object struct {
+	// Checksum is the CRC32C checksum in GCS format, or the ETag for objects
+	// in S3 or Azure Blob Storage that have no CRC32C checksum.
+	Checksum string
+	// Generation identifies the version of the object. Each write changes it.
+	Generation   string
+	Size         int64
+	ContentType  string
+	CacheControl string
+	LastModified string
+	// Metadata holds the custom metadata of the object. S3 and Azure do not
+	// preserve the case of keys and report them in lowercase. Metadata the
+	// stores keep for themselves is not included.
+	Metadata map[string]string
+}
+
+// WriteOptions control a Write.
+type WriteOptions struct {
+	// IfGenerationMatch makes the write fail with ErrPreconditionFailed unless
+	// the object's current generation matches. "0" requires that the object
+	// does not exist. Empty means unconditional.
+	IfGenerationMatch string
+	// ContentType is the object's content type. When empty it is derived from
+	// the file extension.
+	ContentType string
+	// CacheControl sets the object's Cache-Control header.
+	CacheControl string
+	// Metadata sets custom metadata on the object.
+	//
+	// Local files have none of these, so LocalFS rejects writes that set them.
+	Metadata map[string]string
+}
+
+// contentType returns the content type to store p with.
+func (o WriteOptions) contentType(p string) string {
+	if o.ContentType != "" {
+		return o.ContentType
+	}
+	if t := mime.TypeByExtension(path.Ext(p)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// Store is a place generated files and templates are kept, addressed by
+// scheme-prefixed paths such as gs://bucket/dags/a.py or
+// s3://bucket/dags/a.py.
+type Store interface {
+	Read(ctx context.Context, p string) ([]byte, error)
+	Write(ctx context.Context, p string, content []byte, opts WriteOptions) (*Object, error)
+	Stat(ctx context.Context, p string) (*Object, error)
+	// Delete removes the object at p. A missing object is not an error.
+	Delete(ctx context.Context, p string) error
+}
+
+// IsLocal reports whether p is a file:// path.
+func IsLocal(p string) bool {
+	return strings.HasPrefix(p, LocalScheme)
+}
+
+// IsGCS reports whether p is a gs:// path.
+func IsGCS(p string) bool {
+	return strings.HasPrefix(p, GCSScheme)
+}
+
+// IsS3 reports whether p is an s3:// path.
+func IsS3(p string) bool {
+	return strings.HasPrefix(p, S3Scheme)
+}
+
+// IsAzure reports whether p is an az:// path.
+func IsAzure(p string) bool {
+	return strings.HasPrefix(p, AzureScheme)
+}
+
+// lowerKeys returns m with lowercase keys, as S3 and Azure report metadata.
+func lowerKeys(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	lower := make(map[string]string, len(m))
+	for k, v := range m {
+		lower[strings.ToLower(k)] = v
+	}
+	return lower
+}
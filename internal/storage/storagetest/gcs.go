@@ -0,0 +1,228 @@
+// Package storagetest provides in-memory stand-ins for the object stores the
+// storage package talks to, for tests that must run offline.
+package storagetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mm-aranda/terraform-provider-mirage/internal/client"
+)
+
+// GCSServer is a fake of the parts of the Cloud Storage JSON API the storage
+// package uses: object metadata and media downloads, multipart uploads with
+// generation preconditions, and deletes. Objects are addressed by their
+// gs://bucket/object path.
+type GCSServer struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	objects    map[string]*gcsObject
+	generation int64
+	failWrites map[string]bool
+}
+
+type gcsObject struct {
+	content      []byte
+	generation   int64
+	contentType  string
+	cacheControl string
+	metadata     map[string]string
+	updated      time.Time
+}
+
+// NewGCSServer starts a fake GCS server, closed when the test ends.
+func NewGCSServer(t *testing.T) *GCSServer {
+	t.Helper()
+	s := &GCSServer{objects: map[string]*gcsObject{}, failWrites: map[string]bool{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Put stores content at the gs:// path p, as if uploaded outside the
+// provider, and returns its new generation.
+func (s *GCSServer) Put(p string, content []byte) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return strconv.FormatInt(s.put(strings.TrimPrefix(p, "gs://"), content, "", "", nil).generation, 10)
+}
+
+// Object returns the content of the object at the gs:// path p.
+func (s *GCSServer) Object(p string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, ok := s.objects[strings.TrimPrefix(p, "gs://")]
+	if !ok {
+		return nil, false
+	}
+	return obj.content, true
+}
+
+// Metadata returns the custom metadata of the object at the gs:// path p.
+func (s *GCSServer) Metadata(p string) map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if obj, ok := s.objects[strings.TrimPrefix(p, "gs://")]; ok {
+		return obj.metadata
+	}
+	return nil
+}
+
+// FailWrites makes uploads to the gs:// path p fail with a server error, or
+// succeed again when fail is false.
+func (s *GCSServer) FailWrites(p string, fail bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failWrites[strings.TrimPrefix(p, "gs://")] = fail
+}
+
+func (s *GCSServer) put(key string, content []byte, contentType, cacheControl string, metadata map[string]string) *gcsObject {
+	s.generation++
+	obj := &gcsObject{
+		content:      content,
+		generation:   s.generation,
+		contentType:  contentType,
+		cacheControl: cacheControl,
+		metadata:     metadata,
+		updated:      time.Now().UTC(),
+	}
+	s.objects[key] = obj
+	return obj
+}
+
+func (s *GCSServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	segments := strings.Split(strings.TrimPrefix(r.URL.EscapedPath(), "/"), "/")
+	for i, segment := range segments {
+		unescaped, err := url.PathUnescape(segment)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		segments[i] = unescaped
+	}
+
+	switch {
+	case r.Method == http.MethodPost && len(segments) == 6 && segments[0] == "upload" && segments[5] == "o":
+		s.upload(w, r, segments[4])
+	case len(segments) == 6 && segments[0] == "storage" && segments[2] == "b" && segments[4] == "o":
+		key := segments[3] + "/" + segments[5]
+		switch r.Method {
+		case http.MethodGet:
+			s.get(w, r, key)
+		case http.MethodDelete:
+			if _, ok := s.objects[key]; !ok {
+				http.Error(w, "not found", http.StatusNotFound)
+				return
+			}
+			delete(s.objects, key)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
+
+func (s *GCSServer) get(w http.ResponseWriter, r *http.Request, key string) {
+	obj, ok := s.objects[key]
+	if !ok {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if r.URL.Query().Get("alt") == "media" {
+		_, _ = w.Write(obj.content)
+		return
+	}
+	writeGCSObject(w, obj)
+}
+
+func (s *GCSServer) upload(w http.ResponseWriter, r *http.Request, bucket string) {
+	if r.URL.Query().Get("uploadType") != "multipart" {
+		http.Error(w, "only multipart uploads are supported", http.StatusBadRequest)
+		return
+	}
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/related" {
+		http.Error(w, "expected a multipart/related body", http.StatusBadRequest)
+		return
+	}
+
+	mr := multipart.NewReader(r.Body, params["boundary"])
+	part, err := mr.NextPart()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var meta struct {
+		Name         string            `json:"name"`
+		CRC32C       string            `json:"crc32c"`
+		ContentType  string            `json:"contentType"`
+		CacheControl string            `json:"cacheControl"`
+		Metadata     map[string]string `json:"metadata"`
+	}
+	if err := json.NewDecoder(part).Decode(&meta); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	part, err = mr.NextPart()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	content, err := io.ReadAll(part)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	key := bucket + "/" + meta.Name
+	if s.failWrites[key] {
+		http.Error(w, "injected failure", http.StatusInternalServerError)
+		return
+	}
+	if meta.CRC32C != "" && meta.CRC32C != client.CRC32C(content) {
+		http.Error(w, "crc32c does not match the uploaded content", http.StatusBadRequest)
+		return
+	}
+	if want := r.URL.Query().Get("ifGenerationMatch"); want != "" {
+		current := "0"
+		if obj, ok := s.objects[key]; ok {
+			current = strconv.FormatInt(obj.generation, 10)
+		}
+		if current != want {
+			http.Error(w, fmt.Sprintf("generation is %s, not %s", current, want), http.StatusPreconditionFailed)
+			return
+		}
+	}
+
+	writeGCSObject(w, s.put(key, content, meta.ContentType, meta.CacheControl, meta.Metadata))
+}
+
+func writeGCSObject(w http.ResponseWriter, obj *gcsObject) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"generation":   strconv.FormatInt(obj.generation, 10),
+		"crc32c":       client.CRC32C(obj.content),
+		"size":         strconv.Itoa(len(obj.content)),
+		"contentType":  obj.contentType,
+		"cacheControl": obj.cacheControl,
+		"updated":      obj.updated.Format(time.RFC3339Nano),
+		"metadata":     obj.metadata,
+	})
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mm-aranda/terraform-provider-mirage/internal/client"
+)
+
+// testStore checks the behaviour every Store shares, writing objects under
+// base, such as s3://bucket/prefix. Stores without object metadata are
+// checked without it.
+func testStore(t *testing.T, store Store, base string, withMetadata bool) {
+	t.Helper()
+	ctx := context.Background()
+	p := fmt.Sprintf("%s/mirage-test-%d/orders.py", base, time.Now().UnixNano())
+	content := []byte("dag_id = 'orders'\n")
+	t.Cleanup(func() { _ = store.Delete(context.Background(), p) })
+
+	if _, err := store.Stat(ctx, p); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Stat of a missing object: err = %v, want ErrNotFound", err)
+	}
+	if _, err := store.Read(ctx, p); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Read of a missing object: err = %v, want ErrNotFound", err)
+	}
+
+	opts := WriteOptions{IfGenerationMatch: "0"}
+	if withMetadata {
+		opts.CacheControl = "no-cache"
+		opts.Metadata = map[string]string{"Owner": "data"}
+	}
+	written, err := store.Write(ctx, p, content, opts)
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if want := client.CRC32C(content); written.Checksum != want {
+		t.Errorf("checksum = %q, want %q", written.Checksum, want)
+	}
+	if written.Generation == "" {
+		t.Error("generation is empty")
+	}
+
+	read, err := store.Read(ctx, p)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if string(read) != string(content) {
+		t.Errorf("Read = %q, want %q", read, content)
+	}
+
+	stat, err := store.Stat(ctx, p)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if stat.Checksum != written.Checksum || stat.Generation != written.Generation {
+		t.Errorf("Stat = %+v, want checksum %s and generation %s", stat, written.Checksum, written.Generation)
+	}
+	if withMetadata {
+		if stat.CacheControl != "no-cache" {
+			t.Errorf("cache control = %q, want no-cache", stat.CacheControl)
+		}
+		if stat.Metadata["owner"] != "data" && stat.Metadata["Owner"] != "data" {
+			t.Errorf("metadata = %v, want owner=data", stat.Metadata)
+		}
+	}
+
+	if _, err := store.Write(ctx, p, []byte("a = 2\n"), WriteOptions{IfGenerationMatch: "0"}); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("Write over an existing object with generation 0: err = %v, want ErrPreconditionFailed", err)
+	}
+	rewritten, err := store.Write(ctx, p, []byte("a = 2\n"), WriteOptions{IfGenerationMatch: written.Generation})
+	if err != nil {
+		t.Fatalf("Write with the current generation: %v", err)
+	}
+	if rewritten.Generation == written.Generation {
+		t.Error("the generation did not change on rewrite")
+	}
+	if _, err := store.Write(ctx, p, []byte("a = 3\n"), WriteOptions{IfGenerationMatch: written.Generation}); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("Write with a stale generation: err = %v, want ErrPreconditionFailed", err)
+	}
+
+	if err := store.Delete(ctx, p); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Stat(ctx, p); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat after Delete: err = %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(ctx, p); err != nil {
+		t.Errorf("Delete of a missing object: %v", err)
+	}
+}
+
+// The S3 and Azure stores are tested against MinIO and Azurite, or a real
+// bucket and storage account, when the environment points at them:
+//
+//	MIRAGE_TEST_S3_BUCKET=mirage MIRAGE_TEST_S3_ENDPOINT=http://localhost:9000 \
+//	AWS_ACCESS_KEY_ID=minioadmin AWS_SECRET_ACCESS_KEY=minioadmin go test ./...
+//
+//	MIRAGE_TEST_AZURE_CONTAINER=mirage \
+//	AZURE_STORAGE_CONNECTION_STRING="UseDevelopmentStorage=true" go test ./...
+//
+// MIRAGE_TEST_S3_ENDPOINT may be left out for Amazon S3. The bucket and
+// container must exist.
+
+func TestS3Store(t *testing.T) {
+	bucket := os.Getenv("MIRAGE_TEST_S3_BUCKET")
+	if bucket == "" {
+		t.Skip("MIRAGE_TEST_S3_BUCKET is not set")
+	}
+	s3, err := NewS3(context.Background(), http.DefaultClient, os.Getenv("MIRAGE_TEST_S3_ENDPOINT"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, s3, S3Scheme+bucket, true)
+}
+
+func TestAzureStore(t *testing.T) {
+	container := os.Getenv("MIRAGE_TEST_AZURE_CONTAINER")
+	if container == "" {
+		t.Skip("MIRAGE_TEST_AZURE_CONTAINER is not set")
+	}
+	if os.Getenv("AZURE_STORAGE_CONNECTION_STRING") == "" {
+		t.Skip("AZURE_STORAGE_CONNECTION_STRING is not set")
+	}
+	azure, err := NewAzure(http.DefaultClient, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, azure, AzureScheme+container, true)
+}
+
+func TestGCSStore(t *testing.T) {
+	gcs, _ := newTestGCS(t)
+	testStore(t, gcs, GCSScheme+"bucket", true)
+}
+
+func TestLocalFSStore(t *testing.T) {
+	testStore(t, LocalFS{}, LocalScheme+t.TempDir(), false)
+}
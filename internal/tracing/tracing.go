@@ -0,0 +1,120 @@
+// Package tracing wires the provider into OpenTelemetry. Tracing is off unless
+// enabled through the standard OTEL_* environment variables, in which case
+// spans are exported over OTLP.
+package tracing
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/mm-aranda/terraform-provider-mirage"
+
+// Enabled reports whether the OTEL_* environment asks for trace export. The
+// provider only traces when an OTLP endpoint is configured or
+// OTEL_TRACES_EXPORTER is explicitly set to "otlp".
+func Enabled() bool {
+	if strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") {
+		return false
+	}
+	switch strings.ToLower(os.Getenv("OTEL_TRACES_EXPORTER")) {
+	case "none":
+		return false
+	case "otlp":
+		return true
+	}
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// Setup installs a global OTLP tracer provider when tracing is enabled. The
+// returned function flushes pending spans and must be called before exit; it
+// is a no-op when tracing is disabled.
+func Setup(ctx context.Context, version string) (func(context.Context) error, error) {
+	if !Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := newExporter(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tp, err := NewTracerProvider(ctx, version, sdktrace.WithBatcher(exporter))
+	if err != nil {
+		return nil, err
+	}
+	Install(tp)
+	return tp.Shutdown, nil
+}
+
+// NewTracerProvider creates a tracer provider describing the provider binary.
+// Tests pass sdktrace.WithSyncer with an in-memory exporter.
+func NewTracerProvider(ctx context.Context, version string, opts ...sdktrace.TracerProviderOption) (*sdktrace.TracerProvider, error) {
+	res, err := resource.New(ctx,
+		resource.WithAttributes(
+			semconv.ServiceName("terraform-provider-mirage"),
+			semconv.ServiceVersion(version),
+		),
+		// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES take precedence.
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return sdktrace.NewTracerProvider(append([]sdktrace.TracerProviderOption{sdktrace.WithResource(res)}, opts...)...), nil
+}
+
+// Install makes tp the global tracer provider and enables W3C trace context propagation.
+func Install(tp trace.TracerProvider) {
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+}
+
+func newExporter(ctx context.Context) (sdktrace.SpanExporter, error) {
+	protocol := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL")
+	if protocol == "" {
+		protocol = os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
+	}
+	// Endpoint, headers, TLS and timeouts are read from OTEL_EXPORTER_OTLP_* by the exporters.
+	if protocol == "grpc" {
+		return otlptracegrpc.New(ctx)
+	}
+	return otlptracehttp.New(ctx)
+}
+
+// Tracer returns the provider's tracer from the global tracer provider.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start starts a span for a Terraform operation such as a resource CRUD call.
+func Start(ctx context.Context, name string, attrs ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, attrs...)
+}
+
+// End records the outcome held in diags on span and ends it.
+func End(span trace.Span, diags diag.Diagnostics) {
+	if diags.HasError() {
+		for _, d := range diags.Errors() {
+			span.AddEvent("diagnostic", trace.WithAttributes(
+				semconv.ExceptionMessage(d.Summary()+": "+d.Detail()),
+			))
+		}
+		span.SetStatus(codes.Error, diags.Errors()[0].Summary())
+	} else {
+		span.SetStatus(codes.Ok, "")
+	}
+	span.End()
+}
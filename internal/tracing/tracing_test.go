@@ -0,0 +1,161 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/mm-aranda/terraform-provider-mirage/internal/client"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/tracing"
+)
+
+// installExporter makes an in-memory exporter receive every span ended
+// during the test.
+func installExporter(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	tp, err := tracing.NewTracerProvider(context.Background(), "test", sdktrace.WithSyncer(exporter))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	tracing.Install(tp)
+	t.Cleanup(func() {
+		_ = tp.Shutdown(context.Background())
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+	return exporter
+}
+
+// backend answers the first failures requests with a 503 and every later one
+// with a 200.
+func backend(t *testing.T, failures int32) *httptest.Server {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if calls.Add(1) <= failures {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"status": "SUCCESS"}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newService(t *testing.T, baseURL string, maxRetries int) *client.DagGeneratorService {
+	t.Helper()
+	c, err := client.NewDagGeneratorAPIClientWithOptions(baseURL, client.ClientOptions{MaxRetries: maxRetries})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &client.DagGeneratorService{Client: c}
+}
+
+// read mimics a resource Read: a CRUD span around one backend call.
+func read(svc *client.DagGeneratorService) error {
+	var diags diag.Diagnostics
+	ctx, span := tracing.Start(context.Background(), "mirage_dag_generator.Read")
+	_, err := svc.GetStatus(ctx, "gs://bucket/dag.py")
+	if err != nil {
+		diags.AddError("Error Reading DAG Status", err.Error())
+	}
+	tracing.End(span, diags)
+	return err
+}
+
+func spanNamed(t *testing.T, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	t.Helper()
+	for _, s := range spans {
+		if s.Name == name {
+			return s
+		}
+	}
+	t.Fatalf("no %q span among %d spans", name, len(spans))
+	return tracetest.SpanStub{}
+}
+
+func intAttr(s tracetest.SpanStub, key attribute.Key) (int64, bool) {
+	for _, kv := range s.Attributes {
+		if kv.Key == key {
+			return kv.Value.AsInt64(), true
+		}
+	}
+	return 0, false
+}
+
+func TestClientSpanUnderCRUDSpan(t *testing.T) {
+	exporter := installExporter(t)
+	if err := read(newService(t, backend(t, 1).URL, 2)); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want the CRUD span and one client span", len(spans))
+	}
+	crud := spanNamed(t, spans, "mirage_dag_generator.Read")
+	call := spanNamed(t, spans, "GET /status")
+
+	if call.Parent.SpanID() != crud.SpanContext.SpanID() || call.SpanContext.TraceID() != crud.SpanContext.TraceID() {
+		t.Error("the client span is not a child of the CRUD span")
+	}
+	if call.SpanKind != trace.SpanKindClient {
+		t.Errorf("client span kind = %v, want %v", call.SpanKind, trace.SpanKindClient)
+	}
+	if crud.Status.Code != codes.Ok || call.Status.Code == codes.Error {
+		t.Errorf("statuses = %v and %v, want no error", crud.Status.Code, call.Status.Code)
+	}
+	if retries, ok := intAttr(call, "mirage.retry_count"); !ok || retries != 1 {
+		t.Errorf("mirage.retry_count = %d (set: %t), want 1", retries, ok)
+	}
+	if status, _ := intAttr(call, "http.response.status_code"); status != http.StatusOK {
+		t.Errorf("http.response.status_code = %d, want 200", status)
+	}
+	for _, s := range []tracetest.SpanStub{crud, call} {
+		if !s.EndTime.After(s.StartTime) {
+			t.Errorf("%s: end time %v is not after start time %v", s.Name, s.EndTime, s.StartTime)
+		}
+	}
+	if call.StartTime.Before(crud.StartTime) || call.EndTime.After(crud.EndTime) {
+		t.Error("the client span does not fall within the CRUD span")
+	}
+}
+
+func TestFailedCallSpanStatus(t *testing.T) {
+	exporter := installExporter(t)
+	err := read(newService(t, backend(t, 3).URL, 1))
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("err = %v, want a 503 API error", err)
+	}
+
+	spans := exporter.GetSpans()
+	crud := spanNamed(t, spans, "mirage_dag_generator.Read")
+	call := spanNamed(t, spans, "GET /status")
+	if crud.Status.Code != codes.Error || crud.Status.Description != "Error Reading DAG Status" {
+		t.Errorf("CRUD span status = %v %q, want an error naming the diagnostic", crud.Status.Code, crud.Status.Description)
+	}
+	if len(crud.Events) == 0 || crud.Events[0].Name != "diagnostic" {
+		t.Errorf("CRUD span events = %v, want a diagnostic event", crud.Events)
+	}
+	if call.Status.Code != codes.Error {
+		t.Errorf("client span status = %v, want an error", call.Status.Code)
+	}
+	if retries, _ := intAttr(call, "mirage.retry_count"); retries != 1 {
+		t.Errorf("mirage.retry_count = %d, want 1 after exhausting the retries", retries)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 
 	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/tracing"
 	"github.com/mm-aranda/terraform-provider-mirage/provider"
 )
 
@@ -13,11 +14,24 @@ import (
 var version = "dev"
 
 func main() {
-	err := providerserver.Serve(context.Background(), provider.New(version), providerserver.ServeOpts{
+	ctx := context.Background()
+
+	// Tracing is only enabled when the OTEL_* environment variables ask for it.
+	shutdownTracing, err := tracing.Setup(ctx, version)
+	if err != nil {
+		log.Printf("[WARN] OpenTelemetry tracing disabled: %v", err)
+		shutdownTracing = func(context.Context) error { return nil }
+	}
+
+	err = providerserver.Serve(ctx, provider.New(version), providerserver.ServeOpts{
 		// This address must match the one in your Terraform configurations.
 		Address: "localhost/my-org/mirage",
 	})
 
+	if shutdownErr := shutdownTracing(ctx); shutdownErr != nil {
+		log.Printf("[WARN] Failed to flush OpenTelemetry spans: %v", shutdownErr)
+	}
+
 	if err != nil {
 		log.Fatal(err.Error())
 	}
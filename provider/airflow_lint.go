@@ -0,0 +1,279 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/airflowlint"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/client"
+)
+
+// airflowLintModel is the airflow_lint attribute of mirage_dag_generator.
+type airflowLintModel struct {
+	AirflowVersion  types.String `tfsdk:"airflow_version"`
+	DuplicateTaskID types.String `tfsdk:"duplicate_task_id"`
+	DependencyCycle types.String `tfsdk:"dependency_cycle"`
+	DagIDMismatch   types.String `tfsdk:"dag_id_mismatch"`
+	InvalidSchedule types.String `tfsdk:"invalid_schedule"`
+	Deprecated      types.String `tfsdk:"deprecated"`
+}
+
+var airflowLintAttrTypes = map[string]attr.Type{
+	"airflow_version":   types.StringType,
+	"duplicate_task_id": types.StringType,
+	"dependency_cycle":  types.StringType,
+	"dag_id_mismatch":   types.StringType,
+	"invalid_schedule":  types.StringType,
+	"deprecated":        types.StringType,
+}
+
+// severities returns the configured severity of each rule, null where unset.
+func (m airflowLintModel) severities() map[airflowlint.Rule]types.String {
+	return map[airflowlint.Rule]types.String{
+		airflowlint.DuplicateTaskID: m.DuplicateTaskID,
+		airflowlint.DependencyCycle: m.DependencyCycle,
+		airflowlint.DagIDMismatch:   m.DagIDMismatch,
+		airflowlint.InvalidSchedule: m.InvalidSchedule,
+		airflowlint.Deprecated:      m.Deprecated,
+	}
+}
+
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+	severityOff     = "off"
+)
+
+// defaultSeverities are the severities of rules not set in airflow_lint.
+var defaultSeverities = map[airflowlint.Rule]string{
+	airflowlint.DuplicateTaskID: severityError,
+	airflowlint.DependencyCycle: severityError,
+	airflowlint.DagIDMismatch:   severityError,
+	airflowlint.InvalidSchedule: severityError,
+	airflowlint.Deprecated:      severityWarning,
+}
+
+// lintSummaries are the diagnostic summaries of the findings of each rule.
+var lintSummaries = map[airflowlint.Rule]string{
+	airflowlint.DuplicateTaskID: "Duplicate Task ID",
+	airflowlint.DependencyCycle: "Dependency Cycle",
+	airflowlint.DagIDMismatch:   "DAG ID Mismatch",
+	airflowlint.InvalidSchedule: "Invalid Schedule",
+	airflowlint.Deprecated:      "Deprecated Airflow Usage",
+}
+
+func airflowLintSchema() schema.SingleNestedAttribute {
+	severity := func(rule, def string) schema.StringAttribute {
+		return schema.StringAttribute{
+			Description: fmt.Sprintf("Severity of %s: `error`, `warning` or `off`. Defaults to `%s`.", rule, def),
+			Optional:    true,
+		}
+	}
+	return schema.SingleNestedAttribute{
+		Description: "Lints the rendered file for Airflow mistakes before it is written. Each issue found is reported as a diagnostic of its rule's severity, and an `error` fails the apply and leaves the previous generation of the file in place. With the backend, the file is read back and linted after it is written, so an `error` fails the apply with the new file already in place.",
+		Optional:    true,
+		Attributes: map[string]schema.Attribute{
+			"airflow_version": schema.StringAttribute{
+				Description: "The Airflow version the file targets, such as `2.9`, which decides what `deprecated` reports. Every known deprecation is reported when omitted.",
+				Optional:    true,
+			},
+			"duplicate_task_id": severity("a `task_id` used twice in the same DAG", severityError),
+			"dependency_cycle":  severity("tasks whose `>>` and `<<` dependencies form a cycle", severityError),
+			"dag_id_mismatch":   severity("a file none of whose DAGs has the `dag_id` from `context_json`", severityError),
+			"invalid_schedule":  severity("a `schedule` or `schedule_interval` string that is neither a preset nor a valid cron expression", severityError),
+			"deprecated":        severity("modules and arguments deprecated in `airflow_version`, such as `schedule_interval` from Airflow 2.4", severityWarning),
+		},
+	}
+}
+
+// validateAirflowLint checks the configured airflow_lint attribute.
+func validateAirflowLint(ctx context.Context, lint types.Object, diags *diag.Diagnostics) {
+	if lint.IsNull() || lint.IsUnknown() {
+		return
+	}
+	var m airflowLintModel
+	diags.Append(lint.As(ctx, &m, basetypes.ObjectAsOptions{})...)
+	if diags.HasError() {
+		return
+	}
+	for rule, value := range m.severities() {
+		if value.IsNull() || value.IsUnknown() {
+			continue
+		}
+		switch value.ValueString() {
+		case severityError, severityWarning, severityOff:
+		default:
+			diags.AddAttributeError(
+				path.Root("airflow_lint").AtName(string(rule)),
+				"Invalid Configuration",
+				fmt.Sprintf("`airflow_lint.%s` must be %q, %q or %q, not %q.", rule, severityError, severityWarning, severityOff, value.ValueString()),
+			)
+		}
+	}
+	if !m.AirflowVersion.IsNull() && !m.AirflowVersion.IsUnknown() {
+		if _, err := airflowlint.ParseVersion(m.AirflowVersion.ValueString()); err != nil {
+			diags.AddAttributeError(
+				path.Root("airflow_lint").AtName("airflow_version"),
+				"Invalid Configuration",
+				fmt.Sprintf("`airflow_lint.airflow_version` must be a version such as \"2.9\": %v.", err),
+			)
+		}
+	}
+}
+
+// errAirflowLint fails a generation whose rendered file has an error-level
+// lint finding, or cannot be linted. The findings themselves are reported as
+// diagnostics.
+var errAirflowLint = errors.New("the rendered file failed the Airflow lint")
+
+// airflowLinter lints the files of a mirage_dag_generator.
+type airflowLinter struct {
+	opts     airflowlint.Options
+	severity map[airflowlint.Rule]string
+
+	// content and findings are those of the file last checked.
+	content  string
+	findings []airflowlint.Finding
+}
+
+// newAirflowLinter returns the linter configured by the airflow_lint
+// attribute, or nil if it is not set. The expected dag_id is the "dag_id" key
+// of the context.
+func newAirflowLinter(ctx context.Context, lint types.Object, contextJSON string) (*airflowLinter, diag.Diagnostics) {
+	if lint.IsNull() || lint.IsUnknown() {
+		return nil, nil
+	}
+	var m airflowLintModel
+	diags := lint.As(ctx, &m, basetypes.ObjectAsOptions{})
+	if diags.HasError() {
+		return nil, diags
+	}
+
+	l := &airflowLinter{severity: map[airflowlint.Rule]string{}}
+	for rule, value := range m.severities() {
+		l.severity[rule] = defaultSeverities[rule]
+		if !value.IsNull() {
+			l.severity[rule] = value.ValueString()
+		}
+	}
+	if v := m.AirflowVersion.ValueString(); v != "" {
+		version, err := airflowlint.ParseVersion(v)
+		if err != nil {
+			diags.AddAttributeError(path.Root("airflow_lint").AtName("airflow_version"), "Invalid Configuration", err.Error())
+			return nil, diags
+		}
+		l.opts.AirflowVersion = version
+	}
+	var vars struct {
+		DagID any `json:"dag_id"`
+	}
+	if json.Unmarshal([]byte(contextJSON), &vars) == nil {
+		if id, ok := vars.DagID.(string); ok {
+			l.opts.DagID = id
+		}
+	}
+	return l, diags
+}
+
+// check lints content, the rendered file. It returns errAirflowLint if a
+// finding has error severity, and the error of checkPython if content is not
+// valid Python.
+func (l *airflowLinter) check(content string) error {
+	findings, err := airflowlint.Lint(content, l.opts)
+	if err != nil {
+		return checkPython(content)
+	}
+	l.content = content
+	l.findings = l.findings[:0]
+	failed := false
+	for _, f := range findings {
+		switch l.severity[f.Rule] {
+		case severityError:
+			failed = true
+		case severityOff:
+			continue
+		}
+		l.findings = append(l.findings, f)
+	}
+	if failed {
+		return errAirflowLint
+	}
+	return nil
+}
+
+// report adds a diagnostic for each finding of the last check of the file at
+// targetPath. written tells whether the file was written nonetheless, as it
+// is when the backend generates it.
+func (l *airflowLinter) report(diags *diag.Diagnostics, targetPath string, written bool) {
+	for _, f := range l.findings {
+		attrPath := path.Root("airflow_lint").AtName(string(f.Rule))
+		detail := fmt.Sprintf("%s, %s%s", targetPath, f, quoteLine(l.content, f.Line, f.Col))
+		if l.severity[f.Rule] != severityError {
+			diags.AddAttributeWarning(attrPath, lintSummaries[f.Rule], detail)
+			continue
+		}
+		if written {
+			detail += "\n\nThe backend wrote the file before it could be linted. Fix the template and apply again."
+		} else {
+			detail += fmt.Sprintf("\n\n%s was not written and its previous generation, if any, is left in place.", targetPath)
+		}
+		diags.AddAttributeError(attrPath, lintSummaries[f.Rule], detail)
+	}
+}
+
+// generateLinted generates a file with gen and lints it with linter, if set.
+// When the provider renders the file, it is linted before it is written, and
+// an error-level finding fails the generation with errAirflowLint. The
+// backend renders and writes the file itself, so it is read back and linted
+// afterwards, and an error-level finding fails the generation all the same,
+// though the file is already written.
+func generateLinted(ctx context.Context, gen generator, genReq client.GenerateRequest, linter *airflowLinter, diags *diag.Diagnostics) (*client.GenerateResponse, error) {
+	if linter == nil {
+		return gen.Generate(ctx, genReq)
+	}
+
+	if direct, ok := gen.(*directGenerator); ok {
+		linted := *direct
+		linted.check = linter.check
+		result, err := linted.Generate(ctx, genReq)
+		if err == nil || errors.Is(err, errAirflowLint) {
+			linter.report(diags, genReq.TargetGCSPath, false)
+		}
+		return result, err
+	}
+
+	result, err := gen.Generate(ctx, genReq)
+	if err != nil {
+		return nil, err
+	}
+	status, err := gen.GetStatusWithOptions(ctx, genReq.TargetGCSPath, client.StatusOptions{IncludeContent: true})
+	if err != nil {
+		diags.AddWarning(
+			"Could not lint generated file",
+			fmt.Sprintf("Unable to read %s back from the backend to lint it: %v", genReq.TargetGCSPath, err),
+		)
+		return result, nil
+	}
+	err = linter.check(status.Content)
+	if err != nil && !errors.Is(err, errAirflowLint) {
+		diags.AddAttributeError(
+			path.Root("airflow_lint"),
+			"Invalid Python",
+			fmt.Sprintf("%s could not be linted: %v", genReq.TargetGCSPath, err),
+		)
+		return nil, errAirflowLint
+	}
+	linter.report(diags, genReq.TargetGCSPath, true)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,105 @@
+package provider
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/client"
+)
+
+// duplicateTaskDAG renders a DAG that uses the task ID "extract" twice.
+const duplicateTaskDAG = `from airflow import DAG
+from airflow.operators.empty import EmptyOperator
+
+with DAG("orders", schedule="@daily") as dag:
+    EmptyOperator(task_id="extract")
+    EmptyOperator(task_id="extract")
+`
+
+// airflowLintValue returns an airflow_lint value with the given attributes
+// set and the others null.
+func airflowLintValue(attrs map[string]string) tftypes.Value {
+	types := map[string]tftypes.Type{}
+	values := map[string]tftypes.Value{}
+	for name := range airflowLintAttrTypes {
+		types[name] = tftypes.String
+		values[name] = tftypes.NewValue(tftypes.String, nil)
+		if v, ok := attrs[name]; ok {
+			values[name] = stringValue(v)
+		}
+	}
+	return tftypes.NewValue(tftypes.Object{AttributeTypes: types}, values)
+}
+
+func TestDagGeneratorAirflowLintKeepsPreviousFile(t *testing.T) {
+	target, file := localTarget(t, "orders.py")
+	r := newTestProvider(t, nil).resource("mirage_dag_generator")
+	lint := airflowLintValue(map[string]string{"airflow_version": "2.9"})
+
+	r.mustApply(map[string]tftypes.Value{
+		"template_content": stringValue("a = 1"),
+		"target_path":      stringValue(target),
+		"airflow_lint":     lint,
+	})
+	diags := r.apply(map[string]tftypes.Value{
+		"template_content": stringValue(duplicateTaskDAG),
+		"target_path":      stringValue(target),
+		"airflow_lint":     lint,
+	})
+	requireError(t, diags, "was not written")
+	if content, _ := os.ReadFile(file); string(content) != "a = 1" {
+		t.Errorf("orders.py holds %q after a failed lint", content)
+	}
+
+	diags = r.apply(map[string]tftypes.Value{
+		"template_content": stringValue(duplicateTaskDAG),
+		"target_path":      stringValue(target),
+		"airflow_lint":     airflowLintValue(map[string]string{"airflow_version": "2.9", "duplicate_task_id": "warning"}),
+	})
+	requireNoErrors(t, "apply with duplicate_task_id a warning", diags)
+	// Like Jinja2, the renderer drops the template's final newline.
+	if content, _ := os.ReadFile(file); string(content) != strings.TrimSuffix(duplicateTaskDAG, "\n") {
+		t.Errorf("orders.py holds %q after a lint warning", content)
+	}
+}
+
+func TestDagGeneratorAirflowLintFailsBackendApply(t *testing.T) {
+	var written string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/generate":
+			var genReq client.GenerateRequest
+			if err := json.NewDecoder(req.Body).Decode(&genReq); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			written = genReq.TemplateContent
+			_ = json.NewEncoder(w).Encode(client.GenerateResponse{Checksum: client.CRC32C([]byte(written)), Generation: "1"})
+		case "/status":
+			_ = json.NewEncoder(w).Encode(client.StatusResponse{Checksum: client.CRC32C([]byte(written)), Generation: "1", Content: written})
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	t.Cleanup(backend.Close)
+	r := newTestProvider(t, nil).resource("mirage_dag_generator")
+
+	diags := r.apply(map[string]tftypes.Value{
+		"template_content":          stringValue(duplicateTaskDAG),
+		"target_path":               stringValue("gs://dags/orders.py"),
+		"dag_generator_backend_url": stringValue(backend.URL),
+		"airflow_lint":              airflowLintValue(map[string]string{"airflow_version": "2.9"}),
+	})
+	requireError(t, diags, "The backend wrote the file before it could be linted")
+	if written != duplicateTaskDAG {
+		t.Errorf("the backend was asked to write %q", written)
+	}
+	if !r.state.IsNull() {
+		t.Error("the failed apply saved state")
+	}
+}
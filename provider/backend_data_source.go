@@ -0,0 +1,159 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var _ datasource.DataSourceWithConfigure = &backendDataSource{}
+
+func NewBackendDataSource() datasource.DataSource {
+	return &backendDataSource{}
+}
+
+type backendDataSource struct {
+	providerData *mirageProviderData
+}
+
+type backendDataSourceModel struct {
+	DagGeneratorBackendURL   types.String `tfsdk:"dag_generator_backend_url"`
+	UseGCPServiceAccountAuth types.Bool   `tfsdk:"use_gcp_service_account_auth"`
+	Headers                  types.Map    `tfsdk:"headers"`
+	ID                       types.String `tfsdk:"id"`
+	Reachable                types.Bool   `tfsdk:"reachable"`
+	APIVersion               types.String `tfsdk:"api_version"`
+	BuildCommit              types.String `tfsdk:"build_commit"`
+	SupportedFeatures        types.List   `tfsdk:"supported_features"`
+	Principal                types.String `tfsdk:"principal"`
+}
+
+func (d *backendDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	data, ok := req.ProviderData.(*mirageProviderData)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Data Source Configure Type",
+			fmt.Sprintf("Expected *mirageProviderData, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+		return
+	}
+	d.providerData = data
+}
+
+func (d *backendDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_backend"
+}
+
+func (d *backendDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Reports the health, version and capabilities of a Mirage backend service.",
+		Attributes: map[string]schema.Attribute{
+			"dag_generator_backend_url": schema.StringAttribute{
+				Description: "The base URL of the backend service.",
+				Required:    true,
+			},
+			"use_gcp_service_account_auth": schema.BoolAttribute{
+				Description: "If true, authenticate requests to the backend using the machine's GCP service account.",
+				Optional:    true,
+			},
+			"headers": schema.MapAttribute{
+				Description: "Headers sent with every backend request, overriding the provider's `default_headers`.",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"id": schema.StringAttribute{
+				Description: "The backend URL.",
+				Computed:    true,
+			},
+			"reachable": schema.BoolAttribute{
+				Description: "Whether the backend answered its `/healthz` endpoint successfully.",
+				Computed:    true,
+			},
+			"api_version": schema.StringAttribute{
+				Description: "The API version reported by the backend.",
+				Computed:    true,
+			},
+			"build_commit": schema.StringAttribute{
+				Description: "The source commit the backend was built from.",
+				Computed:    true,
+			},
+			"supported_features": schema.ListAttribute{
+				Description: "Optional features supported by the backend.",
+				ElementType: types.StringType,
+				Computed:    true,
+			},
+			"principal": schema.StringAttribute{
+				Description: "The identity the backend authenticated the provider as.",
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func (d *backendDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	ctx, span := tracing.Start(ctx, "mirage_backend.Read")
+	defer func() { tracing.End(span, resp.Diagnostics) }()
+
+	var config backendDataSourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	span.SetAttributes(attribute.String("mirage.backend_url", config.DagGeneratorBackendURL.ValueString()))
+
+	headers, diags := stringMapValue(ctx, config.Headers)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	dagGenService, err := d.providerData.newDagGeneratorService(config.DagGeneratorBackendURL.ValueString(), config.UseGCPServiceAccountAuth.ValueBool(), headers)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to create backend client", err.Error())
+		return
+	}
+
+	config.ID = config.DagGeneratorBackendURL
+	config.Reachable = basetypes.NewBoolValue(true)
+	config.APIVersion = basetypes.NewStringValue("")
+	config.BuildCommit = basetypes.NewStringValue("")
+	config.Principal = basetypes.NewStringValue("")
+	config.SupportedFeatures = basetypes.NewListValueMust(types.StringType, nil)
+
+	// An unreachable backend is reported through `reachable` rather than as
+	// an error, so modules can guard on it with preconditions.
+	if err := dagGenService.Client.ProbeHealth(ctx); err != nil {
+		resp.Diagnostics.AddWarning("Backend unreachable", err.Error())
+		config.Reachable = basetypes.NewBoolValue(false)
+		resp.Diagnostics.Append(resp.State.Set(ctx, &config)...)
+		return
+	}
+
+	info, err := dagGenService.GetBackendInfo(ctx)
+	if err != nil {
+		resp.Diagnostics.AddWarning(
+			"Could not get backend info",
+			fmt.Sprintf("The backend is reachable but its version information could not be retrieved: %v", err),
+		)
+		resp.Diagnostics.Append(resp.State.Set(ctx, &config)...)
+		return
+	}
+
+	config.APIVersion = basetypes.NewStringValue(info.APIVersion)
+	config.BuildCommit = basetypes.NewStringValue(info.BuildCommit)
+	config.Principal = basetypes.NewStringValue(info.Principal)
+	features, diags := types.ListValueFrom(ctx, types.StringType, info.Features)
+	resp.Diagnostics.Append(diags...)
+	config.SupportedFeatures = features
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &config)...)
+}
@@ -0,0 +1,114 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/client"
+)
+
+// infoBackend serves /healthz and /info and counts the health probes.
+func infoBackend(t *testing.T, probes *atomic.Int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/healthz":
+			probes.Add(1)
+			_, _ = w.Write([]byte("ok"))
+		case "/info":
+			_ = json.NewEncoder(w).Encode(client.BackendInfo{
+				APIVersion:  "1.4.0",
+				BuildCommit: "3f2a9c1",
+				Features:    []string{"bundles", "lint"},
+				Principal:   "mirage@project.iam.gserviceaccount.com",
+			})
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func stringListAttr(t *testing.T, v tftypes.Value, name string) []string {
+	t.Helper()
+	var elems []tftypes.Value
+	if err := objectAttr(t, v, name).As(&elems); err != nil {
+		t.Fatal(err)
+	}
+	var list []string
+	for _, e := range elems {
+		var s string
+		if err := e.As(&s); err != nil {
+			t.Fatal(err)
+		}
+		list = append(list, s)
+	}
+	return list
+}
+
+func TestBackendDataSource(t *testing.T) {
+	for _, healthCheck := range []bool{false, true} {
+		t.Run(fmt.Sprintf("health_check=%t", healthCheck), func(t *testing.T) {
+			var probes atomic.Int32
+			backend := infoBackend(t, &probes)
+			p := newTestProvider(t, map[string]tftypes.Value{"health_check": boolValue(healthCheck)})
+
+			state, diags := p.readDataSource("mirage_backend", map[string]tftypes.Value{
+				"dag_generator_backend_url": stringValue(backend.URL),
+			})
+			requireNoErrors(t, "Read", diags)
+			if len(diags) != 0 {
+				t.Errorf("unexpected diagnostics:\n%s", formatDiagnostics(diags))
+			}
+
+			if !objectAttr(t, state, "reachable").Equal(boolValue(true)) {
+				t.Error("reachable = false, want true")
+			}
+			for name, want := range map[string]string{
+				"id":           backend.URL,
+				"api_version":  "1.4.0",
+				"build_commit": "3f2a9c1",
+				"principal":    "mirage@project.iam.gserviceaccount.com",
+			} {
+				if got := objectAttr(t, state, name); !got.Equal(stringValue(want)) {
+					t.Errorf("%s = %v, want %q", name, got, want)
+				}
+			}
+			if got := stringListAttr(t, state, "supported_features"); !reflect.DeepEqual(got, []string{"bundles", "lint"}) {
+				t.Errorf("supported_features = %q, want [bundles lint]", got)
+			}
+			if got := probes.Load(); got != 1 {
+				t.Errorf("/healthz was probed %d times, want 1", got)
+			}
+		})
+	}
+}
+
+func TestBackendDataSourceUnreachable(t *testing.T) {
+	backend := httptest.NewServer(http.NotFoundHandler())
+	backend.Close()
+	p := newTestProvider(t, nil)
+
+	state, diags := p.readDataSource("mirage_backend", map[string]tftypes.Value{
+		"dag_generator_backend_url": stringValue(backend.URL),
+	})
+	requireNoErrors(t, "Read", diags)
+	if len(diags) != 1 || diags[0].Severity != tfprotov6.DiagnosticSeverityWarning || !strings.Contains(diags[0].Summary, "unreachable") {
+		t.Errorf("diagnostics = %s, want an unreachable warning", formatDiagnostics(diags))
+	}
+	if !objectAttr(t, state, "reachable").Equal(boolValue(false)) {
+		t.Error("reachable = true, want false")
+	}
+	if !objectAttr(t, state, "api_version").Equal(stringValue("")) {
+		t.Errorf("api_version = %v, want empty", objectAttr(t, state, "api_version"))
+	}
+}
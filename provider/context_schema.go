@@ -0,0 +1,166 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/storage"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// inlineSchemaURL identifies a context_schema given inline.
+const inlineSchemaURL = "mem:///context_schema.json"
+
+// isStoragePath reports whether p is a path the provider can read, rather
+// than an inline document.
+func isStoragePath(p string) bool {
+	return storage.IsGCS(p) || storage.IsS3(p) || storage.IsAzure(p) || storage.IsLocal(p)
+}
+
+// storageLoader loads the schemas a context_schema refers to with $ref from
+// their storage.
+type storageLoader struct {
+	ctx context.Context
+	d   *mirageProviderData
+}
+
+func (l storageLoader) Load(url string) (any, error) {
+	store, err := l.d.store(l.ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	content, err := store.Read(l.ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return jsonschema.UnmarshalJSON(bytes.NewReader(content))
+}
+
+// compileContextSchema compiles a context_schema: a JSON Schema document, or
+// the path of one. Schemas without $schema are read as draft 2020-12, and
+// format is asserted.
+func (d *mirageProviderData) compileContextSchema(ctx context.Context, value string) (*jsonschema.Schema, error) {
+	loader := storageLoader{ctx: ctx, d: d}
+	c := jsonschema.NewCompiler()
+	c.DefaultDraft(jsonschema.Draft2020)
+	c.AssertFormat()
+	c.UseLoader(jsonschema.SchemeURLLoader{"gs": loader, "s3": loader, "az": loader, "file": loader})
+
+	url := strings.TrimSpace(value)
+	if !isStoragePath(url) {
+		doc, err := jsonschema.UnmarshalJSON(strings.NewReader(value))
+		if err != nil {
+			return nil, fmt.Errorf("context_schema is neither a JSON document nor a gs://, s3://, az:// or file:// path: %w", err)
+		}
+		url = inlineSchemaURL
+		if err := c.AddResource(url, doc); err != nil {
+			return nil, err
+		}
+	}
+	return c.Compile(url)
+}
+
+// validateContext validates contextJSON against the context_schema value,
+// adding a diagnostic on context_json for each violation. An empty context is
+// validated as an empty object, as it is rendered.
+func (d *mirageProviderData) validateContext(ctx context.Context, schemaValue, contextJSON string, diags *diag.Diagnostics) {
+	sch, err := d.compileContextSchema(ctx, schemaValue)
+	if err != nil {
+		diags.AddAttributeError(path.Root("context_schema"), "Invalid Context Schema", err.Error())
+		return
+	}
+
+	if strings.TrimSpace(contextJSON) == "" {
+		contextJSON = "{}"
+	}
+	inst, err := jsonschema.UnmarshalJSON(strings.NewReader(contextJSON))
+	if err != nil {
+		diags.AddAttributeError(path.Root("context_json"), "Invalid Context", fmt.Sprintf("context_json is not valid JSON: %v", err))
+		return
+	}
+
+	err = sch.Validate(inst)
+	verr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		if err != nil {
+			diags.AddAttributeError(path.Root("context_json"), "Invalid Context", err.Error())
+		}
+		return
+	}
+
+	var leaves []*jsonschema.ValidationError
+	violations(verr, &leaves)
+	sort.SliceStable(leaves, func(i, j int) bool {
+		return strings.Join(leaves[i].InstanceLocation, "/") < strings.Join(leaves[j].InstanceLocation, "/")
+	})
+	p := message.NewPrinter(language.English)
+	for _, v := range leaves {
+		msg := v.ErrorKind.LocalizedString(p)
+		// The alternatives of a failed anyOf or oneOf are listed below it.
+		for _, c := range v.Causes {
+			var alts []*jsonschema.ValidationError
+			violations(c, &alts)
+			for _, a := range alts {
+				msg += fmt.Sprintf("\n  - at %s: %s", contextLocation(a.InstanceLocation), a.ErrorKind.LocalizedString(p))
+			}
+		}
+		diags.AddAttributeError(
+			path.Root("context_json"),
+			"Invalid Context",
+			fmt.Sprintf("The context does not match context_schema at %s: %s", contextLocation(v.InstanceLocation), msg),
+		)
+	}
+}
+
+// violations collects the errors that make up err: its innermost causes,
+// except that a failed anyOf or oneOf is one violation, whose alternatives
+// are its causes.
+func violations(err *jsonschema.ValidationError, out *[]*jsonschema.ValidationError) {
+	switch err.ErrorKind.(type) {
+	case *kind.AnyOf, *kind.OneOf:
+		*out = append(*out, err)
+		return
+	}
+	if len(err.Causes) == 0 {
+		*out = append(*out, err)
+		return
+	}
+	for _, c := range err.Causes {
+		violations(c, out)
+	}
+}
+
+var contextIdentifierRE = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+var contextIndexRE = regexp.MustCompile(`^[0-9]+$`)
+
+// contextLocation formats the location of a value in the context the way it
+// is referenced in a template, such as tasks[0].task_id.
+func contextLocation(tokens []string) string {
+	if len(tokens) == 0 {
+		return "the top level"
+	}
+	var b strings.Builder
+	for _, tok := range tokens {
+		switch {
+		case contextIndexRE.MatchString(tok):
+			b.WriteString("[" + tok + "]")
+		case contextIdentifierRE.MatchString(tok):
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(tok)
+		default:
+			fmt.Fprintf(&b, "[%q]", tok)
+		}
+	}
+	return b.String()
+}
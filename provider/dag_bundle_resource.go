@@ -0,0 +1,444 @@
+package provider
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/client"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	_ resource.Resource               = &dagBundleResource{}
+	_ resource.ResourceWithConfigure  = &dagBundleResource{}
+	_ resource.ResourceWithModifyPlan = &dagBundleResource{}
+)
+
+func NewDagBundleResource() resource.Resource {
+	return &dagBundleResource{}
+}
+
+type dagBundleResource struct {
+	providerData *mirageProviderData
+}
+
+type dagBundleResourceModel struct {
+	DagGeneratorBackendURL   types.String `tfsdk:"dag_generator_backend_url"`
+	UseGCPServiceAccountAuth types.Bool   `tfsdk:"use_gcp_service_account_auth"`
+	Headers                  types.Map    `tfsdk:"headers"`
+	ContextJSON              types.String `tfsdk:"context_json"`
+	Files                    types.Map    `tfsdk:"files"`
+	ProvenanceHeader         types.Bool   `tfsdk:"provenance_header"`
+	ID                       types.String `tfsdk:"id"`
+	Checksums                types.Map    `tfsdk:"checksums"`
+	Generations              types.Map    `tfsdk:"generations"`
+}
+
+// dagBundleFileModel describes the template rendered to one target path.
+type dagBundleFileModel struct {
+	TemplatePath    types.String `tfsdk:"template_path"`
+	TemplateContent types.String `tfsdk:"template_content"`
+}
+
+func (r *dagBundleResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	data, ok := req.ProviderData.(*mirageProviderData)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Resource Configure Type",
+			fmt.Sprintf("Expected *mirageProviderData, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+		return
+	}
+	r.providerData = data
+}
+
+func (r *dagBundleResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_dag_bundle"
+}
+
+func (r *dagBundleResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Manages a set of files (e.g., an Airflow DAG with its SQL and YAML files) rendered from several templates with one shared context.",
+		Attributes: map[string]schema.Attribute{
+			"dag_generator_backend_url": schema.StringAttribute{
+				Description: "The base URL of the backend service for this specific resource. When omitted, the provider renders the templates and writes gs:// targets itself. Not used for file://, s3:// and az:// targets, which the provider always writes itself.",
+				Optional:    true,
+			},
+			"use_gcp_service_account_auth": schema.BoolAttribute{
+				Description: "If true, authenticate requests to the backend using the machine's GCP service account.",
+				Optional:    true,
+			},
+			"headers": schema.MapAttribute{
+				Description: "Headers sent with every backend request for this resource, overriding the provider's `default_headers`.",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"context_json": schema.StringAttribute{
+				Description: "A JSON string representing the context shared by every template in the bundle.",
+				Optional:    true,
+			},
+			"files": schema.MapNestedAttribute{
+				Description: "The files to generate, keyed by their full target path: a gs://, s3://, az:// or file:// path.",
+				Required:    true,
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"template_path": schema.StringAttribute{
+							Description: "The full gs:// path to the source Jinja2 template. When the provider renders the template itself, this may also be a file://, s3:// or az:// path.",
+							Optional:    true,
+						},
+						"template_content": schema.StringAttribute{
+							Description: "The content of the local template file.",
+							Optional:    true,
+						},
+					},
+				},
+			},
+			"provenance_header": schema.BoolAttribute{
+				Description: "If true, a comment block recording the template source and checksum, a hash of the context, the provider version and the Terraform workspace is prepended to every generated file, as for `mirage_dag_generator`. Every file must be of a type with line comments. The header itself never causes regeneration.",
+				Optional:    true,
+			},
+			"id": schema.StringAttribute{
+				Description: "A random identifier for the bundle.",
+				Computed:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"checksums": schema.MapAttribute{
+				Description: "The CRC32C checksum of each generated file, keyed by target path.",
+				ElementType: types.StringType,
+				Computed:    true,
+			},
+			"generations": schema.MapAttribute{
+				Description: "The generation of each generated file in its storage, keyed by target path.",
+				ElementType: types.StringType,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+// ModifyPlan checks that every file can carry a provenance header when one
+// is requested, and plans regeneration when a generated file has gone missing
+// since the last apply, even if the configuration is unchanged.
+func (r *dagBundleResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var plan dagBundleResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() || plan.Files.IsUnknown() {
+		return
+	}
+	for target := range plan.Files.Elements() {
+		validateProvenanceTarget(plan.ProvenanceHeader, types.StringValue(target), path.Root("files").AtMapKey(target), &resp.Diagnostics)
+	}
+	if resp.Diagnostics.HasError() || req.State.Raw.IsNull() {
+		return
+	}
+
+	var state dagBundleResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if hasUngeneratedFile(plan.Files.Elements(), state.Checksums) {
+		planRegeneration(ctx, resp)
+	}
+}
+
+func (r *dagBundleResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, span := tracing.Start(ctx, "mirage_dag_bundle.Create")
+	defer func() { tracing.End(span, resp.Diagnostics) }()
+
+	var plan dagBundleResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	files, ok := r.files(ctx, plan, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+	span.SetAttributes(attribute.Int("mirage.file_count", len(files)))
+
+	fs, ok := r.fileSet(ctx, plan, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+
+	checksums := map[string]string{}
+	generations := map[string]string{}
+	for _, target := range sortedKeys(files) {
+		if err := r.generate(ctx, fs, plan, target, files[target], checksums, generations, &resp.Diagnostics); err != nil {
+			addBackendError(&resp.Diagnostics, fmt.Sprintf("Failed to generate %s", target), err)
+
+			// Roll back so a failed create leaves no partial bundle behind.
+			for generated := range checksums {
+				if err := fs.delete(ctx, generated); err != nil {
+					resp.Diagnostics.AddWarning(
+						"Failed to roll back generated file",
+						fmt.Sprintf("Could not delete %s after the bundle failed to generate: %v", generated, err),
+					)
+				}
+			}
+			return
+		}
+	}
+
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	plan.ID = types.StringValue(hex.EncodeToString(id))
+	plan.Checksums, plan.Generations = fileOutputs(ctx, checksums, generations, &resp.Diagnostics)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *dagBundleResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, span := tracing.Start(ctx, "mirage_dag_bundle.Read")
+	defer func() { tracing.End(span, resp.Diagnostics) }()
+
+	var state dagBundleResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	fs, ok := r.fileSet(ctx, state, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+
+	checksums, generations, ok := fs.readOutputs(ctx, r.targets(state.Checksums), &resp.Diagnostics)
+	if !ok {
+		return
+	}
+
+	state.Checksums, state.Generations = fileOutputs(ctx, checksums, generations, &resp.Diagnostics)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+}
+
+func (r *dagBundleResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx, span := tracing.Start(ctx, "mirage_dag_bundle.Update")
+	defer func() { tracing.End(span, resp.Diagnostics) }()
+
+	var plan, state dagBundleResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	files, ok := r.files(ctx, plan, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+	oldFiles, ok := r.files(ctx, state, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+	span.SetAttributes(attribute.Int("mirage.file_count", len(files)))
+
+	fs, ok := r.fileSet(ctx, plan, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+
+	checksums := map[string]string{}
+	generations := map[string]string{}
+	oldChecksums, diags := stringMapValue(ctx, state.Checksums)
+	resp.Diagnostics.Append(diags...)
+	oldGenerations, diags := stringMapValue(ctx, state.Generations)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// The context and provenance_header apply to every file of the bundle.
+	sharedChanged := plan.ContextJSON.ValueString() != state.ContextJSON.ValueString() ||
+		plan.ProvenanceHeader.ValueBool() != state.ProvenanceHeader.ValueBool()
+	for _, target := range sortedKeys(files) {
+		file := files[target]
+		oldFile, existed := oldFiles[target]
+		_, generated := oldChecksums[target]
+
+		unchanged := existed && generated && !sharedChanged &&
+			file.TemplatePath.ValueString() == oldFile.TemplatePath.ValueString() &&
+			file.TemplateContent.ValueString() == oldFile.TemplateContent.ValueString()
+		if unchanged {
+			checksums[target] = oldChecksums[target]
+			generations[target] = oldGenerations[target]
+			continue
+		}
+
+		if err := r.generate(ctx, fs, plan, target, file, checksums, generations, &resp.Diagnostics); err != nil {
+			addBackendError(&resp.Diagnostics, fmt.Sprintf("Failed to generate %s", target), err)
+			// Nothing is deleted, so every removed file stays tracked.
+			removed := map[string]bool{}
+			for old := range oldFiles {
+				removed[old] = true
+			}
+			r.saveUpdate(ctx, plan, state, files, oldFiles, removed, checksums, generations, oldChecksums, oldGenerations, resp)
+			return
+		}
+	}
+
+	// Only remove outputs that left the map once every file was generated.
+	kept := fs.cleanUp(ctx, r.targets(state.Checksums), r.targets(plan.Files), checksums, &resp.Diagnostics)
+	r.saveUpdate(ctx, plan, state, files, oldFiles, kept, checksums, generations, oldChecksums, oldGenerations, resp)
+}
+
+// saveUpdate saves the state after an update. Files that were not
+// regenerated, because the update failed partway, keep their previous
+// configuration and outputs, and the previous context and provenance_header
+// are kept, so the next plan shows them as changed and the next apply
+// retries them. Added files that failed have no checksum, which ModifyPlan
+// turns into a retry. Removed files in kept stay tracked until they are
+// deleted.
+func (r *dagBundleResource) saveUpdate(ctx context.Context, plan, state dagBundleResourceModel, files, oldFiles map[string]dagBundleFileModel, kept map[string]bool, checksums, generations, oldChecksums, oldGenerations map[string]string, resp *resource.UpdateResponse) {
+	saved := map[string]dagBundleFileModel{}
+	pending := false
+	keepOld := func(target string) {
+		saved[target] = oldFiles[target]
+		if checksum, ok := oldChecksums[target]; ok {
+			checksums[target] = checksum
+			generations[target] = oldGenerations[target]
+		}
+	}
+	for target, file := range files {
+		saved[target] = file
+		if _, ok := checksums[target]; ok {
+			continue
+		}
+		pending = true
+		if _, ok := oldFiles[target]; ok {
+			keepOld(target)
+		}
+	}
+	for target := range oldFiles {
+		if _, ok := files[target]; !ok && kept[target] {
+			keepOld(target)
+		}
+	}
+
+	if len(saved) != len(files) || pending {
+		if pending {
+			plan.ContextJSON = state.ContextJSON
+			plan.ProvenanceHeader = state.ProvenanceHeader
+		}
+		var diags diag.Diagnostics
+		plan.Files, diags = types.MapValueFrom(ctx, plan.Files.ElementType(ctx), saved)
+		resp.Diagnostics.Append(diags...)
+	}
+	plan.ID = state.ID
+	plan.Checksums, plan.Generations = fileOutputs(ctx, checksums, generations, &resp.Diagnostics)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *dagBundleResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, span := tracing.Start(ctx, "mirage_dag_bundle.Delete")
+	defer func() { tracing.End(span, resp.Diagnostics) }()
+
+	var state dagBundleResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	fs, ok := r.fileSet(ctx, state, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+
+	fs.deleteAll(ctx, r.targets(state.Checksums), &resp.Diagnostics)
+}
+
+// files decodes and validates the files map of model.
+func (r *dagBundleResource) files(ctx context.Context, model dagBundleResourceModel, diags *diag.Diagnostics) (map[string]dagBundleFileModel, bool) {
+	files := map[string]dagBundleFileModel{}
+	if model.Files.IsNull() {
+		return files, true
+	}
+	diags.Append(model.Files.ElementsAs(ctx, &files, false)...)
+	if diags.HasError() {
+		return nil, false
+	}
+
+	for target, file := range files {
+		gcsPath := file.TemplatePath.ValueString()
+		content := file.TemplateContent.ValueString()
+		if (gcsPath == "" && content == "") || (gcsPath != "" && content != "") {
+			diags.AddAttributeError(
+				path.Root("files").AtMapKey(target),
+				"Invalid Configuration",
+				"Exactly one of `template_path` or `template_content` must be specified.",
+			)
+		}
+	}
+	return files, !diags.HasError()
+}
+
+// generate renders one file of the bundle and records its checksum and generation.
+func (r *dagBundleResource) generate(ctx context.Context, fs *fileSet, model dagBundleResourceModel, target string, file dagBundleFileModel, checksums, generations map[string]string, diags *diag.Diagnostics) error {
+	genReq := client.GenerateRequest{
+		TemplateGCSPath: file.TemplatePath.ValueString(),
+		TemplateContent: file.TemplateContent.ValueString(),
+		TargetGCSPath:   target,
+		ContextJSON:     model.ContextJSON.ValueString(),
+	}
+	if model.ProvenanceHeader.ValueBool() {
+		header, err := fs.provenanceHeader(ctx, genReq, diags)
+		if err != nil {
+			return err
+		}
+		genReq.ProvenanceHeader = header
+	}
+	generationResult, err := fs.generate(ctx, genReq)
+	if err != nil {
+		return err
+	}
+	checksums[target] = generationResult.Checksum
+	generations[target] = generationResult.Generation
+	return nil
+}
+
+// targets returns the target path of each file in m, a map keyed by target
+// path such as files or checksums.
+func (r *dagBundleResource) targets(m types.Map) map[string]string {
+	targets := map[string]string{}
+	for target := range m.Elements() {
+		targets[target] = target
+	}
+	return targets
+}
+
+func (r *dagBundleResource) fileSet(ctx context.Context, model dagBundleResourceModel, diags *diag.Diagnostics) (*fileSet, bool) {
+	return newFileSet(ctx, r.providerData, model.DagGeneratorBackendURL, model.UseGCPServiceAccountAuth, model.Headers, diags)
+}
+
+// sortedKeys returns the keys of m in order, so files are generated deterministically.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
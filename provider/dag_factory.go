@@ -0,0 +1,184 @@
+package provider
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"gopkg.in/yaml.v3"
+)
+
+// factoryDag is one DAG resolved from a spec.
+type factoryDag struct {
+	TargetPath  string
+	ContextJSON string
+}
+
+// checksum identifies everything that goes into generating the DAG besides
+// the template, so a changed spec or target plans its regeneration.
+func (d factoryDag) checksum() string {
+	sum := sha256.Sum256([]byte(d.TargetPath + "\n" + d.ContextJSON))
+	return hex.EncodeToString(sum[:])
+}
+
+var targetPatternVar = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// loadFactorySpecs returns the raw YAML specs keyed by DAG name, read either
+// from the files matching specGlob, named after the file without extension,
+// or taken as is from specs.
+func loadFactorySpecs(specGlob string, specs map[string]string) (map[string]string, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	if (specGlob == "") == (specs == nil) {
+		diags.AddAttributeError(
+			path.Root("spec_glob"),
+			"Invalid Configuration",
+			"Exactly one of `spec_glob` or `specs` must be specified.",
+		)
+		return nil, diags
+	}
+	if specs != nil {
+		return specs, diags
+	}
+
+	matches, err := filepath.Glob(specGlob)
+	if err != nil {
+		diags.AddAttributeError(path.Root("spec_glob"), "Invalid Spec Glob", err.Error())
+		return nil, diags
+	}
+
+	loaded := map[string]string{}
+	files := map[string]string{}
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		name := strings.TrimSuffix(filepath.Base(match), filepath.Ext(match))
+		if other, ok := files[name]; ok {
+			diags.AddAttributeError(
+				path.Root("spec_glob"),
+				"Duplicate Spec Name",
+				fmt.Sprintf("The specs %s and %s both resolve to the DAG name %q.", other, match, name),
+			)
+			continue
+		}
+		content, err := os.ReadFile(match)
+		if err != nil {
+			diags.AddAttributeError(path.Root("spec_glob"), "Failed to read spec", err.Error())
+			continue
+		}
+		files[name] = match
+		loaded[name] = string(content)
+	}
+	if len(files) == 0 && !diags.HasError() {
+		// A typo in the glob would otherwise plan the deletion of every DAG.
+		diags.AddAttributeError(
+			path.Root("spec_glob"),
+			"No Specs Found",
+			fmt.Sprintf("No spec file matches %q.", specGlob),
+		)
+	}
+	return loaded, diags
+}
+
+// resolveFactoryDags parses each spec, merges it over the base context and
+// expands the target path pattern with the spec's top-level values.
+func resolveFactoryDags(specs map[string]string, baseContextJSON, targetPattern string) (map[string]factoryDag, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	base := map[string]any{}
+	if baseContextJSON != "" {
+		if err := json.Unmarshal([]byte(baseContextJSON), &base); err != nil {
+			diags.AddAttributeError(path.Root("context_json"), "Invalid Context JSON", err.Error())
+			return nil, diags
+		}
+	}
+
+	dags := map[string]factoryDag{}
+	targets := map[string]string{}
+	for _, name := range sortedKeys(specs) {
+		spec := map[string]any{}
+		if err := yaml.Unmarshal([]byte(specs[name]), &spec); err != nil {
+			diags.AddError("Invalid Spec", fmt.Sprintf("The spec for DAG %q is not a YAML mapping: %v", name, err))
+			continue
+		}
+
+		dagContext := make(map[string]any, len(base)+len(spec))
+		for k, v := range base {
+			dagContext[k] = v
+		}
+		for k, v := range spec {
+			dagContext[k] = v
+		}
+
+		target, err := expandTargetPattern(targetPattern, dagContext)
+		if err != nil {
+			diags.AddAttributeError(
+				path.Root("target_path_pattern"),
+				"Invalid Target Path",
+				fmt.Sprintf("Cannot build the target path for DAG %q: %v", name, err),
+			)
+			continue
+		}
+		if other, ok := targets[target]; ok {
+			diags.AddAttributeError(
+				path.Root("target_path_pattern"),
+				"Duplicate Target Path",
+				fmt.Sprintf("DAGs %q and %q both write to %s.", other, name, target),
+			)
+			continue
+		}
+		targets[target] = name
+
+		contextJSON, err := json.Marshal(dagContext)
+		if err != nil {
+			diags.AddError("Invalid Spec", fmt.Sprintf("The spec for DAG %q cannot be converted to JSON: %v", name, err))
+			continue
+		}
+		dags[name] = factoryDag{TargetPath: target, ContextJSON: string(contextJSON)}
+	}
+	return dags, diags
+}
+
+// expandTargetPattern replaces each {{ key }} in pattern with the scalar value
+// of key in values. A value may only name a file, so one containing a slash,
+// a backslash or ".." is rejected rather than let a spec write outside the
+// directory the pattern names.
+func expandTargetPattern(pattern string, values map[string]any) (string, error) {
+	var err error
+	target := targetPatternVar.ReplaceAllStringFunc(pattern, func(m string) string {
+		key := targetPatternVar.FindStringSubmatch(m)[1]
+		v, ok := values[key]
+		if !ok {
+			if err == nil {
+				err = fmt.Errorf("the spec has no value for %q", key)
+			}
+			return m
+		}
+		switch v.(type) {
+		case string, int, int64, float64, bool:
+			s := fmt.Sprint(v)
+			if strings.ContainsAny(s, `/\`) || strings.Contains(s, "..") {
+				if err == nil {
+					err = fmt.Errorf("the value of %q, %q, must not contain a slash, a backslash or \"..\"", key, s)
+				}
+				return m
+			}
+			return s
+		default:
+			if err == nil {
+				err = fmt.Errorf("the value of %q is not a scalar", key)
+			}
+			return m
+		}
+	})
+	return target, err
+}
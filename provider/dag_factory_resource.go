@@ -0,0 +1,486 @@
+package provider
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/client"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	_ resource.Resource               = &dagFactoryResource{}
+	_ resource.ResourceWithConfigure  = &dagFactoryResource{}
+	_ resource.ResourceWithModifyPlan = &dagFactoryResource{}
+)
+
+func NewDagFactoryResource() resource.Resource {
+	return &dagFactoryResource{}
+}
+
+type dagFactoryResource struct {
+	providerData *mirageProviderData
+}
+
+type dagFactoryResourceModel struct {
+	DagGeneratorBackendURL   types.String `tfsdk:"dag_generator_backend_url"`
+	UseGCPServiceAccountAuth types.Bool   `tfsdk:"use_gcp_service_account_auth"`
+	Headers                  types.Map    `tfsdk:"headers"`
+	TemplatePath             types.String `tfsdk:"template_path"`
+	TemplateContent          types.String `tfsdk:"template_content"`
+	SpecGlob                 types.String `tfsdk:"spec_glob"`
+	Specs                    types.Map    `tfsdk:"specs"`
+	TargetPathPattern        types.String `tfsdk:"target_path_pattern"`
+	ContextJSON              types.String `tfsdk:"context_json"`
+	BatchSize                types.Int64  `tfsdk:"batch_size"`
+	ProvenanceHeader         types.Bool   `tfsdk:"provenance_header"`
+	ID                       types.String `tfsdk:"id"`
+	Targets                  types.Map    `tfsdk:"targets"`
+	SpecChecksums            types.Map    `tfsdk:"spec_checksums"`
+	Checksums                types.Map    `tfsdk:"checksums"`
+	Generations              types.Map    `tfsdk:"generations"`
+}
+
+func (r *dagFactoryResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	data, ok := req.ProviderData.(*mirageProviderData)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Resource Configure Type",
+			fmt.Sprintf("Expected *mirageProviderData, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+		return
+	}
+	r.providerData = data
+}
+
+func (r *dagFactoryResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_dag_factory"
+}
+
+func (r *dagFactoryResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Generates one DAG per YAML spec from a shared template, adding and removing DAGs as specs appear or disappear.",
+		Attributes: map[string]schema.Attribute{
+			"dag_generator_backend_url": schema.StringAttribute{
+				Description: "The base URL of the backend service for this specific resource. When omitted, the provider renders the templates and writes gs:// targets itself. Not used for file://, s3:// and az:// targets, which the provider always writes itself.",
+				Optional:    true,
+			},
+			"use_gcp_service_account_auth": schema.BoolAttribute{
+				Description: "If true, authenticate requests to the backend using the machine's GCP service account.",
+				Optional:    true,
+			},
+			"headers": schema.MapAttribute{
+				Description: "Headers sent with every backend request for this resource, overriding the provider's `default_headers`.",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"template_path": schema.StringAttribute{
+				Description: "The full gs:// path to the source Jinja2 template. When the provider renders the template itself, this may also be a file://, s3:// or az:// path.",
+				Optional:    true,
+			},
+			"template_content": schema.StringAttribute{
+				Description: "The content of the local template file.",
+				Optional:    true,
+			},
+			"spec_glob": schema.StringAttribute{
+				Description: "A glob matching local YAML spec files, one per DAG. Each DAG is named after its file without extension.",
+				Optional:    true,
+			},
+			"specs": schema.MapAttribute{
+				Description: "YAML specs keyed by DAG name, as an alternative to `spec_glob`.",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"target_path_pattern": schema.StringAttribute{
+				Description: "The path of each generated DAG: a gs://, s3://, az:// or file:// path. `{{ key }}` placeholders are replaced with the spec's top-level values, e.g. `gs://bucket/dags/{{ dag_id }}.py`. A value containing `/`, `\\` or `..` is rejected.",
+				Required:    true,
+			},
+			"context_json": schema.StringAttribute{
+				Description: "A JSON object with default context values. Each spec's values take precedence.",
+				Optional:    true,
+			},
+			"batch_size": schema.Int64Attribute{
+				Description: fmt.Sprintf("The maximum number of DAGs sent per batch request. Defaults to %d.", client.DefaultBatchSize),
+				Optional:    true,
+			},
+			"provenance_header": schema.BoolAttribute{
+				Description: "If true, a comment block recording the template source and checksum, a hash of the DAG's context, the provider version and the Terraform workspace is prepended to every generated DAG, as for `mirage_dag_generator`. The targets must be of a type with line comments. The header itself never causes regeneration.",
+				Optional:    true,
+			},
+			"id": schema.StringAttribute{
+				Description: "A random identifier for the factory.",
+				Computed:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"targets": schema.MapAttribute{
+				Description: "The target path of each DAG, keyed by DAG name.",
+				ElementType: types.StringType,
+				Computed:    true,
+			},
+			"spec_checksums": schema.MapAttribute{
+				Description: "A SHA-256 of each DAG's target path and rendered context, keyed by DAG name.",
+				ElementType: types.StringType,
+				Computed:    true,
+			},
+			"checksums": schema.MapAttribute{
+				Description: "The CRC32C checksum of each generated DAG, keyed by DAG name.",
+				ElementType: types.StringType,
+				Computed:    true,
+			},
+			"generations": schema.MapAttribute{
+				Description: "The generation of each generated DAG in its storage, keyed by DAG name.",
+				ElementType: types.StringType,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+// ModifyPlan resolves the specs at plan time, so added, removed or edited
+// spec files show up in the plan even though the configuration is unchanged.
+func (r *dagFactoryResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var plan dagFactoryResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if plan.TemplatePath.IsUnknown() || plan.TemplateContent.IsUnknown() || plan.SpecGlob.IsUnknown() ||
+		!mapFullyKnown(plan.Specs) || plan.TargetPathPattern.IsUnknown() || plan.ContextJSON.IsUnknown() {
+		return
+	}
+
+	dags, ok := r.resolve(ctx, plan, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+	for _, name := range sortedKeys(dags) {
+		validateProvenanceTarget(plan.ProvenanceHeader, types.StringValue(dags[name].TargetPath), path.Root("target_path_pattern"), &resp.Diagnostics)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+	targets, specChecksums := factoryOutputs(dags)
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("targets"), targets)...)
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("spec_checksums"), specChecksums)...)
+
+	if req.State.Raw.IsNull() {
+		return
+	}
+	var state dagFactoryResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	changed := !plan.TemplatePath.Equal(state.TemplatePath) ||
+		!plan.TemplateContent.Equal(state.TemplateContent) ||
+		plan.ProvenanceHeader.ValueBool() != state.ProvenanceHeader.ValueBool() ||
+		!specChecksums.Equal(state.SpecChecksums)
+	if changed || hasUngeneratedFile(specChecksums.Elements(), state.Checksums) {
+		planRegeneration(ctx, resp)
+	} else {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("checksums"), state.Checksums)...)
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("generations"), state.Generations)...)
+	}
+}
+
+func (r *dagFactoryResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, span := tracing.Start(ctx, "mirage_dag_factory.Create")
+	defer func() { tracing.End(span, resp.Diagnostics) }()
+
+	var plan dagFactoryResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	dags, ok := r.resolve(ctx, plan, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+	span.SetAttributes(attribute.Int("mirage.dag_count", len(dags)))
+
+	fs, ok := r.fileSet(ctx, plan, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+
+	checksums := map[string]string{}
+	generations := map[string]string{}
+	r.generate(ctx, fs, plan, dags, sortedKeys(dags), checksums, generations, &resp.Diagnostics)
+
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	plan.ID = types.StringValue(hex.EncodeToString(id))
+	plan.Targets, plan.SpecChecksums = factoryOutputs(dags)
+	plan.Checksums, plan.Generations = fileOutputs(ctx, checksums, generations, &resp.Diagnostics)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *dagFactoryResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, span := tracing.Start(ctx, "mirage_dag_factory.Read")
+	defer func() { tracing.End(span, resp.Diagnostics) }()
+
+	var state dagFactoryResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	targets, ok := r.generatedTargets(ctx, state, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+
+	fs, ok := r.fileSet(ctx, state, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+
+	checksums, generations, ok := fs.readOutputs(ctx, targets, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+
+	state.Checksums, state.Generations = fileOutputs(ctx, checksums, generations, &resp.Diagnostics)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+}
+
+func (r *dagFactoryResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx, span := tracing.Start(ctx, "mirage_dag_factory.Update")
+	defer func() { tracing.End(span, resp.Diagnostics) }()
+
+	var plan, state dagFactoryResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	dags, ok := r.resolve(ctx, plan, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+	span.SetAttributes(attribute.Int("mirage.dag_count", len(dags)))
+
+	fs, ok := r.fileSet(ctx, plan, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+
+	oldTargets, diags := stringMapValue(ctx, state.Targets)
+	resp.Diagnostics.Append(diags...)
+	oldSpecChecksums, diags := stringMapValue(ctx, state.SpecChecksums)
+	resp.Diagnostics.Append(diags...)
+	oldChecksums, diags := stringMapValue(ctx, state.Checksums)
+	resp.Diagnostics.Append(diags...)
+	oldGenerations, diags := stringMapValue(ctx, state.Generations)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	templateChanged := !plan.TemplatePath.Equal(state.TemplatePath) || !plan.TemplateContent.Equal(state.TemplateContent)
+	headerChanged := plan.ProvenanceHeader.ValueBool() != state.ProvenanceHeader.ValueBool()
+
+	checksums := map[string]string{}
+	generations := map[string]string{}
+	var changed []string
+	for _, name := range sortedKeys(dags) {
+		_, generated := oldChecksums[name]
+		if !templateChanged && !headerChanged && generated && oldSpecChecksums[name] == dags[name].checksum() {
+			checksums[name] = oldChecksums[name]
+			generations[name] = oldGenerations[name]
+			continue
+		}
+		changed = append(changed, name)
+	}
+
+	r.generate(ctx, fs, plan, dags, changed, checksums, generations, &resp.Diagnostics)
+
+	// Delete the DAGs whose spec disappeared and the old files of DAGs whose
+	// target path changed. DAGs whose old file is still current, or could not
+	// be deleted, keep their previous entry in state.
+	generatedTargets, ok := r.generatedTargets(ctx, state, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+	targets := map[string]string{}
+	for name, dag := range dags {
+		targets[name] = dag.TargetPath
+	}
+	kept := fs.cleanUp(ctx, generatedTargets, targets, checksums, &resp.Diagnostics)
+
+	plan.ID = state.ID
+	plan.Targets, plan.SpecChecksums = factoryOutputs(dags)
+	if len(kept) > 0 {
+		savedTargets := plan.Targets.Elements()
+		savedSpecChecksums := plan.SpecChecksums.Elements()
+		pending := false
+		for name := range kept {
+			if _, ok := dags[name]; ok {
+				pending = true
+			}
+			savedTargets[name] = types.StringValue(oldTargets[name])
+			savedSpecChecksums[name] = types.StringValue(oldSpecChecksums[name])
+			checksums[name] = oldChecksums[name]
+			generations[name] = oldGenerations[name]
+		}
+		plan.Targets = types.MapValueMust(types.StringType, savedTargets)
+		plan.SpecChecksums = types.MapValueMust(types.StringType, savedSpecChecksums)
+		// DAGs kept on the previous template or provenance_header must be
+		// regenerated with the new one, so the previous setting stays in
+		// state until they are.
+		if pending && templateChanged {
+			plan.TemplatePath = state.TemplatePath
+			plan.TemplateContent = state.TemplateContent
+		}
+		if pending && headerChanged {
+			plan.ProvenanceHeader = state.ProvenanceHeader
+		}
+	}
+	plan.Checksums, plan.Generations = fileOutputs(ctx, checksums, generations, &resp.Diagnostics)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *dagFactoryResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, span := tracing.Start(ctx, "mirage_dag_factory.Delete")
+	defer func() { tracing.End(span, resp.Diagnostics) }()
+
+	var state dagFactoryResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	targets, ok := r.generatedTargets(ctx, state, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+
+	fs, ok := r.fileSet(ctx, state, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+
+	fs.deleteAll(ctx, targets, &resp.Diagnostics)
+}
+
+// resolve validates model and returns the DAGs described by its specs.
+func (r *dagFactoryResource) resolve(ctx context.Context, model dagFactoryResourceModel, diags *diag.Diagnostics) (map[string]factoryDag, bool) {
+	gcsPath := model.TemplatePath.ValueString()
+	content := model.TemplateContent.ValueString()
+	if (gcsPath == "" && content == "") || (gcsPath != "" && content != "") {
+		diags.AddError(
+			"Invalid Configuration",
+			"Exactly one of `template_path` or `template_content` must be specified.",
+		)
+		return nil, false
+	}
+
+	specMap, d := stringMapValue(ctx, model.Specs)
+	diags.Append(d...)
+	if diags.HasError() {
+		return nil, false
+	}
+	if !model.Specs.IsNull() && specMap == nil {
+		specMap = map[string]string{}
+	}
+
+	specs, d := loadFactorySpecs(model.SpecGlob.ValueString(), specMap)
+	diags.Append(d...)
+	if diags.HasError() {
+		return nil, false
+	}
+
+	dags, d := resolveFactoryDags(specs, model.ContextJSON.ValueString(), model.TargetPathPattern.ValueString())
+	diags.Append(d...)
+	return dags, !diags.HasError()
+}
+
+// generate sends the named DAGs in batches and records the checksum and
+// generation of each one that succeeded. Each failure is reported as its own
+// diagnostic.
+func (r *dagFactoryResource) generate(ctx context.Context, fs *fileSet, model dagFactoryResourceModel, dags map[string]factoryDag, names []string, checksums, generations map[string]string, diags *diag.Diagnostics) {
+	failed := func(id string, err error) {
+		diags.AddError(
+			"Failed to generate DAG",
+			fmt.Sprintf("Could not generate DAG %q at %s: %v", id, dags[id].TargetPath, err),
+		)
+	}
+
+	items := make([]client.BatchGenerateItem, 0, len(names))
+	for _, name := range names {
+		genReq := client.GenerateRequest{
+			TemplateGCSPath: model.TemplatePath.ValueString(),
+			TemplateContent: model.TemplateContent.ValueString(),
+			TargetGCSPath:   dags[name].TargetPath,
+			ContextJSON:     dags[name].ContextJSON,
+		}
+		if model.ProvenanceHeader.ValueBool() {
+			header, err := fs.provenanceHeader(ctx, genReq, diags)
+			if err != nil {
+				failed(name, err)
+				continue
+			}
+			genReq.ProvenanceHeader = header
+		}
+		items = append(items, client.BatchGenerateItem{ID: name, GenerateRequest: genReq})
+	}
+
+	fs.generateBatch(ctx, items, int(model.BatchSize.ValueInt64()), checksums, generations, diags, failed)
+}
+
+// generatedTargets returns the target of each DAG in model that has a
+// generated file.
+func (r *dagFactoryResource) generatedTargets(ctx context.Context, model dagFactoryResourceModel, diags *diag.Diagnostics) (map[string]string, bool) {
+	targets, d := stringMapValue(ctx, model.Targets)
+	diags.Append(d...)
+	if diags.HasError() {
+		return nil, false
+	}
+
+	generated := map[string]string{}
+	for name := range model.Checksums.Elements() {
+		if target, ok := targets[name]; ok {
+			generated[name] = target
+		}
+	}
+	return generated, true
+}
+
+// factoryOutputs returns the targets and spec_checksums maps for dags.
+func factoryOutputs(dags map[string]factoryDag) (types.Map, types.Map) {
+	targets := map[string]attr.Value{}
+	specChecksums := map[string]attr.Value{}
+	for name, dag := range dags {
+		targets[name] = types.StringValue(dag.TargetPath)
+		specChecksums[name] = types.StringValue(dag.checksum())
+	}
+	return types.MapValueMust(types.StringType, targets), types.MapValueMust(types.StringType, specChecksums)
+}
+
+func (r *dagFactoryResource) fileSet(ctx context.Context, model dagFactoryResourceModel, diags *diag.Diagnostics) (*fileSet, bool) {
+	return newFileSet(ctx, r.providerData, model.DagGeneratorBackendURL, model.UseGCPServiceAccountAuth, model.Headers, diags)
+}
@@ -0,0 +1,42 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpandTargetPattern(t *testing.T) {
+	const pattern = "gs://dags/{{ team }}/{{ dag_id }}.py"
+	tests := []struct {
+		name    string
+		values  map[string]any
+		want    string
+		wantErr string
+	}{
+		{name: "strings", values: map[string]any{"team": "data", "dag_id": "orders"}, want: "gs://dags/data/orders.py"},
+		{name: "number", values: map[string]any{"team": "data", "dag_id": float64(7)}, want: "gs://dags/data/7.py"},
+		{name: "dots in a name", values: map[string]any{"team": "data", "dag_id": "orders.v2"}, want: "gs://dags/data/orders.v2.py"},
+		{name: "missing value", values: map[string]any{"team": "data"}, wantErr: `no value for "dag_id"`},
+		{name: "list", values: map[string]any{"team": "data", "dag_id": []any{"a"}}, wantErr: "not a scalar"},
+		{name: "parent directory", values: map[string]any{"team": "..", "dag_id": "orders"}, wantErr: `the value of "team", "..", must not contain`},
+		{name: "slash", values: map[string]any{"team": "data", "dag_id": "../../etc/orders"}, wantErr: `the value of "dag_id"`},
+		{name: "backslash", values: map[string]any{"team": `data\ops`, "dag_id": "orders"}, wantErr: `the value of "team"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandTargetPattern(pattern, tt.values)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("err = %v, want it to mention %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("expandTargetPattern() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,22 +2,30 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
 	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
 	"github.com/mm-aranda/terraform-provider-mirage/internal/client"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/pysyntax"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/render"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/storage"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	"strings"
 
+	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
-	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
-	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 )
 
 var (
-	_ resource.Resource                = &dagGeneratorResource{}
-	_ resource.ResourceWithImportState = &dagGeneratorResource{}
+	_ resource.Resource                 = &dagGeneratorResource{}
+	_ resource.ResourceWithImportState  = &dagGeneratorResource{}
+	_ resource.ResourceWithModifyPlan   = &dagGeneratorResource{}
+	_ resource.ResourceWithUpgradeState = &dagGeneratorResource{}
 )
 
 func NewDagGeneratorResource() resource.Resource {
@@ -26,22 +34,51 @@ func NewDagGeneratorResource() resource.Resource {
 
 type dagGeneratorResource struct {
 	dagGenService *client.DagGeneratorService
+	providerData  *mirageProviderData
 }
 
 type dagGeneratorResourceModel struct {
 	DagGeneratorBackendURL   types.String `tfsdk:"dag_generator_backend_url"`
+	TemplatePath             types.String `tfsdk:"template_path"`
 	TemplateGCSPath          types.String `tfsdk:"template_gcs_path"`
 	TemplateContent          types.String `tfsdk:"template_content"`
+	TargetPath               types.String `tfsdk:"target_path"`
 	TargetGCSPath            types.String `tfsdk:"target_gcs_path"`
 	ContextJSON              types.String `tfsdk:"context_json"`
+	ContextSchema            types.String `tfsdk:"context_schema"`
 	GeneratedFileChecksum    types.String `tfsdk:"generated_file_checksum"`
+	Generation               types.String `tfsdk:"generation"`
 	GCSGenerationNumber      types.String `tfsdk:"gcs_generation_number"`
 	TemplateChecksum         types.String `tfsdk:"template_checksum"`
 	ID                       types.String `tfsdk:"id"`
 	UseGCPServiceAccountAuth types.Bool   `tfsdk:"use_gcp_service_account_auth"`
+	Headers                  types.Map    `tfsdk:"headers"`
+	TemplateFiles            types.Map    `tfsdk:"template_files"`
+	TemplateDir              types.String `tfsdk:"template_dir"`
+	TemplateBundleChecksum   types.String `tfsdk:"template_bundle_checksum"`
+	ContentType              types.String `tfsdk:"content_type"`
+	CacheControl             types.String `tfsdk:"cache_control"`
+	Metadata                 types.Map    `tfsdk:"metadata"`
+	ProvenanceHeader         types.Bool   `tfsdk:"provenance_header"`
+	ValidatePython           types.Bool   `tfsdk:"validate_python"`
+	StrictUndefined          types.Bool   `tfsdk:"strict_undefined"`
+	AirflowLint              types.Object `tfsdk:"airflow_lint"`
 }
 
 func (r *dagGeneratorResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	data, ok := req.ProviderData.(*mirageProviderData)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Resource Configure Type",
+			fmt.Sprintf("Expected *mirageProviderData, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+		return
+	}
+	r.providerData = data
 }
 
 func (r *dagGeneratorResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
@@ -50,46 +87,81 @@ func (r *dagGeneratorResource) Metadata(_ context.Context, req resource.Metadata
 
 func (r *dagGeneratorResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
 	resp.Schema = schema.Schema{
-		Description: "Manages a generated file (e.g., an Airflow DAG) in Google Cloud Storage.",
+		Description: "Manages a generated file (e.g., an Airflow DAG) in Google Cloud Storage, S3, Azure Blob Storage or the local filesystem.",
+		Version:     1,
 		Attributes: map[string]schema.Attribute{
 			"dag_generator_backend_url": schema.StringAttribute{
-				Description: "The base URL of the backend service for this specific resource.",
-				Required:    true,
+				Description: "The base URL of the backend service for this specific resource. When omitted, the provider renders the template and writes gs:// targets itself. Not used for file://, s3:// and az:// targets, which the provider always writes itself.",
+				Optional:    true,
 			},
 			"id": schema.StringAttribute{
-				Description: "The GCS path of the generated file, used as the resource ID.",
+				Description: "The path of the generated file, used as the resource ID.",
 				Computed:    true,
 			},
-			"template_gcs_path": schema.StringAttribute{
-				Description: "The full gs:// path to the source Jinja2 template.",
+			"template_path": schema.StringAttribute{
+				Description: "The full gs:// path to the source Jinja2 template. When the provider renders the template itself, this may also be a file://, s3:// or az:// path. Changing it replaces the resource.",
 				Optional:    true,
-				PlanModifiers: []planmodifier.String{
-					stringplanmodifier.RequiresReplace(),
-				},
+				Computed:    true,
+			},
+			"template_gcs_path": schema.StringAttribute{
+				Description:        "Deprecated alias of `template_path`.",
+				DeprecationMessage: "Use `template_path` instead. `template_gcs_path` will be removed in a future major version.",
+				Optional:           true,
+				Computed:           true,
 			},
 			"template_content": schema.StringAttribute{
 				Description: "The content of the local template file.",
 				Optional:    true,
 			},
+			"template_files": schema.MapAttribute{
+				Description: "Additional templates, keyed by relative path, that the main template can `include`, `import` or `extend`. Conflicts with `template_dir`.",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"template_dir": schema.StringAttribute{
+				Description: "A local directory whose files are sent as additional templates, keyed by their path relative to the directory. Hidden files are skipped. Conflicts with `template_files`.",
+				Optional:    true,
+			},
+			"template_bundle_checksum": schema.StringAttribute{
+				Description: "A SHA-256 checksum over every file in `template_files` or `template_dir`. A change regenerates the file.",
+				Computed:    true,
+			},
+			"target_path": schema.StringAttribute{
+				Description: "The full gs:// path for the generated output file, an s3:// or az:// path, or a file:// path to render locally and write to the local filesystem. Exactly one of `target_path` or `target_gcs_path` is required.",
+				Optional:    true,
+				Computed:    true,
+			},
 			"target_gcs_path": schema.StringAttribute{
-				Description: "The full gs:// path for the generated output file.",
-				Required:    true,
+				Description:        "Deprecated alias of `target_path`.",
+				DeprecationMessage: "Use `target_path` instead. `target_gcs_path` will be removed in a future major version.",
+				Optional:           true,
+				Computed:           true,
 			},
 			"context_json": schema.StringAttribute{
 				Description: "A JSON string representing the dynamic context for the template.",
 				Required:    false,
 				Optional:    true,
 			},
+			"context_schema": schema.StringAttribute{
+				Description: "A JSON Schema (draft 2020-12 unless it declares `$schema`) that `context_json` must match, inline or as a gs://, s3://, az:// or file:// path. The context is validated at plan time, with a diagnostic for each violation.",
+				Optional:    true,
+			},
 			"generated_file_checksum": schema.StringAttribute{
 				Description: "The CRC32C checksum of the generated file in GCS.",
 				Computed:    true,
 			},
-			"gcs_generation_number": schema.StringAttribute{
-				Description: "The GCS generation number of the generated file.",
+			"generation": schema.StringAttribute{
+				Description: "The version of the generated file in its storage: the GCS generation number, the S3 or Azure version ID or ETag, or the modification time of a local file.",
 				Computed:    true,
 			},
+			"gcs_generation_number": schema.StringAttribute{
+				Description:        "Deprecated: use `generation` instead. Holds the same value and will be removed in a future major version. Terraform does not warn about reading it, since it cannot be set in configuration.",
+				DeprecationMessage: "Use `generation` instead. `gcs_generation_number` will be removed in a future major version.",
+				Computed:           true,
+			},
 			"template_checksum": schema.StringAttribute{
-				Description: "The CRC32C checksum of the template file in GCS.",
+				Description: "The CRC32C checksum of the template file in GCS. Set it to a `mirage_template` resource's `checksum` to regenerate the file whenever that template changes.",
+				Optional:    true,
 				Computed:    true,
 			},
 			"use_gcp_service_account_auth": schema.BoolAttribute{
@@ -97,97 +169,352 @@ func (r *dagGeneratorResource) Schema(_ context.Context, _ resource.SchemaReques
 				Optional:    true,
 				Computed:    false,
 			},
+			"headers": schema.MapAttribute{
+				Description: "Headers sent with every backend request for this resource, overriding the provider's `default_headers`.",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"content_type": schema.StringAttribute{
+				Description: "The content type of the generated file, such as `text/x-python`. Defaults to a type derived from the file extension. Not supported for file:// targets.",
+				Optional:    true,
+			},
+			"cache_control": schema.StringAttribute{
+				Description: "The Cache-Control metadata of the generated file, such as `no-cache`. Not supported for file:// targets.",
+				Optional:    true,
+			},
+			"metadata": schema.MapAttribute{
+				Description: "Custom metadata (labels) set on the generated file, such as `owner` or `source-repo`. Only these keys are checked for drift; other metadata on the file is left alone. Not supported for file:// targets. For az:// targets keys must be valid C# identifiers, so use `source_repo` rather than `source-repo`.",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"provenance_header": schema.BoolAttribute{
+				Description: "If true, a comment block recording the template source and checksum, a hash of the context, the provider version and the Terraform workspace is prepended to the generated file, in the comment syntax of its type: `#` for Python, shell, YAML and TOML, `--` for SQL, `//` for JavaScript, TypeScript, Java, Scala and Go. Other file types, such as JSON, have no line comments and are rejected at plan time. The header itself never causes regeneration.",
+				Optional:    true,
+			},
+			"validate_python": schema.BoolAttribute{
+				Description: "If true, the rendered file is checked to be valid Python before it is written. A file that does not parse fails the apply with the line and column of the error, and the previous generation of the file is left in place. Cannot be combined with `dag_generator_backend_url` for gs:// targets, which the backend writes itself.",
+				Optional:    true,
+			},
+			"strict_undefined": schema.BoolAttribute{
+				Description: "If true, the template is rendered with Jinja2's `StrictUndefined`, so a variable, attribute or item missing from `context_json` fails the apply with its name and template line instead of rendering as an empty string. `is defined` and the `default` filter still work. Changing it alone does not regenerate the file.",
+				Optional:    true,
+			},
+			"airflow_lint": airflowLintSchema(),
 		},
 	}
 }
 
+// ModifyPlan resolves the deprecated path aliases, so both names of an
+// attribute hold the same value, and computes the template bundle checksum at
+// plan time, so edits to files under template_dir show up as a change even
+// though the configuration itself is unchanged. It also checks context_json
+// against the variables the template uses.
+func (r *dagGeneratorResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var config, plan dagGeneratorResourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if config.TargetPath.IsNull() && config.TargetGCSPath.IsNull() {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("target_path"),
+			"Missing Target Path",
+			"Exactly one of `target_path` or `target_gcs_path` must be specified.",
+		)
+		return
+	}
+	validateAirflowLint(ctx, config.AirflowLint, &resp.Diagnostics)
+	templatePath := resolvePathAlias(&resp.Diagnostics, config.TemplatePath, config.TemplateGCSPath, "template_path", "template_gcs_path")
+	targetPath := resolvePathAlias(&resp.Diagnostics, config.TargetPath, config.TargetGCSPath, "target_path", "target_gcs_path")
+	for _, name := range []string{"template_path", "template_gcs_path"} {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root(name), templatePath)...)
+	}
+	for _, name := range []string{"target_path", "target_gcs_path"} {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root(name), targetPath)...)
+	}
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if storage.IsLocal(targetPath.ValueString()) {
+		for name, value := range map[string]attr.Value{
+			"content_type":  config.ContentType,
+			"cache_control": config.CacheControl,
+			"metadata":      config.Metadata,
+		} {
+			if !value.IsNull() {
+				resp.Diagnostics.AddAttributeError(
+					path.Root(name),
+					"Invalid Configuration",
+					fmt.Sprintf("`%s` cannot be set for file:// targets, since local files have no object metadata.", name),
+				)
+			}
+		}
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+	validateProvenanceTarget(config.ProvenanceHeader, targetPath, path.Root("provenance_header"), &resp.Diagnostics)
+	// The backend renders and writes the file in one request, so the
+	// provider has no chance to check it before it is written.
+	if config.ValidatePython.ValueBool() && !targetPath.IsUnknown() && !config.DagGeneratorBackendURL.IsUnknown() &&
+		usesBackend(targetPath.ValueString(), config.DagGeneratorBackendURL.ValueString()) {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("validate_python"),
+			"Invalid Configuration",
+			"`validate_python` cannot be set for targets written by the backend, since the file is written before the provider could check it. Remove `dag_generator_backend_url` so the provider renders and checks the file itself.",
+		)
+		return
+	}
+	if storage.IsAzure(targetPath.ValueString()) && !config.Metadata.IsUnknown() {
+		for key := range config.Metadata.Elements() {
+			if err := storage.CheckAzureMetadataKey(key); err != nil {
+				resp.Diagnostics.AddAttributeError(path.Root("metadata").AtMapKey(key), "Invalid Metadata Key", err.Error()+".")
+			}
+		}
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+
+	// A different template is a different file, whichever name it is set by.
+	if !req.State.Raw.IsNull() {
+		var state dagGeneratorResourceModel
+		resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		if !templatePath.Equal(state.TemplatePath) {
+			resp.RequiresReplace = append(resp.RequiresReplace, path.Root("template_path"))
+		}
+	}
+
+	// The context is validated once both it and the schema are known, which
+	// may only be at apply time.
+	if !config.ContextSchema.IsNull() && !config.ContextSchema.IsUnknown() && !config.ContextJSON.IsUnknown() && r.providerData != nil {
+		r.providerData.validateContext(ctx, config.ContextSchema.ValueString(), config.ContextJSON.ValueString(), &resp.Diagnostics)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+
+	if !mapFullyKnown(plan.TemplateFiles) || plan.TemplateDir.IsUnknown() {
+		return
+	}
+
+	bundle, diags := loadTemplateBundle(ctx, plan.TemplateFiles, plan.TemplateDir)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("template_bundle_checksum"), bundleChecksum(bundle))...)
+
+	// The context is checked against the variables the template uses once
+	// both are known. A template that does not exist yet, or that changes in
+	// this apply, is not checked.
+	if config.ContextJSON.IsUnknown() || config.TemplateContent.IsUnknown() || config.TemplateChecksum.IsUnknown() || r.providerData == nil {
+		return
+	}
+	template := config.TemplateContent.ValueString()
+	if template == "" {
+		if templatePath.IsUnknown() || templatePath.IsNull() || targetPath.IsUnknown() || plan.DagGeneratorBackendURL.IsUnknown() || !mapFullyKnown(plan.Headers) {
+			return
+		}
+		headers, diags := stringMapValue(ctx, plan.Headers)
+		resp.Diagnostics.Append(diags...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		gen, err := r.providerData.generatorFor(targetPath.ValueString(), plan.DagGeneratorBackendURL.ValueString(), plan.UseGCPServiceAccountAuth.ValueBool(), headers)
+		if err != nil {
+			return
+		}
+		template, err = readTemplate(ctx, gen, templatePath.ValueString())
+		if errors.Is(err, errTemplateNotFound) {
+			return
+		}
+		if err != nil {
+			resp.Diagnostics.AddWarning(
+				"Could not check template variables",
+				fmt.Sprintf("Unable to read %s to check context_json against the variables it uses: %v", templatePath.ValueString(), err),
+			)
+			return
+		}
+	}
+	checkTemplateVariables(template, bundle, config.ContextJSON.ValueString(), &resp.Diagnostics)
+}
+
 func (r *dagGeneratorResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, span := tracing.Start(ctx, "mirage_dag_generator.Create")
+	defer func() { tracing.End(span, resp.Diagnostics) }()
+
 	var plan dagGeneratorResourceModel
 	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	span.SetAttributes(attribute.String("mirage.target_path", plan.TargetPath.ValueString()))
 
-	gcsPath := plan.TemplateGCSPath.ValueString()
+	gcsPath := plan.TemplatePath.ValueString()
 	content := plan.TemplateContent.ValueString()
 
 	if (gcsPath == "" && content == "") || (gcsPath != "" && content != "") {
 		resp.Diagnostics.AddError(
 			"Invalid Configuration",
-			"Exactly one of `template_gcs_path` or `template_content` must be specified.",
+			"Exactly one of `template_path` or `template_content` must be specified.",
+		)
+		return
+	}
+	if gcsPath != "" && !storage.IsGCS(gcsPath) && usesBackend(plan.TargetPath.ValueString(), plan.DagGeneratorBackendURL.ValueString()) {
+		resp.Diagnostics.AddError(
+			"Invalid Configuration",
+			fmt.Sprintf("The backend can only read gs:// templates, not %q. Use a file://, s3:// or az:// `target_path` or omit `dag_generator_backend_url` to render in the provider.", gcsPath),
 		)
 		return
 	}
+	if !plan.ContextSchema.IsNull() {
+		r.providerData.validateContext(ctx, plan.ContextSchema.ValueString(), plan.ContextJSON.ValueString(), &resp.Diagnostics)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
 
-	// Initialize API client and service using backend_url from the plan
-	apiClient := client.NewDagGeneratorAPIClientWithAuth(plan.DagGeneratorBackendURL.ValueString(), plan.UseGCPServiceAccountAuth.ValueBool())
-	dagGenService := &client.DagGeneratorService{Client: apiClient}
+	bundle, diags := loadTemplateBundle(ctx, plan.TemplateFiles, plan.TemplateDir)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
 
-	contextJSON := plan.ContextJSON.ValueString()
-	generationResult, err := dagGenService.Generate(ctx, gcsPath, content, plan.TargetGCSPath.ValueString(), contextJSON)
+	// Initialize API client and service using dag_generator_backend_url from the plan
+	headers, diags := stringMapValue(ctx, plan.Headers)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	dagGenService, err := r.providerData.generatorFor(plan.TargetPath.ValueString(), plan.DagGeneratorBackendURL.ValueString(), plan.UseGCPServiceAccountAuth.ValueBool(), headers)
 	if err != nil {
-		resp.Diagnostics.AddError("Failed to generate DAG", err.Error())
+		resp.Diagnostics.AddError("Failed to create backend client", err.Error())
 		return
 	}
 
-	plan.ID = plan.TargetGCSPath
-	plan.GeneratedFileChecksum = basetypes.NewStringValue(generationResult.Checksum)
-	plan.GCSGenerationNumber = basetypes.NewStringValue(generationResult.Generation)
+	metadata, diags := stringMapValue(ctx, plan.Metadata)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
 
-	// Store template checksum if using GCS template
-	if gcsPath != "" {
-		templateStatus, err := dagGenService.GetTemplateStatus(ctx, gcsPath)
+	contextJSON := plan.ContextJSON.ValueString()
+	linter, diags := newAirflowLinter(ctx, plan.AirflowLint, contextJSON)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	var provenanceHeader string
+	if plan.ProvenanceHeader.ValueBool() {
+		provenanceHeader, err = r.providerData.provenance(ctx, dagGenService, gcsPath, content, bundle, &resp.Diagnostics).header(plan.TargetPath.ValueString(), contextJSON)
 		if err != nil {
-			// Warning but don't fail
-			resp.Diagnostics.AddWarning(
-				"Could not get template status",
-				fmt.Sprintf("Unable to get template status for %s: %v", gcsPath, err),
-			)
-			plan.TemplateChecksum = basetypes.NewStringValue("")
-		} else {
-			plan.TemplateChecksum = basetypes.NewStringValue(templateStatus.Checksum)
+			resp.Diagnostics.AddAttributeError(path.Root("provenance_header"), "Invalid Configuration", err.Error())
+			return
 		}
-	} else {
-		plan.TemplateChecksum = basetypes.NewStringValue("")
 	}
+	generationResult, err := generateLinted(ctx, dagGenService, client.GenerateRequest{
+		TemplateGCSPath:  gcsPath,
+		TemplateContent:  content,
+		TargetGCSPath:    plan.TargetPath.ValueString(),
+		ContextJSON:      contextJSON,
+		TemplateFiles:    bundle,
+		ContentType:      plan.ContentType.ValueString(),
+		CacheControl:     plan.CacheControl.ValueString(),
+		Metadata:         metadata,
+		ProvenanceHeader: provenanceHeader,
+		ValidatePython:   plan.ValidatePython.ValueBool(),
+		StrictUndefined:  plan.StrictUndefined.ValueBool(),
+	}, linter, &resp.Diagnostics)
+	if err != nil {
+		addGenerateError(&resp.Diagnostics, "Failed to generate DAG", plan.TargetPath.ValueString(), err)
+		return
+	}
+
+	plan.ID = plan.TargetPath
+	plan.GeneratedFileChecksum = basetypes.NewStringValue(generationResult.Checksum)
+	plan.Generation = basetypes.NewStringValue(generationResult.Generation)
+	plan.GCSGenerationNumber = plan.Generation
+	plan.TemplateBundleChecksum = basetypes.NewStringValue(bundleChecksum(bundle))
+
+	plan.TemplateChecksum = resolveTemplateChecksum(ctx, dagGenService, gcsPath, plan.TemplateChecksum, &resp.Diagnostics)
 
 	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
 }
 
 func (r *dagGeneratorResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, span := tracing.Start(ctx, "mirage_dag_generator.Read")
+	defer func() { tracing.End(span, resp.Diagnostics) }()
+
 	var state dagGeneratorResourceModel
 	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	span.SetAttributes(attribute.String("mirage.target_path", state.TargetPath.ValueString()))
 
-	// Initialize API client and service using backend_url from state
-	apiClient := client.NewDagGeneratorAPIClientWithAuth(state.DagGeneratorBackendURL.ValueString(), state.UseGCPServiceAccountAuth.ValueBool())
-	dagGenService := &client.DagGeneratorService{Client: apiClient}
+	// Initialize API client and service using dag_generator_backend_url from state
+	headers, diags := stringMapValue(ctx, state.Headers)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	dagGenService, err := r.providerData.generatorFor(state.TargetPath.ValueString(), state.DagGeneratorBackendURL.ValueString(), state.UseGCPServiceAccountAuth.ValueBool(), headers)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to create backend client", err.Error())
+		return
+	}
 
-	status, err := dagGenService.GetStatus(ctx, state.TargetGCSPath.ValueString())
+	status, err := dagGenService.GetStatus(ctx, state.TargetPath.ValueString())
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		if errors.Is(err, client.ErrNotFound) {
 			resp.Diagnostics.AddWarning("File not found", "The resource no longer exists in the backend and will be removed from the state.")
 			resp.State.RemoveResource(ctx)
 		} else {
 			// For any other error (e.g., network), report it and stop.
-			resp.Diagnostics.AddError("Failed to read resource status", err.Error())
+			addBackendError(&resp.Diagnostics, "Failed to read resource status", err)
 		}
 		return
 	}
 
 	state.GeneratedFileChecksum = basetypes.NewStringValue(status.Checksum)
-	state.GCSGenerationNumber = basetypes.NewStringValue(status.Generation)
+	state.Generation = basetypes.NewStringValue(status.Generation)
+	state.GCSGenerationNumber = state.Generation
+
+	// Object metadata set outside Terraform shows up as drift, but only for
+	// the settings this resource manages.
+	if !state.ContentType.IsNull() {
+		state.ContentType = basetypes.NewStringValue(status.ContentType)
+	}
+	if !state.CacheControl.IsNull() {
+		state.CacheControl = basetypes.NewStringValue(status.CacheControl)
+	}
+	if !state.Metadata.IsNull() {
+		state.Metadata, diags = managedMetadata(ctx, state.Metadata, status.Metadata)
+		resp.Diagnostics.Append(diags...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
 
 	// Update template checksum if using GCS template
-	if state.TemplateGCSPath.ValueString() != "" {
-		templateStatus, err := dagGenService.GetTemplateStatus(ctx, state.TemplateGCSPath.ValueString())
+	if state.TemplatePath.ValueString() != "" {
+		templateStatus, err := dagGenService.GetTemplateStatus(ctx, state.TemplatePath.ValueString())
 		if err != nil {
 			// Warning but don't fail
 			resp.Diagnostics.AddWarning(
 				"Could not get template status",
-				fmt.Sprintf("Unable to get template status for %s: %v", state.TemplateGCSPath.ValueString(), err),
+				fmt.Sprintf("Unable to get template status for %s: %v", state.TemplatePath.ValueString(), err),
 			)
 		} else {
 			state.TemplateChecksum = basetypes.NewStringValue(templateStatus.Checksum)
@@ -198,11 +525,15 @@ func (r *dagGeneratorResource) Read(ctx context.Context, req resource.ReadReques
 }
 
 func (r *dagGeneratorResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx, span := tracing.Start(ctx, "mirage_dag_generator.Update")
+	defer func() { tracing.End(span, resp.Diagnostics) }()
+
 	var plan dagGeneratorResourceModel
 	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	span.SetAttributes(attribute.String("mirage.target_path", plan.TargetPath.ValueString()))
 
 	var state dagGeneratorResourceModel
 	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
@@ -210,37 +541,51 @@ func (r *dagGeneratorResource) Update(ctx context.Context, req resource.UpdateRe
 		return
 	}
 
-	gcsPath := plan.TemplateGCSPath.ValueString()
+	gcsPath := plan.TemplatePath.ValueString()
 	content := plan.TemplateContent.ValueString()
 
 	if (gcsPath == "" && content == "") || (gcsPath != "" && content != "") {
 		resp.Diagnostics.AddError(
 			"Invalid Configuration",
-			"Exactly one of `template_gcs_path` or `template_content` must be specified.",
+			"Exactly one of `template_path` or `template_content` must be specified.",
+		)
+		return
+	}
+	if gcsPath != "" && !storage.IsGCS(gcsPath) && usesBackend(plan.TargetPath.ValueString(), plan.DagGeneratorBackendURL.ValueString()) {
+		resp.Diagnostics.AddError(
+			"Invalid Configuration",
+			fmt.Sprintf("The backend can only read gs:// templates, not %q. Use a file://, s3:// or az:// `target_path` or omit `dag_generator_backend_url` to render in the provider.", gcsPath),
 		)
 		return
 	}
+	if !plan.ContextSchema.IsNull() {
+		r.providerData.validateContext(ctx, plan.ContextSchema.ValueString(), plan.ContextJSON.ValueString(), &resp.Diagnostics)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
 
-	// Initialize API client and service using backend_url from the plan
-	apiClient := client.NewDagGeneratorAPIClientWithAuth(plan.DagGeneratorBackendURL.ValueString(), plan.UseGCPServiceAccountAuth.ValueBool())
-	dagGenService := &client.DagGeneratorService{Client: apiClient}
+	bundle, diags := loadTemplateBundle(ctx, plan.TemplateFiles, plan.TemplateDir)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
 
-	// Check if target_gcs_path has changed - if so, delete the old file first
-	oldTargetPath := state.TargetGCSPath.ValueString()
-	newTargetPath := plan.TargetGCSPath.ValueString()
-	
-	if oldTargetPath != newTargetPath && oldTargetPath != "" {
-		// Delete the old file
-		err := dagGenService.Delete(ctx, oldTargetPath)
-		if err != nil {
-			// Log warning but don't fail - the old file might already be gone
-			resp.Diagnostics.AddWarning(
-				"Failed to delete old file",
-				fmt.Sprintf("Could not delete old file at %s: %v", oldTargetPath, err),
-			)
-		}
+	// Initialize API client and service using dag_generator_backend_url from the plan
+	headers, diags := stringMapValue(ctx, plan.Headers)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	dagGenService, err := r.providerData.generatorFor(plan.TargetPath.ValueString(), plan.DagGeneratorBackendURL.ValueString(), plan.UseGCPServiceAccountAuth.ValueBool(), headers)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to create backend client", err.Error())
+		return
 	}
 
+	oldTargetPath := state.TargetPath.ValueString()
+	newTargetPath := plan.TargetPath.ValueString()
+
 	// Check if we need to regenerate due to template changes (only for GCS templates)
 	shouldRegenerate := true
 	if gcsPath != "" {
@@ -256,12 +601,15 @@ func (r *dagGeneratorResource) Update(ctx context.Context, req resource.UpdateRe
 			// Compare template checksum with what we have in state
 			currentTemplateChecksum := templateStatus.Checksum
 			storedTemplateChecksum := state.TemplateChecksum.ValueString()
-			
-			if currentTemplateChecksum != "" && storedTemplateChecksum != "" && currentTemplateChecksum == storedTemplateChecksum {
+
+			// A template_checksum set in configuration changes when the template does.
+			configuredChanged := !plan.TemplateChecksum.IsUnknown() && plan.TemplateChecksum.ValueString() != storedTemplateChecksum
+
+			if currentTemplateChecksum != "" && storedTemplateChecksum != "" && currentTemplateChecksum == storedTemplateChecksum && !configuredChanged {
 				// Template hasn't changed, check if other parameters changed
-				if plan.ContextJSON.ValueString() == state.ContextJSON.ValueString() && 
-				   plan.TemplateContent.ValueString() == state.TemplateContent.ValueString() &&
-				   oldTargetPath == newTargetPath {
+				if plan.ContextJSON.ValueString() == state.ContextJSON.ValueString() &&
+					plan.TemplateContent.ValueString() == state.TemplateContent.ValueString() &&
+					oldTargetPath == newTargetPath {
 					shouldRegenerate = false
 				}
 			}
@@ -269,67 +617,230 @@ func (r *dagGeneratorResource) Update(ctx context.Context, req resource.UpdateRe
 	} else {
 		// For inline templates, check if content has changed
 		if plan.TemplateContent.ValueString() == state.TemplateContent.ValueString() &&
-		   plan.ContextJSON.ValueString() == state.ContextJSON.ValueString() &&
-		   oldTargetPath == newTargetPath {
+			plan.ContextJSON.ValueString() == state.ContextJSON.ValueString() &&
+			oldTargetPath == newTargetPath {
 			shouldRegenerate = false
 		}
 	}
 
+	// Any added, removed or edited file in the template bundle requires regeneration.
+	bundleSum := bundleChecksum(bundle)
+	if bundleSum != state.TemplateBundleChecksum.ValueString() {
+		shouldRegenerate = true
+	}
+	// Object metadata is only set when the file is written.
+	if !plan.ContentType.Equal(state.ContentType) || !plan.CacheControl.Equal(state.CacheControl) || !plan.Metadata.Equal(state.Metadata) {
+		shouldRegenerate = true
+	}
+	// Turning the provenance header on or off rewrites the file, but its
+	// content, such as the provider version, is deliberately not compared.
+	if plan.ProvenanceHeader.ValueBool() != state.ProvenanceHeader.ValueBool() {
+		shouldRegenerate = true
+	}
+	plan.TemplateBundleChecksum = basetypes.NewStringValue(bundleSum)
+
 	if shouldRegenerate {
+		var ifGenerationMatch string
+		if oldTargetPath == newTargetPath {
+			ifGenerationMatch = state.Generation.ValueString()
+		}
+
+		metadata, diags := stringMapValue(ctx, plan.Metadata)
+		resp.Diagnostics.Append(diags...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+
 		contextJSON := plan.ContextJSON.ValueString()
-		generationResult, err := dagGenService.Generate(ctx, gcsPath, content, newTargetPath, contextJSON)
+		linter, diags := newAirflowLinter(ctx, plan.AirflowLint, contextJSON)
+		resp.Diagnostics.Append(diags...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		var provenanceHeader string
+		if plan.ProvenanceHeader.ValueBool() {
+			provenanceHeader, err = r.providerData.provenance(ctx, dagGenService, gcsPath, content, bundle, &resp.Diagnostics).header(newTargetPath, contextJSON)
+			if err != nil {
+				resp.Diagnostics.AddAttributeError(path.Root("provenance_header"), "Invalid Configuration", err.Error())
+				return
+			}
+		}
+		generationResult, err := generateLinted(ctx, dagGenService, client.GenerateRequest{
+			TemplateGCSPath:  gcsPath,
+			TemplateContent:  content,
+			TargetGCSPath:    newTargetPath,
+			ContextJSON:      contextJSON,
+			TemplateFiles:    bundle,
+			ContentType:      plan.ContentType.ValueString(),
+			CacheControl:     plan.CacheControl.ValueString(),
+			Metadata:         metadata,
+			ProvenanceHeader: provenanceHeader,
+			ValidatePython:   plan.ValidatePython.ValueBool(),
+			StrictUndefined:  plan.StrictUndefined.ValueBool(),
+			// Fail rather than overwrite a file that changed since it was last read.
+			IfGenerationMatch: ifGenerationMatch,
+		}, linter, &resp.Diagnostics)
 		if err != nil {
-			resp.Diagnostics.AddError("Failed to update DAG", err.Error())
+			addGenerateError(&resp.Diagnostics, "Failed to update DAG", newTargetPath, err)
 			return
 		}
 
-		plan.ID = plan.TargetGCSPath
+		plan.ID = plan.TargetPath
 		plan.GeneratedFileChecksum = basetypes.NewStringValue(generationResult.Checksum)
-		plan.GCSGenerationNumber = basetypes.NewStringValue(generationResult.Generation)
+		plan.Generation = basetypes.NewStringValue(generationResult.Generation)
+		plan.GCSGenerationNumber = plan.Generation
 
-		// Store template checksum if using GCS template
-		if gcsPath != "" {
-			templateStatus, err := dagGenService.GetTemplateStatus(ctx, gcsPath)
-			if err != nil {
-				// Warning but don't fail
-				resp.Diagnostics.AddWarning(
-					"Could not get template status",
-					fmt.Sprintf("Unable to get template status for %s: %v", gcsPath, err),
-				)
-				plan.TemplateChecksum = basetypes.NewStringValue("")
-			} else {
-				plan.TemplateChecksum = basetypes.NewStringValue(templateStatus.Checksum)
-			}
-		} else {
-			plan.TemplateChecksum = basetypes.NewStringValue("")
-		}
+		plan.TemplateChecksum = resolveTemplateChecksum(ctx, dagGenService, gcsPath, plan.TemplateChecksum, &resp.Diagnostics)
 	} else {
 		// No regeneration needed, just update the target path in state
-		plan.ID = plan.TargetGCSPath
+		plan.ID = plan.TargetPath
 		plan.GeneratedFileChecksum = state.GeneratedFileChecksum
-		plan.GCSGenerationNumber = state.GCSGenerationNumber
+		plan.Generation = state.Generation
+		plan.GCSGenerationNumber = state.Generation
 		plan.TemplateChecksum = state.TemplateChecksum
 	}
 
+	// If target_path has changed, delete the old file now that the new one is
+	// written, so a failed generation leaves the old file in place.
+	if oldTargetPath != newTargetPath && oldTargetPath != "" {
+		// Delete the old file, which may live in a different storage than the new one
+		oldService, err := r.providerData.generatorFor(oldTargetPath, state.DagGeneratorBackendURL.ValueString(), state.UseGCPServiceAccountAuth.ValueBool(), headers)
+		if err == nil {
+			err = oldService.Delete(ctx, oldTargetPath)
+		}
+		if err != nil {
+			// Log warning but don't fail - the old file might already be gone
+			resp.Diagnostics.AddWarning(
+				"Failed to delete old file",
+				fmt.Sprintf("Could not delete old file at %s: %v", oldTargetPath, err),
+			)
+		}
+	}
+
 	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
 }
 
 func (r *dagGeneratorResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, span := tracing.Start(ctx, "mirage_dag_generator.Delete")
+	defer func() { tracing.End(span, resp.Diagnostics) }()
+
 	var state dagGeneratorResourceModel
 	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	span.SetAttributes(attribute.String("mirage.target_path", state.TargetPath.ValueString()))
 
-	// Initialize API client and service using backend_url from state
-	apiClient := client.NewDagGeneratorAPIClientWithAuth(state.DagGeneratorBackendURL.ValueString(), state.UseGCPServiceAccountAuth.ValueBool())
-	dagGenService := &client.DagGeneratorService{Client: apiClient}
+	// Initialize API client and service using dag_generator_backend_url from state
+	headers, diags := stringMapValue(ctx, state.Headers)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	dagGenService, err := r.providerData.generatorFor(state.TargetPath.ValueString(), state.DagGeneratorBackendURL.ValueString(), state.UseGCPServiceAccountAuth.ValueBool(), headers)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to create backend client", err.Error())
+		return
+	}
 
-	err := dagGenService.Delete(ctx, state.TargetGCSPath.ValueString())
+	err = dagGenService.Delete(ctx, state.TargetPath.ValueString())
 	if err != nil {
-		resp.Diagnostics.AddError("Failed to delete DAG", err.Error())
+		addBackendError(&resp.Diagnostics, "Failed to delete DAG", err)
+		return
+	}
+}
+
+// addGenerateError reports a failed generation of targetPath. Rendered output
+// rejected by validate_python is reported as such, since the target was left
+// untouched, and output rejected by airflow_lint already has a diagnostic for
+// each finding. A variable rejected by strict_undefined is reported on
+// context_json.
+func addGenerateError(diags *diag.Diagnostics, summary, targetPath string, err error) {
+	if errors.Is(err, errAirflowLint) {
+		return
+	}
+	var undefinedErr *render.UndefinedError
+	if errors.As(err, &undefinedErr) {
+		where := fmt.Sprintf("line %d", undefinedErr.Line)
+		if undefinedErr.Template != "" {
+			where += " of " + undefinedErr.Template
+		}
+		diags.AddAttributeError(
+			path.Root("context_json"),
+			"Undefined Template Variable",
+			fmt.Sprintf("The template uses %s on %s, which context_json does not define, and strict_undefined is set. %s was not written and its previous generation, if any, is left in place.", undefinedErr.Name, where, targetPath),
+		)
+		return
+	}
+	var syntaxErr *pysyntax.Error
+	if errors.As(err, &syntaxErr) {
+		diags.AddAttributeError(
+			path.Root("validate_python"),
+			"Invalid Python",
+			fmt.Sprintf("%s was not written and its previous generation, if any, is left in place: %v", targetPath, err),
+		)
 		return
 	}
+	addBackendError(diags, summary, err)
+}
+
+// resolveTemplateChecksum returns the template checksum to store in state. A
+// checksum set in configuration is kept so the state matches the plan; otherwise
+// it is looked up from the backend for GCS templates.
+func resolveTemplateChecksum(ctx context.Context, dagGenService generator, gcsPath string, planned types.String, diags *diag.Diagnostics) types.String {
+	if gcsPath == "" {
+		if !planned.IsUnknown() && !planned.IsNull() {
+			return planned
+		}
+		return basetypes.NewStringValue("")
+	}
+
+	templateStatus, err := dagGenService.GetTemplateStatus(ctx, gcsPath)
+	if err != nil {
+		// Warning but don't fail
+		diags.AddWarning(
+			"Could not get template status",
+			fmt.Sprintf("Unable to get template status for %s: %v", gcsPath, err),
+		)
+		if !planned.IsUnknown() && !planned.IsNull() {
+			return planned
+		}
+		return basetypes.NewStringValue("")
+	}
+
+	if planned.IsUnknown() || planned.IsNull() {
+		return basetypes.NewStringValue(templateStatus.Checksum)
+	}
+	if planned.ValueString() != templateStatus.Checksum {
+		diags.AddWarning(
+			"Template checksum mismatch",
+			fmt.Sprintf("The configured template_checksum %q does not match the checksum %q of %s. The file was generated from the current template, but the configured checksum is kept in state, so a later change to the template only regenerates the file when template_checksum changes too. Set template_checksum to the checksum of this template, such as a mirage_template resource's checksum.",
+				planned.ValueString(), templateStatus.Checksum, gcsPath),
+		)
+	}
+	return planned
+}
+
+// managedMetadata returns the current values of the metadata keys in managed,
+// so edits and removals of those keys are detected while metadata added by
+// other tools is ignored. Keys are also matched in lowercase, as S3 and Azure
+// report them.
+func managedMetadata(ctx context.Context, managed types.Map, current map[string]string) (types.Map, diag.Diagnostics) {
+	keys, diags := stringMapValue(ctx, managed)
+	if diags.HasError() {
+		return managed, diags
+	}
+	values := map[string]string{}
+	for k := range keys {
+		if v, ok := current[k]; ok {
+			values[k] = v
+		} else if v, ok := current[strings.ToLower(k)]; ok {
+			values[k] = v
+		}
+	}
+	result, d := types.MapValueFrom(ctx, types.StringType, values)
+	diags.Append(d...)
+	return result, diags
 }
 
 func (r *dagGeneratorResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
@@ -0,0 +1,425 @@
+package provider
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/client"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/storage/storagetest"
+)
+
+// localTarget returns a file:// path to name in a temporary directory, and
+// the path of that file.
+func localTarget(t *testing.T, name string) (string, string) {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), name)
+	return "file://" + filepath.ToSlash(file), file
+}
+
+func TestDagGeneratorLocalLifecycle(t *testing.T) {
+	target, file := localTarget(t, "orders.py")
+	r := newTestProvider(t, nil).resource("mirage_dag_generator")
+
+	config := map[string]tftypes.Value{
+		"template_content": stringValue("dag_id = '{{ dag_id }}'\n"),
+		"target_path":      stringValue(target),
+		"context_json":     stringValue(`{"dag_id": "orders"}`),
+	}
+	r.mustApply(config)
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "dag_id = 'orders'"; string(content) != want {
+		t.Errorf("generated %q, want %q", content, want)
+	}
+	if got, want := r.stringAttr("generated_file_checksum"), client.CRC32C(content); got != want {
+		t.Errorf("generated_file_checksum = %q, want %q", got, want)
+	}
+	generation := r.stringAttr("generation")
+	if generation == "" {
+		t.Error("generation is empty")
+	}
+	if got := r.stringAttr("gcs_generation_number"); got != generation {
+		t.Errorf("gcs_generation_number = %q, want %q", got, generation)
+	}
+	if got := r.stringAttr("target_gcs_path"); got != target {
+		t.Errorf("target_gcs_path = %q, want %q", got, target)
+	}
+
+	requireNoErrors(t, "refresh", r.refresh())
+	if !r.planIsEmpty(config) {
+		t.Error("plan after apply is not empty")
+	}
+
+	config["context_json"] = stringValue(`{"dag_id": "payments"}`)
+	r.mustApply(config)
+	content, err = os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "dag_id = 'payments'"; string(content) != want {
+		t.Errorf("regenerated %q, want %q", content, want)
+	}
+	if r.stringAttr("generation") == generation {
+		t.Error("generation did not change on regeneration")
+	}
+
+	r.mustApply(nil)
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("file still exists after destroy: %v", err)
+	}
+}
+
+func TestDagGeneratorUpgradeFromV0(t *testing.T) {
+	template, templateFile := localTarget(t, "orders.py.j2")
+	if err := os.WriteFile(templateFile, []byte("dag_id = '{{ dag_id }}'\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	target, _ := localTarget(t, "orders.py")
+	p := newTestProvider(t, nil)
+
+	newNames := map[string]tftypes.Value{
+		"template_path": stringValue(template),
+		"target_path":   stringValue(target),
+		"context_json":  stringValue(`{"dag_id": "orders"}`),
+	}
+	oldNames := map[string]tftypes.Value{
+		"template_gcs_path": stringValue(template),
+		"target_gcs_path":   stringValue(target),
+		"context_json":      stringValue(`{"dag_id": "orders"}`),
+	}
+
+	// Generate the file, then describe it as a version 0 state would.
+	applied := p.resource("mirage_dag_generator")
+	applied.mustApply(newNames)
+	raw := func(name string) any {
+		if applied.attr(name).IsNull() {
+			return nil
+		}
+		return applied.stringAttr(name)
+	}
+	v0 := map[string]any{
+		"id":                       raw("id"),
+		"template_gcs_path":        template,
+		"target_gcs_path":          target,
+		"context_json":             `{"dag_id": "orders"}`,
+		"generated_file_checksum":  raw("generated_file_checksum"),
+		"gcs_generation_number":    raw("generation"),
+		"template_checksum":        raw("template_checksum"),
+		"template_bundle_checksum": raw("template_bundle_checksum"),
+	}
+
+	for name, config := range map[string]map[string]tftypes.Value{"new names": newNames, "old names": oldNames} {
+		t.Run(name, func(t *testing.T) {
+			r := p.resource("mirage_dag_generator")
+			requireNoErrors(t, "upgrade", r.upgrade(0, v0))
+			for attr, want := range map[string]string{
+				"template_path":         template,
+				"template_gcs_path":     template,
+				"target_path":           target,
+				"target_gcs_path":       target,
+				"generation":            applied.stringAttr("generation"),
+				"gcs_generation_number": applied.stringAttr("generation"),
+			} {
+				if got := r.stringAttr(attr); got != want {
+					t.Errorf("%s = %q after the upgrade, want %q", attr, got, want)
+				}
+			}
+
+			requireNoErrors(t, "refresh", r.refresh())
+			if !r.planIsEmpty(config) {
+				t.Error("plan after the upgrade is not empty")
+			}
+
+			var deprecated []string
+			for _, d := range r.validate(config) {
+				if d.Severity == tfprotov6.DiagnosticSeverityWarning && strings.Contains(d.Detail, "will be removed in a future major version") {
+					deprecated = append(deprecated, d.Detail)
+				}
+			}
+			wantWarnings := 0
+			if name == "old names" {
+				wantWarnings = 2
+			}
+			if len(deprecated) != wantWarnings {
+				t.Errorf("got %d deprecation warnings, want %d: %q", len(deprecated), wantWarnings, deprecated)
+			}
+		})
+	}
+}
+
+func TestDagGeneratorLocalFileRemovedOutsideTerraform(t *testing.T) {
+	target, file := localTarget(t, "orders.py")
+	r := newTestProvider(t, nil).resource("mirage_dag_generator")
+
+	r.mustApply(map[string]tftypes.Value{
+		"template_content": stringValue("a = 1\n"),
+		"target_path":      stringValue(target),
+	})
+	if err := os.Remove(file); err != nil {
+		t.Fatal(err)
+	}
+
+	requireNoErrors(t, "refresh", r.refresh())
+	if !r.state.IsNull() {
+		t.Error("the resource is still in state after its file was removed")
+	}
+}
+
+func TestDagGeneratorBackendFileRemovedOutsideTerraform(t *testing.T) {
+	// statusCode is what /status answers once the file has been generated.
+	statusCode := http.StatusOK
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/generate":
+			_ = json.NewEncoder(w).Encode(client.GenerateResponse{Checksum: "c1", Generation: "1"})
+		case "/status":
+			if statusCode != http.StatusOK {
+				// The body must not matter: only the status says the file is gone.
+				http.Error(w, "template not found in cache", statusCode)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(client.StatusResponse{Checksum: "c1", Generation: "1"})
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	t.Cleanup(backend.Close)
+	r := newTestProvider(t, nil).resource("mirage_dag_generator")
+
+	r.mustApply(map[string]tftypes.Value{
+		"template_content":          stringValue("a = 1\n"),
+		"target_path":               stringValue("gs://dags/orders.py"),
+		"dag_generator_backend_url": stringValue(backend.URL),
+	})
+
+	statusCode = http.StatusInternalServerError
+	requireError(t, r.refresh(), "Failed to read resource status")
+	if r.state.IsNull() {
+		t.Fatal("a backend error removed the resource from state")
+	}
+
+	statusCode = http.StatusNotFound
+	requireNoErrors(t, "refresh", r.refresh())
+	if !r.state.IsNull() {
+		t.Error("the resource is still in state after the backend reported its file missing")
+	}
+}
+
+func TestDagGeneratorLocalFileChangedOutsideTerraform(t *testing.T) {
+	target, file := localTarget(t, "orders.py")
+	r := newTestProvider(t, nil).resource("mirage_dag_generator")
+
+	r.mustApply(map[string]tftypes.Value{
+		"template_content": stringValue("a = 1\n"),
+		"target_path":      stringValue(target),
+	})
+	if err := os.WriteFile(file, []byte("a = 2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	requireNoErrors(t, "refresh", r.refresh())
+	if got, want := r.stringAttr("generated_file_checksum"), client.CRC32C([]byte("a = 2\n")); got != want {
+		t.Errorf("generated_file_checksum after refresh = %q, want %q", got, want)
+	}
+}
+
+func TestDagGeneratorLocalTemplatePath(t *testing.T) {
+	templateFile := filepath.Join(t.TempDir(), "dag.py.j2")
+	if err := os.WriteFile(templateFile, []byte("dag_id = '{{ dag_id }}'\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	target, file := localTarget(t, "orders.py")
+	r := newTestProvider(t, nil).resource("mirage_dag_generator")
+
+	r.mustApply(map[string]tftypes.Value{
+		"template_path": stringValue("file://" + filepath.ToSlash(templateFile)),
+		"target_path":   stringValue(target),
+		"context_json":  stringValue(`{"dag_id": "orders"}`),
+	})
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "dag_id = 'orders'"; string(content) != want {
+		t.Errorf("generated %q, want %q", content, want)
+	}
+	if r.stringAttr("template_checksum") == "" {
+		t.Error("template_checksum is empty")
+	}
+}
+
+// directGCS starts a fake GCS server that resources without a backend write
+// gs:// targets to.
+func directGCS(t *testing.T) *storagetest.GCSServer {
+	t.Helper()
+	server := storagetest.NewGCSServer(t)
+	t.Setenv("STORAGE_EMULATOR_HOST", server.URL)
+	return server
+}
+
+func TestDagGeneratorDirectGCSLifecycle(t *testing.T) {
+	gcs := directGCS(t)
+	const target = "gs://dags/orders.py"
+	const template = "gs://templates/dag.py.j2"
+	gcs.Put(template, []byte("dag_id = '{{ dag_id }}'"))
+	r := newTestProvider(t, nil).resource("mirage_dag_generator")
+
+	config := map[string]tftypes.Value{
+		"template_path": stringValue(template),
+		"target_path":   stringValue(target),
+		"context_json":  stringValue(`{"dag_id": "orders"}`),
+		"cache_control": stringValue("no-cache"),
+		"metadata":      stringMapValueOf(map[string]string{"owner": "data"}),
+	}
+	r.mustApply(config)
+
+	content, ok := gcs.Object(target)
+	if !ok {
+		t.Fatalf("%s was not written", target)
+	}
+	if want := "dag_id = 'orders'"; string(content) != want {
+		t.Errorf("generated %q, want %q", content, want)
+	}
+	if got, want := r.stringAttr("generated_file_checksum"), client.CRC32C(content); got != want {
+		t.Errorf("generated_file_checksum = %q, want %q", got, want)
+	}
+	if got := gcs.Metadata(target)["owner"]; got != "data" {
+		t.Errorf("owner label = %q, want data", got)
+	}
+	if got, want := r.stringAttr("template_checksum"), client.CRC32C([]byte("dag_id = '{{ dag_id }}'")); got != want {
+		t.Errorf("template_checksum = %q, want %q", got, want)
+	}
+
+	requireNoErrors(t, "refresh", r.refresh())
+	if !r.planIsEmpty(config) {
+		t.Error("plan after apply is not empty")
+	}
+
+	r.mustApply(nil)
+	if _, ok := gcs.Object(target); ok {
+		t.Error("the object still exists after destroy")
+	}
+}
+
+func TestDagGeneratorDirectGCSGenerationPrecondition(t *testing.T) {
+	gcs := directGCS(t)
+	const target = "gs://dags/orders.py"
+	r := newTestProvider(t, nil).resource("mirage_dag_generator")
+
+	config := map[string]tftypes.Value{
+		"template_content": stringValue("a = {{ a }}"),
+		"target_path":      stringValue(target),
+		"context_json":     stringValue(`{"a": 1}`),
+	}
+	r.mustApply(config)
+
+	// The object changes after the last refresh, so regenerating it must not
+	// overwrite that change.
+	gcs.Put(target, []byte("a = 'hand edited'"))
+	config["context_json"] = stringValue(`{"a": 2}`)
+	requireError(t, r.apply(config), "generation does not match")
+	if got, _ := gcs.Object(target); string(got) != "a = 'hand edited'" {
+		t.Errorf("the object was overwritten with %q", got)
+	}
+
+	requireNoErrors(t, "refresh", r.refresh())
+	r.mustApply(config)
+	if got, _ := gcs.Object(target); string(got) != "a = 2" {
+		t.Errorf("generated %q after refresh, want %q", got, "a = 2")
+	}
+}
+
+func TestDagGeneratorDirectGCSWriteFailureKeepsState(t *testing.T) {
+	gcs := directGCS(t)
+	const target = "gs://dags/orders.py"
+	r := newTestProvider(t, nil).resource("mirage_dag_generator")
+
+	config := map[string]tftypes.Value{
+		"template_content": stringValue("a = {{ a }}"),
+		"target_path":      stringValue(target),
+		"context_json":     stringValue(`{"a": 1}`),
+	}
+	r.mustApply(config)
+	checksum := r.stringAttr("generated_file_checksum")
+
+	gcs.FailWrites(target, true)
+	config["context_json"] = stringValue(`{"a": 2}`)
+	requireError(t, r.apply(config), "Failed to update DAG")
+	if got := r.stringAttr("generated_file_checksum"); got != checksum {
+		t.Errorf("generated_file_checksum = %q after a failed update, want the previous %q", got, checksum)
+	}
+	if got, _ := gcs.Object(target); string(got) != "a = 1" {
+		t.Errorf("the object holds %q after a failed update", got)
+	}
+}
+
+func TestDagGeneratorRejectsInvalidAzureMetadataKey(t *testing.T) {
+	r := newTestProvider(t, nil).resource("mirage_dag_generator")
+
+	resp, _ := r.plan(map[string]tftypes.Value{
+		"template_content": stringValue("a = 1"),
+		"target_path":      stringValue("az://dags/orders.py"),
+		"metadata":         stringMapValueOf(map[string]string{"source-repo": "etl", "owner": "data"}),
+	})
+	requireError(t, resp.Diagnostics, "Invalid Metadata Key")
+	if len(resp.Diagnostics) != 1 {
+		t.Errorf("%d diagnostics, want one for source-repo:\n%s", len(resp.Diagnostics), formatDiagnostics(resp.Diagnostics))
+	}
+}
+
+func TestDagGeneratorProvenanceHeaderSQLComments(t *testing.T) {
+	target, file := localTarget(t, "orders.sql")
+	r := newTestProvider(t, nil).resource("mirage_dag_generator")
+
+	r.mustApply(map[string]tftypes.Value{
+		"template_content":  stringValue("SELECT 1"),
+		"target_path":       stringValue(target),
+		"provenance_header": boolValue(true),
+	})
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(content), "-- ---") || strings.Contains(string(content), "\n# ") {
+		t.Errorf("orders.sql does not carry an SQL comment header:\n%s", content)
+	}
+
+	resp, _ := r.plan(map[string]tftypes.Value{
+		"template_content":  stringValue("{}"),
+		"target_path":       stringValue(target + ".json"),
+		"provenance_header": boolValue(true),
+	})
+	requireError(t, resp.Diagnostics, "cannot be written to "+target+".json")
+}
+
+func TestDagGeneratorValidatePythonRejectsBackendTarget(t *testing.T) {
+	r := newTestProvider(t, nil).resource("mirage_dag_generator")
+
+	resp, _ := r.plan(map[string]tftypes.Value{
+		"template_content":          stringValue("a = 1"),
+		"target_path":               stringValue("gs://dags/orders.py"),
+		"dag_generator_backend_url": stringValue("http://backend.invalid"),
+		"validate_python":           boolValue(true),
+	})
+	requireError(t, resp.Diagnostics, "`validate_python` cannot be set for targets written by the backend")
+
+	target, _ := localTarget(t, "orders.py")
+	resp, _ = r.plan(map[string]tftypes.Value{
+		"template_content":          stringValue("a = 1"),
+		"target_path":               stringValue(target),
+		"dag_generator_backend_url": stringValue("http://backend.invalid"),
+		"validate_python":           boolValue(true),
+	})
+	requireNoErrors(t, "plan with a file:// target", resp.Diagnostics)
+}
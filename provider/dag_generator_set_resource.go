@@ -0,0 +1,445 @@
+package provider
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/client"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	_ resource.Resource               = &dagGeneratorSetResource{}
+	_ resource.ResourceWithConfigure  = &dagGeneratorSetResource{}
+	_ resource.ResourceWithModifyPlan = &dagGeneratorSetResource{}
+)
+
+func NewDagGeneratorSetResource() resource.Resource {
+	return &dagGeneratorSetResource{}
+}
+
+type dagGeneratorSetResource struct {
+	providerData *mirageProviderData
+}
+
+type dagGeneratorSetResourceModel struct {
+	DagGeneratorBackendURL   types.String `tfsdk:"dag_generator_backend_url"`
+	UseGCPServiceAccountAuth types.Bool   `tfsdk:"use_gcp_service_account_auth"`
+	Headers                  types.Map    `tfsdk:"headers"`
+	BatchSize                types.Int64  `tfsdk:"batch_size"`
+	Dags                     types.Map    `tfsdk:"dags"`
+	ProvenanceHeader         types.Bool   `tfsdk:"provenance_header"`
+	ID                       types.String `tfsdk:"id"`
+	Checksums                types.Map    `tfsdk:"checksums"`
+	Generations              types.Map    `tfsdk:"generations"`
+}
+
+// dagGeneratorSetItemModel describes one DAG of the set.
+type dagGeneratorSetItemModel struct {
+	TemplatePath    types.String `tfsdk:"template_path"`
+	TemplateContent types.String `tfsdk:"template_content"`
+	TargetPath      types.String `tfsdk:"target_path"`
+	ContextJSON     types.String `tfsdk:"context_json"`
+}
+
+// request returns the generate request for the item.
+func (m dagGeneratorSetItemModel) request() client.GenerateRequest {
+	return client.GenerateRequest{
+		TemplateGCSPath: m.TemplatePath.ValueString(),
+		TemplateContent: m.TemplateContent.ValueString(),
+		TargetGCSPath:   m.TargetPath.ValueString(),
+		ContextJSON:     m.ContextJSON.ValueString(),
+	}
+}
+
+// equal reports whether m and other generate the same file.
+func (m dagGeneratorSetItemModel) equal(other dagGeneratorSetItemModel) bool {
+	return m.TemplatePath.Equal(other.TemplatePath) &&
+		m.TemplateContent.Equal(other.TemplateContent) &&
+		m.TargetPath.Equal(other.TargetPath) &&
+		m.ContextJSON.Equal(other.ContextJSON)
+}
+
+func (r *dagGeneratorSetResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	data, ok := req.ProviderData.(*mirageProviderData)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Resource Configure Type",
+			fmt.Sprintf("Expected *mirageProviderData, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+		return
+	}
+	r.providerData = data
+}
+
+func (r *dagGeneratorSetResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_dag_generator_set"
+}
+
+func (r *dagGeneratorSetResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Manages many generated DAG files, sending them to the backend in batched requests. DAGs the provider writes itself are generated one by one.",
+		Attributes: map[string]schema.Attribute{
+			"dag_generator_backend_url": schema.StringAttribute{
+				Description: "The base URL of the backend service for this specific resource. When omitted, the provider renders the templates and writes gs:// targets itself. Not used for file://, s3:// and az:// targets, which the provider always writes itself.",
+				Optional:    true,
+			},
+			"use_gcp_service_account_auth": schema.BoolAttribute{
+				Description: "If true, authenticate requests to the backend using the machine's GCP service account.",
+				Optional:    true,
+			},
+			"headers": schema.MapAttribute{
+				Description: "Headers sent with every backend request for this resource, overriding the provider's `default_headers`.",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"batch_size": schema.Int64Attribute{
+				Description: fmt.Sprintf("The maximum number of DAGs sent per batch request. Defaults to %d.", client.DefaultBatchSize),
+				Optional:    true,
+			},
+			"dags": schema.MapNestedAttribute{
+				Description: "The DAGs to generate, keyed by a name of your choice.",
+				Required:    true,
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"template_path": schema.StringAttribute{
+							Description: "The full gs:// path to the source Jinja2 template. When the provider renders the template itself, this may also be a file://, s3:// or az:// path.",
+							Optional:    true,
+						},
+						"template_content": schema.StringAttribute{
+							Description: "The content of the local template file.",
+							Optional:    true,
+						},
+						"target_path": schema.StringAttribute{
+							Description: "The full path where the generated DAG will be saved: a gs://, s3://, az:// or file:// path.",
+							Required:    true,
+						},
+						"context_json": schema.StringAttribute{
+							Description: "A JSON string representing the context for the template.",
+							Optional:    true,
+						},
+					},
+				},
+			},
+			"provenance_header": schema.BoolAttribute{
+				Description: "If true, a comment block recording the template source and checksum, a hash of the context, the provider version and the Terraform workspace is prepended to every generated DAG, as for `mirage_dag_generator`. Every target must be of a type with line comments. The header itself never causes regeneration.",
+				Optional:    true,
+			},
+			"id": schema.StringAttribute{
+				Description: "A random identifier for the set.",
+				Computed:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"checksums": schema.MapAttribute{
+				Description: "The CRC32C checksum of each generated DAG, keyed by name.",
+				ElementType: types.StringType,
+				Computed:    true,
+			},
+			"generations": schema.MapAttribute{
+				Description: "The generation of each generated DAG in its storage, keyed by name.",
+				ElementType: types.StringType,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+// ModifyPlan checks that every target can carry a provenance header when one
+// is requested, and plans regeneration of DAGs that failed in an earlier
+// apply or went missing since, even if the configuration is unchanged.
+func (r *dagGeneratorSetResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var plan dagGeneratorSetResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() || plan.Dags.IsUnknown() {
+		return
+	}
+	if plan.ProvenanceHeader.ValueBool() {
+		dags := map[string]dagGeneratorSetItemModel{}
+		resp.Diagnostics.Append(plan.Dags.ElementsAs(ctx, &dags, false)...)
+		for name, dag := range dags {
+			validateProvenanceTarget(plan.ProvenanceHeader, dag.TargetPath, path.Root("dags").AtMapKey(name).AtName("target_path"), &resp.Diagnostics)
+		}
+	}
+	if resp.Diagnostics.HasError() || req.State.Raw.IsNull() {
+		return
+	}
+
+	var state dagGeneratorSetResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if hasUngeneratedFile(plan.Dags.Elements(), state.Checksums) {
+		planRegeneration(ctx, resp)
+	}
+}
+
+func (r *dagGeneratorSetResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, span := tracing.Start(ctx, "mirage_dag_generator_set.Create")
+	defer func() { tracing.End(span, resp.Diagnostics) }()
+
+	var plan dagGeneratorSetResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	dags, ok := r.dags(ctx, plan, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+	span.SetAttributes(attribute.Int("mirage.dag_count", len(dags)))
+
+	fs, ok := r.fileSet(ctx, plan, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+
+	checksums := map[string]string{}
+	generations := map[string]string{}
+	r.generate(ctx, fs, plan, dags, sortedKeys(dags), checksums, generations, &resp.Diagnostics)
+
+	// DAGs that failed are left out of the computed maps, so the set is saved
+	// with what was generated and the next apply retries the rest.
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	plan.ID = types.StringValue(hex.EncodeToString(id))
+	plan.Checksums, plan.Generations = fileOutputs(ctx, checksums, generations, &resp.Diagnostics)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *dagGeneratorSetResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, span := tracing.Start(ctx, "mirage_dag_generator_set.Read")
+	defer func() { tracing.End(span, resp.Diagnostics) }()
+
+	var state dagGeneratorSetResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	dags, ok := r.dags(ctx, state, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+
+	fs, ok := r.fileSet(ctx, state, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+
+	checksums, generations, ok := fs.readOutputs(ctx, r.targets(dags, state.Checksums), &resp.Diagnostics)
+	if !ok {
+		return
+	}
+
+	state.Checksums, state.Generations = fileOutputs(ctx, checksums, generations, &resp.Diagnostics)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+}
+
+func (r *dagGeneratorSetResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx, span := tracing.Start(ctx, "mirage_dag_generator_set.Update")
+	defer func() { tracing.End(span, resp.Diagnostics) }()
+
+	var plan, state dagGeneratorSetResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	dags, ok := r.dags(ctx, plan, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+	oldDags, ok := r.dags(ctx, state, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+	span.SetAttributes(attribute.Int("mirage.dag_count", len(dags)))
+
+	fs, ok := r.fileSet(ctx, plan, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+
+	oldChecksums, diags := stringMapValue(ctx, state.Checksums)
+	resp.Diagnostics.Append(diags...)
+	oldGenerations, diags := stringMapValue(ctx, state.Generations)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	headerChanged := plan.ProvenanceHeader.ValueBool() != state.ProvenanceHeader.ValueBool()
+	checksums := map[string]string{}
+	generations := map[string]string{}
+	var changed []string
+	for _, name := range sortedKeys(dags) {
+		oldDag, existed := oldDags[name]
+		_, generated := oldChecksums[name]
+		if existed && generated && !headerChanged && dags[name].equal(oldDag) {
+			checksums[name] = oldChecksums[name]
+			generations[name] = oldGenerations[name]
+			continue
+		}
+		changed = append(changed, name)
+	}
+
+	r.generate(ctx, fs, plan, dags, changed, checksums, generations, &resp.Diagnostics)
+	// DAGs that failed keep the previous provenance_header until they are
+	// regenerated with the new one.
+	if headerChanged {
+		for _, name := range changed {
+			if _, ok := checksums[name]; !ok {
+				plan.ProvenanceHeader = state.ProvenanceHeader
+			}
+		}
+	}
+
+	// Delete the outputs of removed DAGs and the old targets of moved ones.
+	// DAGs whose old file is still current, or could not be deleted, keep
+	// their previous entry in state.
+	if kept := fs.cleanUp(ctx, r.targets(oldDags, state.Checksums), r.targets(dags, plan.Dags), checksums, &resp.Diagnostics); len(kept) > 0 {
+		saved := make(map[string]dagGeneratorSetItemModel, len(dags))
+		for name, dag := range dags {
+			saved[name] = dag
+		}
+		for name := range kept {
+			saved[name] = oldDags[name]
+			checksums[name] = oldChecksums[name]
+			generations[name] = oldGenerations[name]
+		}
+		plan.Dags, diags = types.MapValueFrom(ctx, plan.Dags.ElementType(ctx), saved)
+		resp.Diagnostics.Append(diags...)
+	}
+
+	plan.ID = state.ID
+	plan.Checksums, plan.Generations = fileOutputs(ctx, checksums, generations, &resp.Diagnostics)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *dagGeneratorSetResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, span := tracing.Start(ctx, "mirage_dag_generator_set.Delete")
+	defer func() { tracing.End(span, resp.Diagnostics) }()
+
+	var state dagGeneratorSetResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	dags, ok := r.dags(ctx, state, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+
+	fs, ok := r.fileSet(ctx, state, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+
+	fs.deleteAll(ctx, r.targets(dags, state.Checksums), &resp.Diagnostics)
+}
+
+// dags decodes and validates the dags map of model.
+func (r *dagGeneratorSetResource) dags(ctx context.Context, model dagGeneratorSetResourceModel, diags *diag.Diagnostics) (map[string]dagGeneratorSetItemModel, bool) {
+	dags := map[string]dagGeneratorSetItemModel{}
+	if model.Dags.IsNull() {
+		return dags, true
+	}
+	diags.Append(model.Dags.ElementsAs(ctx, &dags, false)...)
+	if diags.HasError() {
+		return nil, false
+	}
+
+	targets := map[string]string{}
+	for _, name := range sortedKeys(dags) {
+		dag := dags[name]
+		gcsPath := dag.TemplatePath.ValueString()
+		content := dag.TemplateContent.ValueString()
+		if (gcsPath == "" && content == "") || (gcsPath != "" && content != "") {
+			diags.AddAttributeError(
+				path.Root("dags").AtMapKey(name),
+				"Invalid Configuration",
+				"Exactly one of `template_path` or `template_content` must be specified.",
+			)
+		}
+		target := dag.TargetPath.ValueString()
+		if other, ok := targets[target]; ok {
+			diags.AddAttributeError(
+				path.Root("dags").AtMapKey(name).AtName("target_path"),
+				"Duplicate Target Path",
+				fmt.Sprintf("DAGs %q and %q both write to %s.", other, name, target),
+			)
+		}
+		targets[target] = name
+	}
+	return dags, !diags.HasError()
+}
+
+// targets returns the target path of each DAG in dags whose name is a key of
+// names.
+func (r *dagGeneratorSetResource) targets(dags map[string]dagGeneratorSetItemModel, names types.Map) map[string]string {
+	targets := map[string]string{}
+	for name := range names.Elements() {
+		if dag, ok := dags[name]; ok {
+			targets[name] = dag.TargetPath.ValueString()
+		}
+	}
+	return targets
+}
+
+// generate sends the named DAGs in batches and records the checksum and
+// generation of each one that succeeded. Each failure is reported as its own
+// diagnostic on that DAG.
+func (r *dagGeneratorSetResource) generate(ctx context.Context, fs *fileSet, model dagGeneratorSetResourceModel, dags map[string]dagGeneratorSetItemModel, names []string, checksums, generations map[string]string, diags *diag.Diagnostics) {
+	failed := func(id string, err error) {
+		diags.AddAttributeError(
+			path.Root("dags").AtMapKey(id),
+			"Failed to generate DAG",
+			fmt.Sprintf("Could not generate %s: %v", dags[id].TargetPath.ValueString(), err),
+		)
+	}
+
+	items := make([]client.BatchGenerateItem, 0, len(names))
+	for _, name := range names {
+		genReq := dags[name].request()
+		if model.ProvenanceHeader.ValueBool() {
+			header, err := fs.provenanceHeader(ctx, genReq, diags)
+			if err != nil {
+				failed(name, err)
+				continue
+			}
+			genReq.ProvenanceHeader = header
+		}
+		items = append(items, client.BatchGenerateItem{ID: name, GenerateRequest: genReq})
+	}
+
+	fs.generateBatch(ctx, items, int(model.BatchSize.ValueInt64()), checksums, generations, diags, failed)
+}
+
+func (r *dagGeneratorSetResource) fileSet(ctx context.Context, model dagGeneratorSetResourceModel, diags *diag.Diagnostics) (*fileSet, bool) {
+	return newFileSet(ctx, r.providerData, model.DagGeneratorBackendURL, model.UseGCPServiceAccountAuth, model.Headers, diags)
+}
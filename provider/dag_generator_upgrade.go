@@ -0,0 +1,109 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// dagGeneratorResourceModelV0 is the state of mirage_dag_generator before
+// template_path, target_path and generation replaced their GCS-specific names.
+type dagGeneratorResourceModelV0 struct {
+	DagGeneratorBackendURL   types.String `tfsdk:"dag_generator_backend_url"`
+	TemplateGCSPath          types.String `tfsdk:"template_gcs_path"`
+	TemplateContent          types.String `tfsdk:"template_content"`
+	TargetGCSPath            types.String `tfsdk:"target_gcs_path"`
+	ContextJSON              types.String `tfsdk:"context_json"`
+	GeneratedFileChecksum    types.String `tfsdk:"generated_file_checksum"`
+	GCSGenerationNumber      types.String `tfsdk:"gcs_generation_number"`
+	TemplateChecksum         types.String `tfsdk:"template_checksum"`
+	ID                       types.String `tfsdk:"id"`
+	UseGCPServiceAccountAuth types.Bool   `tfsdk:"use_gcp_service_account_auth"`
+	Headers                  types.Map    `tfsdk:"headers"`
+	TemplateFiles            types.Map    `tfsdk:"template_files"`
+	TemplateDir              types.String `tfsdk:"template_dir"`
+	TemplateBundleChecksum   types.String `tfsdk:"template_bundle_checksum"`
+}
+
+// UpgradeState migrates version 0 state by copying the GCS-specific
+// attributes to their new names. The old names are kept as aliases, so
+// configurations using either name plan no changes after the upgrade.
+func (r *dagGeneratorResource) UpgradeState(_ context.Context) map[int64]resource.StateUpgrader {
+	return map[int64]resource.StateUpgrader{
+		0: {
+			PriorSchema: &schema.Schema{
+				Attributes: map[string]schema.Attribute{
+					"dag_generator_backend_url":    schema.StringAttribute{Optional: true},
+					"id":                           schema.StringAttribute{Computed: true},
+					"template_gcs_path":            schema.StringAttribute{Optional: true},
+					"template_content":             schema.StringAttribute{Optional: true},
+					"template_files":               schema.MapAttribute{ElementType: types.StringType, Optional: true},
+					"template_dir":                 schema.StringAttribute{Optional: true},
+					"template_bundle_checksum":     schema.StringAttribute{Computed: true},
+					"target_gcs_path":              schema.StringAttribute{Required: true},
+					"context_json":                 schema.StringAttribute{Optional: true},
+					"generated_file_checksum":      schema.StringAttribute{Computed: true},
+					"gcs_generation_number":        schema.StringAttribute{Computed: true},
+					"template_checksum":            schema.StringAttribute{Optional: true, Computed: true},
+					"use_gcp_service_account_auth": schema.BoolAttribute{Optional: true},
+					"headers":                      schema.MapAttribute{ElementType: types.StringType, Optional: true},
+				},
+			},
+			StateUpgrader: func(ctx context.Context, req resource.UpgradeStateRequest, resp *resource.UpgradeStateResponse) {
+				var prior dagGeneratorResourceModelV0
+				resp.Diagnostics.Append(req.State.Get(ctx, &prior)...)
+				if resp.Diagnostics.HasError() {
+					return
+				}
+
+				resp.Diagnostics.Append(resp.State.Set(ctx, dagGeneratorResourceModel{
+					DagGeneratorBackendURL:   prior.DagGeneratorBackendURL,
+					TemplatePath:             prior.TemplateGCSPath,
+					TemplateGCSPath:          prior.TemplateGCSPath,
+					TemplateContent:          prior.TemplateContent,
+					TargetPath:               prior.TargetGCSPath,
+					TargetGCSPath:            prior.TargetGCSPath,
+					ContextJSON:              prior.ContextJSON,
+					ContextSchema:            types.StringNull(),
+					GeneratedFileChecksum:    prior.GeneratedFileChecksum,
+					Generation:               prior.GCSGenerationNumber,
+					GCSGenerationNumber:      prior.GCSGenerationNumber,
+					TemplateChecksum:         prior.TemplateChecksum,
+					ID:                       prior.ID,
+					UseGCPServiceAccountAuth: prior.UseGCPServiceAccountAuth,
+					Headers:                  prior.Headers,
+					TemplateFiles:            prior.TemplateFiles,
+					TemplateDir:              prior.TemplateDir,
+					TemplateBundleChecksum:   prior.TemplateBundleChecksum,
+					ContentType:              types.StringNull(),
+					CacheControl:             types.StringNull(),
+					Metadata:                 types.MapNull(types.StringType),
+					ProvenanceHeader:         types.BoolNull(),
+					ValidatePython:           types.BoolNull(),
+					StrictUndefined:          types.BoolNull(),
+					AirflowLint:              types.ObjectNull(airflowLintAttrTypes),
+				})...)
+			},
+		},
+	}
+}
+
+// resolvePathAlias returns the configured value of an attribute that can also
+// be set through a deprecated alias, and reports an error if both are set.
+func resolvePathAlias(diags *diag.Diagnostics, value, deprecated types.String, name, deprecatedName string) types.String {
+	if value.IsNull() {
+		return deprecated
+	}
+	if !deprecated.IsNull() {
+		diags.AddAttributeError(
+			path.Root(deprecatedName),
+			"Conflicting Attributes",
+			"`"+deprecatedName+"` is a deprecated alias of `"+name+"`. Set only `"+name+"`.",
+		)
+	}
+	return value
+}
@@ -0,0 +1,206 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/client"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var _ datasource.DataSourceWithConfigure = &generatedFileDataSource{}
+
+func NewGeneratedFileDataSource() datasource.DataSource {
+	return &generatedFileDataSource{}
+}
+
+type generatedFileDataSource struct {
+	providerData *mirageProviderData
+}
+
+type generatedFileDataSourceModel struct {
+	DagGeneratorBackendURL   types.String `tfsdk:"dag_generator_backend_url"`
+	UseGCPServiceAccountAuth types.Bool   `tfsdk:"use_gcp_service_account_auth"`
+	Headers                  types.Map    `tfsdk:"headers"`
+	TargetPath               types.String `tfsdk:"target_path"`
+	IncludeContent           types.Bool   `tfsdk:"include_content"`
+	ID                       types.String `tfsdk:"id"`
+	Exists                   types.Bool   `tfsdk:"exists"`
+	Checksum                 types.String `tfsdk:"checksum"`
+	Generation               types.String `tfsdk:"generation"`
+	Size                     types.Int64  `tfsdk:"size"`
+	ContentType              types.String `tfsdk:"content_type"`
+	CacheControl             types.String `tfsdk:"cache_control"`
+	Metadata                 types.Map    `tfsdk:"metadata"`
+	LastModified             types.String `tfsdk:"last_modified"`
+	Content                  types.String `tfsdk:"content"`
+}
+
+func (d *generatedFileDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	data, ok := req.ProviderData.(*mirageProviderData)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Data Source Configure Type",
+			fmt.Sprintf("Expected *mirageProviderData, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+		return
+	}
+	d.providerData = data
+}
+
+func (d *generatedFileDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_generated_file"
+}
+
+func (d *generatedFileDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Reads the metadata, and optionally the content, of a generated file without managing it.",
+		Attributes: map[string]schema.Attribute{
+			"dag_generator_backend_url": schema.StringAttribute{
+				Description: "The base URL of the backend service. When omitted, the provider reads gs:// files itself. Not used for file://, s3:// and az:// files, which the provider always reads itself.",
+				Optional:    true,
+			},
+			"use_gcp_service_account_auth": schema.BoolAttribute{
+				Description: "If true, authenticate requests to the backend using the machine's GCP service account.",
+				Optional:    true,
+			},
+			"headers": schema.MapAttribute{
+				Description: "Headers sent with every backend request, overriding the provider's `default_headers`.",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"target_path": schema.StringAttribute{
+				Description: "The full path of the generated file: a gs://, s3://, az:// or file:// path.",
+				Required:    true,
+			},
+			"include_content": schema.BoolAttribute{
+				Description: "If true, also return the file's content.",
+				Optional:    true,
+			},
+			"id": schema.StringAttribute{
+				Description: "The path of the file.",
+				Computed:    true,
+			},
+			"exists": schema.BoolAttribute{
+				Description: "Whether the file exists.",
+				Computed:    true,
+			},
+			"checksum": schema.StringAttribute{
+				Description: "The CRC32C checksum of the file.",
+				Computed:    true,
+			},
+			"generation": schema.StringAttribute{
+				Description: "The version of the file in its storage: the GCS generation number, the S3 or Azure version ID or ETag, or a pseudo-generation derived from the modification time of a local file.",
+				Computed:    true,
+			},
+			"size": schema.Int64Attribute{
+				Description: "The size of the file in bytes.",
+				Computed:    true,
+			},
+			"content_type": schema.StringAttribute{
+				Description: "The content type of the file.",
+				Computed:    true,
+			},
+			"cache_control": schema.StringAttribute{
+				Description: "The Cache-Control metadata of the file.",
+				Computed:    true,
+			},
+			"metadata": schema.MapAttribute{
+				Description: "The custom metadata (labels) of the file.",
+				ElementType: types.StringType,
+				Computed:    true,
+			},
+			"last_modified": schema.StringAttribute{
+				Description: "When the file was last modified, as an RFC 3339 timestamp.",
+				Computed:    true,
+			},
+			"content": schema.StringAttribute{
+				Description: "The content of the file. Only set when `include_content` is true.",
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func (d *generatedFileDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	ctx, span := tracing.Start(ctx, "mirage_generated_file.Read")
+	defer func() { tracing.End(span, resp.Diagnostics) }()
+
+	var config generatedFileDataSourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	span.SetAttributes(attribute.String("mirage.target_path", config.TargetPath.ValueString()))
+
+	headers, diags := stringMapValue(ctx, config.Headers)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	dagGenService, err := d.providerData.generatorFor(config.TargetPath.ValueString(), config.DagGeneratorBackendURL.ValueString(), config.UseGCPServiceAccountAuth.ValueBool(), headers)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to create backend client", err.Error())
+		return
+	}
+
+	config.ID = config.TargetPath
+
+	status, err := dagGenService.GetStatusWithOptions(ctx, config.TargetPath.ValueString(), client.StatusOptions{
+		IncludeContent: config.IncludeContent.ValueBool(),
+	})
+	if err != nil {
+		if errors.Is(err, client.ErrNotFound) {
+			// A missing file is reported through `exists` so callers can branch on it.
+			config.Exists = basetypes.NewBoolValue(false)
+			config.Checksum = basetypes.NewStringValue("")
+			config.Generation = basetypes.NewStringValue("")
+			config.Size = basetypes.NewInt64Value(0)
+			config.ContentType = basetypes.NewStringValue("")
+			config.CacheControl = basetypes.NewStringValue("")
+			config.Metadata = types.MapValueMust(types.StringType, map[string]attr.Value{})
+			config.LastModified = basetypes.NewStringValue("")
+			config.Content = basetypes.NewStringNull()
+			resp.Diagnostics.Append(resp.State.Set(ctx, &config)...)
+			return
+		}
+		addBackendError(&resp.Diagnostics, "Failed to read generated file", err)
+		return
+	}
+
+	config.Exists = basetypes.NewBoolValue(true)
+	config.Checksum = basetypes.NewStringValue(status.Checksum)
+	config.Generation = basetypes.NewStringValue(status.Generation)
+	config.Size = basetypes.NewInt64Value(status.Size)
+	config.ContentType = basetypes.NewStringValue(status.ContentType)
+	config.CacheControl = basetypes.NewStringValue(status.CacheControl)
+	config.LastModified = basetypes.NewStringValue(status.LastModified)
+	values := status.Metadata
+	if values == nil {
+		values = map[string]string{}
+	}
+	metadata, diags := types.MapValueFrom(ctx, types.StringType, values)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	config.Metadata = metadata
+	if config.IncludeContent.ValueBool() {
+		config.Content = basetypes.NewStringValue(status.Content)
+	} else {
+		config.Content = basetypes.NewStringNull()
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &config)...)
+}
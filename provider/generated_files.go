@@ -0,0 +1,269 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/client"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/storage"
+)
+
+// fileSet generates, reads and deletes the files of the resources that manage
+// several of them: mirage_dag_bundle, mirage_dag_generator_set and
+// mirage_dag_factory. Each file is known by a name, its key in the resource's
+// checksums and generations maps.
+//
+// As for mirage_dag_generator, usesBackend decides for each target whether
+// the backend generates it or the provider renders and writes it itself.
+type fileSet struct {
+	data       *mirageProviderData
+	backendURL string
+	// service is nil when the resource has no backend.
+	service *client.DagGeneratorService
+	direct  *directGenerator
+	// provenances caches the provenance of each template path, so a
+	// template shared by many files is looked up once.
+	provenances map[string]provenance
+}
+
+// newFileSet returns the fileSet for a resource's backend settings.
+func newFileSet(ctx context.Context, data *mirageProviderData, backendURL types.String, useServiceAccountAuth types.Bool, headers types.Map, diags *diag.Diagnostics) (*fileSet, bool) {
+	headerMap, d := stringMapValue(ctx, headers)
+	diags.Append(d...)
+	if diags.HasError() {
+		return nil, false
+	}
+
+	f := &fileSet{
+		data:        data,
+		backendURL:  backendURL.ValueString(),
+		direct:      &directGenerator{store: data.store},
+		provenances: map[string]provenance{},
+	}
+	if f.backendURL == "" {
+		return f, true
+	}
+	dagGenService, err := data.newDagGeneratorService(f.backendURL, useServiceAccountAuth.ValueBool(), headerMap)
+	if err != nil {
+		diags.AddError("Failed to create backend client", err.Error())
+		return nil, false
+	}
+	f.service = dagGenService
+	return f, true
+}
+
+// generator returns the generator that manages target.
+func (f *fileSet) generator(target string) (generator, error) {
+	if !usesBackend(target, f.backendURL) {
+		return f.direct, nil
+	}
+	if f.service == nil {
+		return nil, fmt.Errorf("dag_generator_backend_url is required for target %s", target)
+	}
+	return f.service, nil
+}
+
+// requestGenerator returns the generator for genReq's target, checking that
+// it can read genReq's template.
+func (f *fileSet) requestGenerator(genReq client.GenerateRequest) (generator, error) {
+	gen, err := f.generator(genReq.TargetGCSPath)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := gen.(*client.DagGeneratorService); ok && genReq.TemplateGCSPath != "" && !storage.IsGCS(genReq.TemplateGCSPath) {
+		return nil, fmt.Errorf("the backend can only read gs:// templates, not %q. Use a file://, s3:// or az:// target or omit dag_generator_backend_url to render in the provider", genReq.TemplateGCSPath)
+	}
+	return gen, nil
+}
+
+// provenanceHeader returns the provenance header of the file genReq
+// generates, for resources with provenance_header set.
+func (f *fileSet) provenanceHeader(ctx context.Context, genReq client.GenerateRequest, diags *diag.Diagnostics) (string, error) {
+	gen, err := f.requestGenerator(genReq)
+	if err != nil {
+		return "", err
+	}
+	p, ok := f.provenances[genReq.TemplateGCSPath]
+	if !ok || genReq.TemplateGCSPath == "" {
+		p = f.data.provenance(ctx, gen, genReq.TemplateGCSPath, genReq.TemplateContent, genReq.TemplateFiles, diags)
+		f.provenances[genReq.TemplateGCSPath] = p
+	}
+	return p.header(genReq.TargetGCSPath, genReq.ContextJSON)
+}
+
+// generate generates a single file.
+func (f *fileSet) generate(ctx context.Context, genReq client.GenerateRequest) (*client.GenerateResponse, error) {
+	gen, err := f.requestGenerator(genReq)
+	if err != nil {
+		return nil, err
+	}
+	return gen.Generate(ctx, genReq)
+}
+
+// generateBatch generates items and records the checksum and generation of
+// each one that succeeded, keyed by item ID. Items for the backend are sent in
+// batches of batchSize, the others generated one by one. failed reports each
+// item that did not succeed, unless the circuit breaker rejected it, which is
+// reported once for the whole batch.
+func (f *fileSet) generateBatch(ctx context.Context, items []client.BatchGenerateItem, batchSize int, checksums, generations map[string]string, diags *diag.Diagnostics, failed func(id string, err error)) {
+	var batched []client.BatchGenerateItem
+	for _, item := range items {
+		gen, err := f.requestGenerator(item.GenerateRequest)
+		if err != nil {
+			failed(item.ID, err)
+			continue
+		}
+		if _, ok := gen.(*client.DagGeneratorService); ok {
+			batched = append(batched, item)
+			continue
+		}
+		generationResult, err := gen.Generate(ctx, item.GenerateRequest)
+		if err != nil {
+			failed(item.ID, err)
+			continue
+		}
+		checksums[item.ID] = generationResult.Checksum
+		generations[item.ID] = generationResult.Generation
+	}
+	if len(batched) == 0 {
+		return
+	}
+
+	for _, result := range f.service.GenerateBatch(ctx, batched, batchSize) {
+		if result.Err != nil {
+			if errors.Is(result.Err, client.ErrCircuitOpen) {
+				addBackendError(diags, "", result.Err)
+				continue
+			}
+			failed(result.ID, result.Err)
+			continue
+		}
+		checksums[result.ID] = result.Checksum
+		generations[result.ID] = result.Generation
+	}
+}
+
+// readOutputs returns the current checksum and generation of the file at each
+// of targets, keyed by name. Files that no longer exist are left out with a
+// warning, which plans their regeneration.
+func (f *fileSet) readOutputs(ctx context.Context, targets map[string]string, diags *diag.Diagnostics) (map[string]string, map[string]string, bool) {
+	checksums := map[string]string{}
+	generations := map[string]string{}
+	for _, name := range sortedKeys(targets) {
+		target := targets[name]
+		gen, err := f.generator(target)
+		if err != nil {
+			diags.AddError("Failed to read resource status", err.Error())
+			return nil, nil, false
+		}
+		status, err := gen.GetStatus(ctx, target)
+		if err != nil {
+			if errors.Is(err, client.ErrNotFound) {
+				diags.AddWarning("File not found", fmt.Sprintf("The generated file %s no longer exists and will be regenerated.", target))
+				continue
+			}
+			addBackendError(diags, "Failed to read resource status", err)
+			return nil, nil, false
+		}
+		checksums[name] = status.Checksum
+		generations[name] = status.Generation
+	}
+	return checksums, generations, true
+}
+
+// delete deletes the file at target.
+func (f *fileSet) delete(ctx context.Context, target string) error {
+	gen, err := f.generator(target)
+	if err != nil {
+		return err
+	}
+	return gen.Delete(ctx, target)
+}
+
+// deleteAll deletes the file at each of targets.
+func (f *fileSet) deleteAll(ctx context.Context, targets map[string]string, diags *diag.Diagnostics) {
+	for _, name := range sortedKeys(targets) {
+		if err := f.delete(ctx, targets[name]); err != nil {
+			addBackendError(diags, fmt.Sprintf("Failed to delete %s", targets[name]), err)
+		}
+	}
+}
+
+// cleanUp deletes the files left behind by an update: those of entries that
+// were removed, and the old files of entries whose target changed. oldTargets
+// holds the target of each entry generated before the update, targets the
+// target of each planned entry and checksums the entries generated so far.
+//
+// It returns the names whose previous entry must stay in state, with its
+// target, checksum and generation: entries that failed to regenerate, whose
+// old file is still the current one, and entries whose old file could not be
+// deleted. Each comes with an error, so the next apply retries it rather than
+// losing track of the old file.
+func (f *fileSet) cleanUp(ctx context.Context, oldTargets, targets, checksums map[string]string, diags *diag.Diagnostics) map[string]bool {
+	owners := map[string]string{}
+	for name, target := range targets {
+		owners[target] = name
+	}
+
+	kept := map[string]bool{}
+	for _, name := range sortedKeys(oldTargets) {
+		oldTarget := oldTargets[name]
+		owner, inUse := owners[oldTarget]
+
+		if _, planned := targets[name]; planned {
+			if _, generated := checksums[name]; !generated {
+				// Unless another entry took the old target over, the old
+				// file is still this entry's.
+				if !inUse || owner == name {
+					kept[name] = true
+				}
+				continue
+			}
+		}
+		if inUse {
+			continue
+		}
+
+		if err := f.delete(ctx, oldTarget); err != nil {
+			addBackendError(diags, fmt.Sprintf("Failed to delete %s", oldTarget), fmt.Errorf("%s is no longer generated but could not be deleted. It stays in state and the next apply retries: %w", oldTarget, err))
+			kept[name] = true
+		}
+	}
+	return kept
+}
+
+// fileOutputs converts checksums and generations to the resource's
+// checksums and generations attributes.
+func fileOutputs(ctx context.Context, checksums, generations map[string]string, diags *diag.Diagnostics) (types.Map, types.Map) {
+	checksumsValue, d := types.MapValueFrom(ctx, types.StringType, checksums)
+	diags.Append(d...)
+	generationsValue, d := types.MapValueFrom(ctx, types.StringType, generations)
+	diags.Append(d...)
+	return checksumsValue, generationsValue
+}
+
+// hasUngeneratedFile reports whether any of names has no checksum in stored,
+// because its file failed to generate in an earlier apply or went missing
+// since.
+func hasUngeneratedFile(names map[string]attr.Value, stored types.Map) bool {
+	checksums := stored.Elements()
+	for name := range names {
+		if _, ok := checksums[name]; !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// planRegeneration marks the checksums and generations as unknown in the
+// plan, as files will be regenerated.
+func planRegeneration(ctx context.Context, resp *resource.ModifyPlanResponse) {
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("checksums"), types.MapUnknown(types.StringType))...)
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("generations"), types.MapUnknown(types.StringType))...)
+}
@@ -0,0 +1,432 @@
+package provider
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+func TestDagBundleLocal(t *testing.T) {
+	dir := t.TempDir()
+	dagPath := "file://" + filepath.ToSlash(filepath.Join(dir, "orders.py"))
+	sqlPath := "file://" + filepath.ToSlash(filepath.Join(dir, "orders.sql"))
+	r := newTestProvider(t, nil).resource("mirage_dag_bundle")
+
+	config := map[string]tftypes.Value{
+		"context_json": stringValue(`{"table": "orders"}`),
+		"files": r.objectMapValue("files", map[string]map[string]tftypes.Value{
+			dagPath: {"template_content": stringValue("dag_id = '{{ table }}'")},
+			sqlPath: {"template_content": stringValue("SELECT * FROM {{ table }}")},
+		}),
+	}
+	r.mustApply(config)
+
+	for file, want := range map[string]string{
+		"orders.py":  "dag_id = 'orders'",
+		"orders.sql": "SELECT * FROM orders",
+	} {
+		content, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != want {
+			t.Errorf("%s holds %q, want %q", file, content, want)
+		}
+	}
+	if got := len(r.stringMapAttr("checksums")); got != 2 {
+		t.Errorf("%d checksums, want 2", got)
+	}
+	requireNoErrors(t, "refresh", r.refresh())
+	if !r.planIsEmpty(config) {
+		t.Error("plan after apply is not empty")
+	}
+
+	config["files"] = r.objectMapValue("files", map[string]map[string]tftypes.Value{
+		dagPath: {"template_content": stringValue("dag_id = '{{ table }}'")},
+	})
+	r.mustApply(config)
+	if _, err := os.Stat(filepath.Join(dir, "orders.sql")); !os.IsNotExist(err) {
+		t.Errorf("orders.sql still exists after it was removed from the bundle: %v", err)
+	}
+	if _, ok := r.stringMapAttr("checksums")[sqlPath]; ok {
+		t.Error("the removed file still has a checksum")
+	}
+
+	r.mustApply(nil)
+	if _, err := os.Stat(filepath.Join(dir, "orders.py")); !os.IsNotExist(err) {
+		t.Errorf("orders.py still exists after destroy: %v", err)
+	}
+}
+
+func TestDagBundlePartialUpdateKeepsOldFiles(t *testing.T) {
+	gcs := directGCS(t)
+	const dagPath = "gs://dags/orders.py"
+	const sqlPath = "gs://dags/orders.sql"
+	r := newTestProvider(t, nil).resource("mirage_dag_bundle")
+
+	files := r.objectMapValue("files", map[string]map[string]tftypes.Value{
+		dagPath: {"template_content": stringValue("dag_id = '{{ table }}'")},
+		sqlPath: {"template_content": stringValue("SELECT * FROM {{ table }}")},
+	})
+	config := map[string]tftypes.Value{
+		"context_json": stringValue(`{"table": "orders"}`),
+		"files":        files,
+	}
+	r.mustApply(config)
+	oldChecksums := r.stringMapAttr("checksums")
+
+	gcs.FailWrites(sqlPath, true)
+	config["context_json"] = stringValue(`{"table": "payments"}`)
+	requireError(t, r.apply(config), "Failed to generate "+sqlPath)
+
+	if got := r.stringMapAttr("checksums")[sqlPath]; got != oldChecksums[sqlPath] {
+		t.Errorf("checksum of the failed file = %q, want its previous %q", got, oldChecksums[sqlPath])
+	}
+	if got := r.stringAttr("context_json"); got != `{"table": "orders"}` {
+		t.Errorf("context_json = %q after a partial update, want the previous context", got)
+	}
+	if r.planIsEmpty(config) {
+		t.Fatal("the failed file is not retried")
+	}
+
+	gcs.FailWrites(sqlPath, false)
+	r.mustApply(config)
+	if got, _ := gcs.Object(sqlPath); string(got) != "SELECT * FROM payments" {
+		t.Errorf("%s holds %q after the retry", sqlPath, got)
+	}
+	if !r.planIsEmpty(config) {
+		t.Error("plan after the retry is not empty")
+	}
+}
+
+func TestDagGeneratorSetPartialFailure(t *testing.T) {
+	gcs := directGCS(t)
+	r := newTestProvider(t, nil).resource("mirage_dag_generator_set")
+
+	dags := func(context string) tftypes.Value {
+		return r.objectMapValue("dags", map[string]map[string]tftypes.Value{
+			"orders": {
+				"template_content": stringValue("dag_id = 'orders_{{ v }}'"),
+				"target_path":      stringValue("gs://dags/orders.py"),
+				"context_json":     stringValue(context),
+			},
+			"payments": {
+				"template_content": stringValue("dag_id = 'payments_{{ v }}'"),
+				"target_path":      stringValue("gs://dags/payments.py"),
+				"context_json":     stringValue(context),
+			},
+		})
+	}
+	config := map[string]tftypes.Value{"dags": dags(`{"v": 1}`)}
+	r.mustApply(config)
+	oldChecksums := r.stringMapAttr("checksums")
+
+	gcs.FailWrites("gs://dags/payments.py", true)
+	config["dags"] = dags(`{"v": 2}`)
+	requireError(t, r.apply(config), "Failed to generate DAG")
+
+	checksums := r.stringMapAttr("checksums")
+	if checksums["orders"] == oldChecksums["orders"] {
+		t.Error("the DAG that succeeded kept its previous checksum")
+	}
+	if checksums["payments"] != oldChecksums["payments"] {
+		t.Errorf("checksum of the failed DAG = %q, want its previous %q", checksums["payments"], oldChecksums["payments"])
+	}
+	if r.planIsEmpty(config) {
+		t.Fatal("the failed DAG is not retried")
+	}
+
+	gcs.FailWrites("gs://dags/payments.py", false)
+	r.mustApply(config)
+	if got, _ := gcs.Object("gs://dags/payments.py"); string(got) != "dag_id = 'payments_2'" {
+		t.Errorf("payments.py holds %q after the retry", got)
+	}
+}
+
+func TestDagGeneratorSetMoveKeepsOldFileUntilReplaced(t *testing.T) {
+	gcs := directGCS(t)
+	r := newTestProvider(t, nil).resource("mirage_dag_generator_set")
+
+	dags := func(target string) tftypes.Value {
+		return r.objectMapValue("dags", map[string]map[string]tftypes.Value{
+			"orders": {
+				"template_content": stringValue("dag_id = 'orders'"),
+				"target_path":      stringValue(target),
+			},
+		})
+	}
+	config := map[string]tftypes.Value{"dags": dags("gs://dags/orders.py")}
+	r.mustApply(config)
+
+	gcs.FailWrites("gs://dags/v2/orders.py", true)
+	config["dags"] = dags("gs://dags/v2/orders.py")
+	requireError(t, r.apply(config), "Failed to generate DAG")
+	if _, ok := gcs.Object("gs://dags/orders.py"); !ok {
+		t.Fatal("the old file was deleted although its replacement failed")
+	}
+
+	gcs.FailWrites("gs://dags/v2/orders.py", false)
+	r.mustApply(config)
+	if _, ok := gcs.Object("gs://dags/orders.py"); ok {
+		t.Error("the old file is left behind after the move")
+	}
+	if _, ok := gcs.Object("gs://dags/v2/orders.py"); !ok {
+		t.Error("the moved file was not written")
+	}
+}
+
+func TestDagFactoryLocal(t *testing.T) {
+	specDir := t.TempDir()
+	outDir := t.TempDir()
+	for name, spec := range map[string]string{
+		"orders.yaml":   "dag_id: orders\n",
+		"payments.yaml": "dag_id: payments\n",
+	} {
+		if err := os.WriteFile(filepath.Join(specDir, name), []byte(spec), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := newTestProvider(t, nil).resource("mirage_dag_factory")
+
+	config := map[string]tftypes.Value{
+		"template_content":    stringValue("dag_id = '{{ dag_id }}'"),
+		"spec_glob":           stringValue(filepath.Join(specDir, "*.yaml")),
+		"target_path_pattern": stringValue("file://" + filepath.ToSlash(outDir) + "/{{ dag_id }}.py"),
+	}
+	r.mustApply(config)
+	for _, name := range []string{"orders", "payments"} {
+		content, err := os.ReadFile(filepath.Join(outDir, name+".py"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "dag_id = '" + name + "'"; string(content) != want {
+			t.Errorf("%s.py holds %q, want %q", name, content, want)
+		}
+	}
+	if !r.planIsEmpty(config) {
+		t.Error("plan after apply is not empty")
+	}
+
+	if err := os.Remove(filepath.Join(specDir, "payments.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if r.planIsEmpty(config) {
+		t.Fatal("a removed spec does not show up in the plan")
+	}
+	r.mustApply(config)
+	if _, err := os.Stat(filepath.Join(outDir, "payments.py")); !os.IsNotExist(err) {
+		t.Errorf("payments.py still exists after its spec was removed: %v", err)
+	}
+
+	if err := os.Remove(filepath.Join(specDir, "orders.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	requireError(t, r.apply(config), "No Specs Found")
+	if _, err := os.Stat(filepath.Join(outDir, "orders.py")); err != nil {
+		t.Errorf("orders.py was removed by a glob that matches nothing: %v", err)
+	}
+}
+
+func TestDagFactoryRejectsPathInSpecValue(t *testing.T) {
+	outDir := t.TempDir()
+	r := newTestProvider(t, nil).resource("mirage_dag_factory")
+
+	resp, _ := r.plan(map[string]tftypes.Value{
+		"template_content":    stringValue("dag_id = '{{ dag_id }}'"),
+		"specs":               stringMapValueOf(map[string]string{"orders": "dag_id: ../orders\n"}),
+		"target_path_pattern": stringValue("file://" + filepath.ToSlash(outDir) + "/{{ dag_id }}.py"),
+	})
+	requireError(t, resp.Diagnostics, `Cannot build the target path for DAG "orders"`)
+}
+
+func TestDagGeneratorSetRequiresBackendForUnknownScheme(t *testing.T) {
+	r := newTestProvider(t, nil).resource("mirage_dag_generator_set")
+
+	requireError(t, r.apply(map[string]tftypes.Value{
+		"dags": r.objectMapValue("dags", map[string]map[string]tftypes.Value{
+			"orders": {
+				"template_content": stringValue("a = 1"),
+				"target_path":      stringValue("https://example.com/orders.py"),
+			},
+		}),
+	}), "dag_generator_backend_url is required")
+}
+
+func TestGeneratedFileDataSourceLocal(t *testing.T) {
+	target, file := localTarget(t, "orders.py")
+	if err := os.WriteFile(file, []byte("a = 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p := newTestProvider(t, nil)
+
+	state, diags := p.readDataSource("mirage_generated_file", map[string]tftypes.Value{
+		"target_path":     stringValue(target),
+		"include_content": boolValue(true),
+	})
+	requireNoErrors(t, "read mirage_generated_file", diags)
+	if !objectAttr(t, state, "exists").Equal(boolValue(true)) {
+		t.Error("exists is not true")
+	}
+	if !objectAttr(t, state, "content").Equal(stringValue("a = 1\n")) {
+		t.Errorf("content = %v", objectAttr(t, state, "content"))
+	}
+
+	state, diags = p.readDataSource("mirage_generated_file", map[string]tftypes.Value{
+		"target_path": stringValue(target + ".missing"),
+	})
+	requireNoErrors(t, "read a missing mirage_generated_file", diags)
+	if !objectAttr(t, state, "exists").Equal(boolValue(false)) {
+		t.Error("exists is not false for a missing file")
+	}
+}
+
+func TestTemplateDataSourceDirectGCS(t *testing.T) {
+	gcs := directGCS(t)
+	const template = "gs://templates/dag.py.j2"
+	gcs.Put(template, []byte("dag_id = '{{ dag_id }}'"))
+	p := newTestProvider(t, nil)
+
+	state, diags := p.readDataSource("mirage_template", map[string]tftypes.Value{
+		"template_path": stringValue(template),
+	})
+	requireNoErrors(t, "read mirage_template", diags)
+	want := tftypes.NewValue(tftypes.List{ElementType: tftypes.String}, []tftypes.Value{stringValue("dag_id")})
+	if got := objectAttr(t, state, "required_variables"); !got.Equal(want) {
+		t.Errorf("required_variables = %v, want [dag_id]", got)
+	}
+
+	_, diags = p.readDataSource("mirage_template", map[string]tftypes.Value{
+		"template_path": stringValue("gs://templates/missing.j2"),
+	})
+	requireError(t, diags, "does not exist")
+}
+
+func TestTemplateDataSourceBundle(t *testing.T) {
+	template, file := localTarget(t, "dag.py.j2")
+	if err := os.WriteFile(file, []byte("dag_id = '{{ dag_id }}'\n{% include 'sensors.j2' %}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p := newTestProvider(t, nil)
+
+	state, diags := p.readDataSource("mirage_template", map[string]tftypes.Value{
+		"template_path":  stringValue(template),
+		"template_files": stringMapValueOf(map[string]string{"sensors.j2": "bucket = '{{ sensor.bucket }}'"}),
+	})
+	requireNoErrors(t, "read mirage_template", diags)
+	want := tftypes.NewValue(tftypes.List{ElementType: tftypes.String}, []tftypes.Value{stringValue("dag_id"), stringValue("sensor.bucket")})
+	if got := objectAttr(t, state, "required_variables"); !got.Equal(want) {
+		t.Errorf("required_variables = %v, want [dag_id sensor.bucket]", got)
+	}
+
+	state, diags = p.readDataSource("mirage_template", map[string]tftypes.Value{
+		"template_path": stringValue(template),
+	})
+	requireNoErrors(t, "read mirage_template without its bundle", diags)
+	if got := objectAttr(t, state, "required_variables"); !got.IsNull() {
+		t.Errorf("required_variables = %v without the included file, want null", got)
+	}
+	if len(diags) != 1 || !strings.Contains(diags[0].Detail, "missing from template_files") {
+		t.Errorf("diagnostics without the included file:\n%s", formatDiagnostics(diags))
+	}
+}
+
+func TestDagBundleProvenanceHeader(t *testing.T) {
+	dir := t.TempDir()
+	dagPath := "file://" + filepath.ToSlash(filepath.Join(dir, "orders.py"))
+	sqlPath := "file://" + filepath.ToSlash(filepath.Join(dir, "orders.sql"))
+	r := newTestProvider(t, nil).resource("mirage_dag_bundle")
+
+	config := map[string]tftypes.Value{
+		"context_json":      stringValue(`{"table": "orders"}`),
+		"provenance_header": boolValue(true),
+		"files": r.objectMapValue("files", map[string]map[string]tftypes.Value{
+			dagPath: {"template_content": stringValue("dag_id = '{{ table }}'")},
+			sqlPath: {"template_content": stringValue("SELECT * FROM {{ table }}")},
+		}),
+	}
+	r.mustApply(config)
+
+	for file, comment := range map[string]string{"orders.py": "# ", "orders.sql": "-- "} {
+		content, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(string(content), "\n")
+		header := lines[:len(lines)-1]
+		for _, line := range header {
+			if line != strings.TrimSpace(comment) && !strings.HasPrefix(line, comment) {
+				t.Errorf("%s: header line %q is not a %q comment", file, line, comment)
+			}
+		}
+		if !strings.Contains(string(content), comment+"Template source:     inline template_content\n") {
+			t.Errorf("%s has no template source line:\n%s", file, content)
+		}
+	}
+	if !r.planIsEmpty(config) {
+		t.Error("plan after apply is not empty")
+	}
+
+	config["provenance_header"] = boolValue(false)
+	r.mustApply(config)
+	if content, _ := os.ReadFile(filepath.Join(dir, "orders.sql")); string(content) != "SELECT * FROM orders" {
+		t.Errorf("orders.sql holds %q after provenance_header was turned off", content)
+	}
+}
+
+func TestProvenanceHeaderRejectsFilesWithoutComments(t *testing.T) {
+	p := newTestProvider(t, nil)
+	dir := t.TempDir()
+
+	bundle := p.resource("mirage_dag_bundle")
+	resp, _ := bundle.plan(map[string]tftypes.Value{
+		"provenance_header": boolValue(true),
+		"files": bundle.objectMapValue("files", map[string]map[string]tftypes.Value{
+			"file://" + dir + "/orders.py":   {"template_content": stringValue("a = 1")},
+			"file://" + dir + "/orders.json": {"template_content": stringValue("{}")},
+		}),
+	})
+	requireError(t, resp.Diagnostics, "cannot be written to file://"+dir+"/orders.json")
+
+	factory := p.resource("mirage_dag_factory")
+	resp, _ = factory.plan(map[string]tftypes.Value{
+		"provenance_header":   boolValue(true),
+		"template_content":    stringValue("{}"),
+		"specs":               stringMapValueOf(map[string]string{"orders": "dag_id: orders\n"}),
+		"target_path_pattern": stringValue("file://" + dir + "/{{ dag_id }}.json"),
+	})
+	requireError(t, resp.Diagnostics, "cannot be written to file://"+dir+"/orders.json")
+}
+
+func TestDagFactoryProvenanceHeader(t *testing.T) {
+	outDir := t.TempDir()
+	r := newTestProvider(t, nil).resource("mirage_dag_factory")
+
+	r.mustApply(map[string]tftypes.Value{
+		"provenance_header":   boolValue(true),
+		"template_content":    stringValue("dag_id = '{{ dag_id }}'"),
+		"specs":               stringMapValueOf(map[string]string{"orders": "dag_id: orders\n", "payments": "dag_id: payments\n"}),
+		"target_path_pattern": stringValue("file://" + filepath.ToSlash(outDir) + "/{{ dag_id }}.py"),
+	})
+
+	hashes := map[string]bool{}
+	for _, name := range []string{"orders", "payments"} {
+		content, err := os.ReadFile(filepath.Join(outDir, name+".py"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(content), "# ---") || !strings.HasSuffix(string(content), "\ndag_id = '"+name+"'") {
+			t.Errorf("%s.py does not start with a provenance header:\n%s", name, content)
+		}
+		for _, line := range strings.Split(string(content), "\n") {
+			if strings.HasPrefix(line, "# Context hash:") {
+				hashes[line] = true
+			}
+		}
+	}
+	if len(hashes) != 2 {
+		t.Errorf("the DAGs record %d distinct context hashes, want one each", len(hashes))
+	}
+}
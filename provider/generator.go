@@ -0,0 +1,303 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mm-aranda/terraform-provider-mirage/internal/client"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/pysyntax"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/render"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/storage"
+)
+
+// generator generates, inspects and deletes files for mirage_dag_generator.
+// The backend service implements it, and directGenerator implements it for
+// targets the provider renders and writes itself.
+type generator interface {
+	Generate(ctx context.Context, genReq client.GenerateRequest) (*client.GenerateResponse, error)
+	GetStatus(ctx context.Context, targetPath string) (*client.StatusResponse, error)
+	GetStatusWithOptions(ctx context.Context, targetPath string, opts client.StatusOptions) (*client.StatusResponse, error)
+	GetTemplateStatus(ctx context.Context, templatePath string) (*client.TemplateStatusResponse, error)
+	Delete(ctx context.Context, targetPath string) error
+}
+
+var (
+	_ generator = &client.DagGeneratorService{}
+	_ generator = &directGenerator{}
+)
+
+// directGenerator renders templates with the local renderer and reads and
+// writes files directly in their storage, so no backend is needed.
+type directGenerator struct {
+	// store returns the store holding p.
+	store func(ctx context.Context, p string) (storage.Store, error)
+	// check, if set, is called with each rendered file before it is
+	// written, and an error it returns fails the generation.
+	check func(content string) error
+}
+
+func (g *directGenerator) Generate(ctx context.Context, genReq client.GenerateRequest) (*client.GenerateResponse, error) {
+	template := genReq.TemplateContent
+	if genReq.TemplateGCSPath != "" {
+		store, err := g.store(ctx, genReq.TemplateGCSPath)
+		if err != nil {
+			return nil, err
+		}
+		content, err := store.Read(ctx, genReq.TemplateGCSPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template: %w", err)
+		}
+		template = string(content)
+	}
+
+	rendered, err := render.Render(template, genReq.TemplateFiles, genReq.ContextJSON, render.Options{StrictUndefined: genReq.StrictUndefined})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render template: %w", err)
+	}
+	rendered = genReq.ProvenanceHeader + rendered
+	if genReq.ValidatePython {
+		if err := checkPython(rendered); err != nil {
+			return nil, err
+		}
+	}
+	if g.check != nil {
+		if err := g.check(rendered); err != nil {
+			return nil, err
+		}
+	}
+
+	store, err := g.store(ctx, genReq.TargetGCSPath)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := store.Write(ctx, genReq.TargetGCSPath, []byte(rendered), storage.WriteOptions{
+		IfGenerationMatch: genReq.IfGenerationMatch,
+		ContentType:       genReq.ContentType,
+		CacheControl:      genReq.CacheControl,
+		Metadata:          genReq.Metadata,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &client.GenerateResponse{Checksum: obj.Checksum, Generation: obj.Generation}, nil
+}
+
+// checkPython returns an error wrapping the *pysyntax.Error in content, with
+// the offending line quoted, or nil if content is valid Python.
+func checkPython(content string) error {
+	err := pysyntax.Check(content)
+	var syntaxErr *pysyntax.Error
+	if !errors.As(err, &syntaxErr) {
+		return err
+	}
+	return fmt.Errorf("the rendered file is not valid Python: %w%s", err, quoteLine(content, syntaxErr.Line, syntaxErr.Col))
+}
+
+// quoteLine returns the 1-based line of content, indented and preceded by a
+// blank line, with a caret under the given column, or "" if there is no such
+// line.
+func quoteLine(content string, line, col int) string {
+	lines := strings.Split(content, "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	text := strings.TrimRight(lines[line-1], "\r")
+	// Tabs are kept in the caret's indentation, so it lines up however wide
+	// they are displayed.
+	var caret strings.Builder
+	for i, r := range []rune(text) {
+		if i >= col-1 {
+			break
+		}
+		if r == '\t' {
+			caret.WriteRune('\t')
+		} else {
+			caret.WriteRune(' ')
+		}
+	}
+	caret.WriteRune('^')
+	return fmt.Sprintf("\n\n    %s\n    %s", text, caret.String())
+}
+
+func (g *directGenerator) GetStatus(ctx context.Context, targetPath string) (*client.StatusResponse, error) {
+	return g.GetStatusWithOptions(ctx, targetPath, client.StatusOptions{})
+}
+
+func (g *directGenerator) GetStatusWithOptions(ctx context.Context, targetPath string, opts client.StatusOptions) (*client.StatusResponse, error) {
+	store, err := g.store(ctx, targetPath)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := store.Stat(ctx, targetPath)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, client.ErrNotFound
+		}
+		return nil, err
+	}
+	status := &client.StatusResponse{
+		Checksum:     obj.Checksum,
+		Generation:   obj.Generation,
+		Size:         obj.Size,
+		ContentType:  obj.ContentType,
+		CacheControl: obj.CacheControl,
+		LastModified: obj.LastModified,
+		Metadata:     obj.Metadata,
+	}
+	if opts.IncludeContent {
+		content, err := store.Read(ctx, targetPath)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				return nil, client.ErrNotFound
+			}
+			return nil, err
+		}
+		status.Content = string(content)
+	}
+	return status, nil
+}
+
+func (g *directGenerator) GetTemplateStatus(ctx context.Context, templatePath string) (*client.TemplateStatusResponse, error) {
+	store, err := g.store(ctx, templatePath)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := store.Stat(ctx, templatePath)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return &client.TemplateStatusResponse{Exists: false}, nil
+		}
+		return nil, err
+	}
+	return &client.TemplateStatusResponse{
+		Checksum:     obj.Checksum,
+		Generation:   obj.Generation,
+		LastModified: obj.LastModified,
+		Exists:       true,
+	}, nil
+}
+
+func (g *directGenerator) Delete(ctx context.Context, targetPath string) error {
+	store, err := g.store(ctx, targetPath)
+	if err != nil {
+		return err
+	}
+	return store.Delete(ctx, targetPath)
+}
+
+// usesBackend reports whether targetPath is generated by the backend service.
+// file://, s3:// and az:// paths, and gs:// paths when no backend is
+// configured, are rendered and written by the provider.
+func usesBackend(targetPath, backendURL string) bool {
+	if storage.IsLocal(targetPath) || storage.IsS3(targetPath) || storage.IsAzure(targetPath) {
+		return false
+	}
+	return backendURL != "" || !storage.IsGCS(targetPath)
+}
+
+// generatorFor returns the generator that manages targetPath, as decided by
+// usesBackend.
+func (d *mirageProviderData) generatorFor(targetPath, backendURL string, useServiceAccountAuth bool, headers map[string]string) (generator, error) {
+	if !usesBackend(targetPath, backendURL) {
+		return &directGenerator{store: d.store}, nil
+	}
+	if backendURL == "" {
+		return nil, fmt.Errorf("dag_generator_backend_url is required for target %s", targetPath)
+	}
+	dagGenService, err := d.newDagGeneratorService(backendURL, useServiceAccountAuth, headers)
+	if err != nil {
+		return nil, err
+	}
+	return dagGenService, nil
+}
+
+// store returns the store holding p, creating the shared cloud stores on
+// first use.
+func (d *mirageProviderData) store(_ context.Context, p string) (storage.Store, error) {
+	switch {
+	case storage.IsLocal(p):
+		return storage.LocalFS{}, nil
+	case storage.IsGCS(p):
+		return d.gcsStore()
+	case storage.IsS3(p):
+		return d.s3Store()
+	case storage.IsAzure(p):
+		return d.azureStore()
+	default:
+		return nil, fmt.Errorf("unsupported path %q: expected a %s, %s, %s or %s path", p,
+			storage.GCSScheme, storage.S3Scheme, storage.AzureScheme, storage.LocalScheme)
+	}
+}
+
+func (d *mirageProviderData) gcsStore() (storage.Store, error) {
+	if d == nil {
+		return nil, errors.New("the provider has not been configured")
+	}
+
+	d.storesMu.Lock()
+	defer d.storesMu.Unlock()
+
+	if d.gcs != nil {
+		return d.gcs, nil
+	}
+	httpClient, err := client.NewHTTPClient(d.clientOptions.Transport)
+	if err != nil {
+		return nil, err
+	}
+	// The store outlives any single request, so its credentials must not be
+	// tied to a request context.
+	gcs, err := storage.NewGCS(context.Background(), httpClient, d.gcsEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	d.gcs = gcs
+	return gcs, nil
+}
+
+func (d *mirageProviderData) s3Store() (storage.Store, error) {
+	if d == nil {
+		return nil, errors.New("the provider has not been configured")
+	}
+
+	d.storesMu.Lock()
+	defer d.storesMu.Unlock()
+
+	if d.s3 != nil {
+		return d.s3, nil
+	}
+	httpClient, err := client.NewHTTPClient(d.clientOptions.Transport)
+	if err != nil {
+		return nil, err
+	}
+	s3, err := storage.NewS3(context.Background(), httpClient, d.s3Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	d.s3 = s3
+	return s3, nil
+}
+
+func (d *mirageProviderData) azureStore() (storage.Store, error) {
+	if d == nil {
+		return nil, errors.New("the provider has not been configured")
+	}
+
+	d.storesMu.Lock()
+	defer d.storesMu.Unlock()
+
+	if d.azure != nil {
+		return d.azure, nil
+	}
+	httpClient, err := client.NewHTTPClient(d.clientOptions.Transport)
+	if err != nil {
+		return nil, err
+	}
+	azure, err := storage.NewAzure(httpClient, d.azureStorageAccount, d.azureEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	d.azure = azure
+	return azure, nil
+}
@@ -0,0 +1,151 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/client"
+)
+
+// provenanceComments maps the extensions of the file types a provenance
+// header can be written to to the syntax of their line comments. Formats
+// without comments, such as JSON, cannot carry one.
+var provenanceComments = map[string]string{
+	".py":    "#",
+	".sh":    "#",
+	".yaml":  "#",
+	".yml":   "#",
+	".toml":  "#",
+	".cfg":   "#",
+	".r":     "#",
+	".sql":   "--",
+	".hql":   "--",
+	".js":    "//",
+	".ts":    "//",
+	".java":  "//",
+	".scala": "//",
+	".go":    "//",
+}
+
+// provenanceComment returns the line comment syntax of target's file type.
+func provenanceComment(target string) (string, error) {
+	if comment, ok := provenanceComments[strings.ToLower(filepath.Ext(target))]; ok {
+		return comment, nil
+	}
+	exts := make([]string, 0, len(provenanceComments))
+	for ext := range provenanceComments {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	return "", fmt.Errorf("a provenance header cannot be written to %s, since only files with line comments can carry one: %s", target, strings.Join(exts, ", "))
+}
+
+// validateProvenanceTarget adds an error at p if provenance_header is set and
+// target, when known, cannot carry a provenance header.
+func validateProvenanceTarget(enabled types.Bool, target types.String, p path.Path, diags *diag.Diagnostics) {
+	if !enabled.ValueBool() || target.IsUnknown() || target.IsNull() {
+		return
+	}
+	if _, err := provenanceComment(target.ValueString()); err != nil {
+		diags.AddAttributeError(p, "Invalid Configuration", "`provenance_header` is set, but "+err.Error()+".")
+	}
+}
+
+// provenance describes where a generated file came from.
+type provenance struct {
+	TemplateSource   string
+	TemplateChecksum string
+	BundleChecksum   string
+	ProviderVersion  string
+	Workspace        string
+}
+
+// header renders the provenance of the file at target, generated with
+// contextJSON, as a block of comments in the syntax of its file type, ending
+// in a newline, to be placed at the top of the file.
+func (p provenance) header(target, contextJSON string) (string, error) {
+	comment, err := provenanceComment(target)
+	if err != nil {
+		return "", err
+	}
+	rule := comment + " " + strings.Repeat("-", 77)
+	lines := []string{
+		rule,
+		comment + " Generated by terraform-provider-mirage. Do not edit this file by hand:",
+		comment + " changes are overwritten the next time it is generated.",
+		comment,
+		comment + " Template source:     " + p.TemplateSource,
+		comment + " Template checksum:   " + p.TemplateChecksum,
+	}
+	if p.BundleChecksum != "" {
+		lines = append(lines, comment+" Template bundle:     sha256:"+p.BundleChecksum)
+	}
+	lines = append(lines,
+		comment+" Context hash:        sha256:"+sha256Hex(contextJSON),
+		comment+" Provider version:    "+p.ProviderVersion,
+		comment+" Terraform workspace: "+p.Workspace,
+		rule,
+	)
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// provenance describes files generated from the template at templatePath, or
+// from inline content when templatePath is empty. The header is never
+// compared when deciding whether to regenerate a file, so a new provider
+// version or workspace alone does not rewrite it.
+func (d *mirageProviderData) provenance(ctx context.Context, gen generator, templatePath, content string, bundle map[string]string, diags *diag.Diagnostics) provenance {
+	p := provenance{
+		TemplateSource:   "inline template_content",
+		TemplateChecksum: client.CRC32C([]byte(content)),
+		BundleChecksum:   bundleChecksum(bundle),
+		ProviderVersion:  d.version,
+		Workspace:        terraformWorkspace(),
+	}
+	if templatePath != "" {
+		p.TemplateSource = templatePath
+		p.TemplateChecksum = "unknown"
+		status, err := gen.GetTemplateStatus(ctx, templatePath)
+		if err != nil {
+			diags.AddWarning(
+				"Could not get template status",
+				fmt.Sprintf("Unable to get the checksum of %s for the provenance header: %v", templatePath, err),
+			)
+		} else if status.Checksum != "" {
+			p.TemplateChecksum = status.Checksum
+		}
+	}
+	return p
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// terraformWorkspace returns the selected Terraform workspace. Terraform does
+// not pass it to providers, so it is looked up the way Terraform selects it:
+// TF_WORKSPACE, then the environment file in the data directory.
+func terraformWorkspace() string {
+	if ws := os.Getenv("TF_WORKSPACE"); ws != "" {
+		return ws
+	}
+	dataDir := os.Getenv("TF_DATA_DIR")
+	if dataDir == "" {
+		dataDir = ".terraform"
+	}
+	if ws, err := os.ReadFile(filepath.Join(dataDir, "environment")); err == nil {
+		if ws := strings.TrimSpace(string(ws)); ws != "" {
+			return ws
+		}
+	}
+	return "default"
+}
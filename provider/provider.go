@@ -2,10 +2,20 @@ package provider
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/provider"
 	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/client"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/storage"
 )
 
 var _ provider.Provider = &MirageProvider{}
@@ -14,6 +24,127 @@ type MirageProvider struct {
 	version string
 }
 
+type mirageProviderModel struct {
+	CACertFile              types.String  `tfsdk:"ca_cert_file"`
+	CACertPEM               types.String  `tfsdk:"ca_cert_pem"`
+	InsecureSkipVerify      types.Bool    `tfsdk:"insecure_skip_verify"`
+	MinTLSVersion           types.String  `tfsdk:"min_tls_version"`
+	ProxyURL                types.String  `tfsdk:"proxy_url"`
+	NoProxy                 types.List    `tfsdk:"no_proxy"`
+	MaxIdleConns            types.Int64   `tfsdk:"max_idle_conns"`
+	MaxIdleConnsPerHost     types.Int64   `tfsdk:"max_idle_conns_per_host"`
+	MaxConnsPerHost         types.Int64   `tfsdk:"max_conns_per_host"`
+	IdleConnTimeout         types.String  `tfsdk:"idle_conn_timeout"`
+	DefaultHeaders          types.Map     `tfsdk:"default_headers"`
+	MaxRetries              types.Int64   `tfsdk:"max_retries"`
+	MaxConcurrentRequests   types.Int64   `tfsdk:"max_concurrent_requests"`
+	RequestsPerSecond       types.Float64 `tfsdk:"requests_per_second"`
+	CircuitBreakerThreshold types.Int64   `tfsdk:"circuit_breaker_threshold"`
+	CircuitBreakerCooldown  types.String  `tfsdk:"circuit_breaker_cooldown"`
+	HealthCheck             types.Bool    `tfsdk:"health_check"`
+	GCSEndpoint             types.String  `tfsdk:"gcs_endpoint"`
+	S3Endpoint              types.String  `tfsdk:"s3_endpoint"`
+	AzureStorageAccount     types.String  `tfsdk:"azure_storage_account"`
+	AzureEndpoint           types.String  `tfsdk:"azure_endpoint"`
+}
+
+// mirageProviderData is passed to resources and data sources as their provider data.
+type mirageProviderData struct {
+	clientOptions client.ClientOptions
+	// version is the provider version, recorded in provenance headers.
+	version string
+
+	// clients holds one API client per backend URL and auth mode, so every
+	// resource talking to the same backend shares its connection pool and
+	// request limits.
+	clientsMu sync.Mutex
+	clients   map[clientKey]*client.DagGeneratorAPIClient
+
+	// The stores used to read and write files directly, each created on
+	// first use: gs:// when a resource has no backend, s3:// and az:// always.
+	gcsEndpoint         string
+	s3Endpoint          string
+	azureStorageAccount string
+	azureEndpoint       string
+	storesMu            sync.Mutex
+	gcs                 *storage.GCS
+	s3                  *storage.S3
+	azure               *storage.Azure
+}
+
+type clientKey struct {
+	backendURL            string
+	useServiceAccountAuth bool
+}
+
+// newDagGeneratorService builds a service for the given backend, applying the
+// provider-level client options when the provider has been configured.
+// headers are sent with every request and override the provider's default_headers.
+func (d *mirageProviderData) newDagGeneratorService(backendURL string, useServiceAccountAuth bool, headers map[string]string) (*client.DagGeneratorService, error) {
+	if err := client.CheckHeaders(headers, useServiceAccountAuth); err != nil {
+		return nil, fmt.Errorf("headers: %w", err)
+	}
+	if d == nil {
+		apiClient, err := client.NewDagGeneratorAPIClientWithOptions(backendURL, client.ClientOptions{UseServiceAccountAuth: useServiceAccountAuth})
+		if err != nil {
+			return nil, err
+		}
+		return &client.DagGeneratorService{Client: apiClient, Headers: headers}, nil
+	}
+
+	apiClient, err := d.apiClient(backendURL, useServiceAccountAuth)
+	if err != nil {
+		return nil, err
+	}
+	return &client.DagGeneratorService{Client: apiClient, Headers: headers}, nil
+}
+
+// apiClient returns the shared client for the backend, creating it on first use.
+func (d *mirageProviderData) apiClient(backendURL string, useServiceAccountAuth bool) (*client.DagGeneratorAPIClient, error) {
+	d.clientsMu.Lock()
+	defer d.clientsMu.Unlock()
+
+	key := clientKey{backendURL: backendURL, useServiceAccountAuth: useServiceAccountAuth}
+	if c, ok := d.clients[key]; ok {
+		return c, nil
+	}
+
+	opts := d.clientOptions
+	opts.UseServiceAccountAuth = useServiceAccountAuth
+	c, err := client.NewDagGeneratorAPIClientWithOptions(backendURL, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.clients == nil {
+		d.clients = make(map[clientKey]*client.DagGeneratorAPIClient)
+	}
+	d.clients[key] = c
+	return c, nil
+}
+
+// addBackendError reports a failed backend call. Calls rejected by an open
+// circuit breaker get a dedicated summary so an outage reads as a single cause
+// rather than one timeout per resource.
+func addBackendError(diags *diag.Diagnostics, summary string, err error) {
+	if errors.Is(err, client.ErrCircuitOpen) {
+		diags.AddError("Backend Unavailable", err.Error())
+		return
+	}
+	diags.AddError(summary, err.Error())
+}
+
+// stringMapValue converts a Terraform map of strings into a Go map. Null and
+// unknown maps yield a nil map.
+func stringMapValue(ctx context.Context, m types.Map) (map[string]string, diag.Diagnostics) {
+	if m.IsNull() || m.IsUnknown() {
+		return nil, nil
+	}
+	var out map[string]string
+	diags := m.ElementsAs(ctx, &out, false)
+	return out, diags
+}
+
 func (p *MirageProvider) Metadata(_ context.Context, req provider.MetadataRequest, resp *provider.MetadataResponse) {
 	resp.TypeName = "mirage"
 	resp.Version = p.version
@@ -22,20 +153,240 @@ func (p *MirageProvider) Metadata(_ context.Context, req provider.MetadataReques
 func (p *MirageProvider) Schema(_ context.Context, _ provider.SchemaRequest, resp *provider.SchemaResponse) {
 	resp.Schema = schema.Schema{
 		Description: "Mirage Provider for managing resources in the Mirage ecosystem.",
+		Attributes: map[string]schema.Attribute{
+			"ca_cert_file": schema.StringAttribute{
+				Description: "Path to a PEM encoded CA bundle used to verify the backend's TLS certificate, in addition to the system roots.",
+				Optional:    true,
+			},
+			"ca_cert_pem": schema.StringAttribute{
+				Description: "PEM encoded CA certificates used to verify the backend's TLS certificate, in addition to the system roots.",
+				Optional:    true,
+			},
+			"insecure_skip_verify": schema.BoolAttribute{
+				Description: "If true, skip verification of the backend's TLS certificate. Only intended for development.",
+				Optional:    true,
+			},
+			"min_tls_version": schema.StringAttribute{
+				Description: "The minimum TLS version accepted when talking to the backend. One of `1.0`, `1.1`, `1.2` or `1.3`.",
+				Optional:    true,
+			},
+			"proxy_url": schema.StringAttribute{
+				Description: "URL of the HTTP proxy used for backend requests. Defaults to the `HTTPS_PROXY`/`HTTP_PROXY` environment variables.",
+				Optional:    true,
+			},
+			"no_proxy": schema.ListAttribute{
+				Description: "Hosts, domains or CIDR ranges that bypass the proxy.",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"max_idle_conns": schema.Int64Attribute{
+				Description: "Maximum number of idle keep-alive connections across all backends.",
+				Optional:    true,
+			},
+			"max_idle_conns_per_host": schema.Int64Attribute{
+				Description: "Maximum number of idle keep-alive connections kept per backend host.",
+				Optional:    true,
+			},
+			"max_conns_per_host": schema.Int64Attribute{
+				Description: "Maximum number of connections per backend host, including those in use. Zero means no limit.",
+				Optional:    true,
+			},
+			"idle_conn_timeout": schema.StringAttribute{
+				Description: "How long an idle connection is kept open, as a Go duration string (e.g. `90s`).",
+				Optional:    true,
+			},
+			"max_retries": schema.Int64Attribute{
+				Description: "How many times a backend request is retried after a network error or a 429, 502, 503 or 504 response. Defaults to 0.",
+				Optional:    true,
+			},
+			"max_concurrent_requests": schema.Int64Attribute{
+				Description: "Maximum number of in-flight requests to each backend, shared by every resource and data source using it. Zero means no limit.",
+				Optional:    true,
+			},
+			"requests_per_second": schema.Float64Attribute{
+				Description: "Maximum rate of requests to each backend, shared by every resource and data source using it. Zero means no limit.",
+				Optional:    true,
+			},
+			"circuit_breaker_threshold": schema.Int64Attribute{
+				Description: "Number of consecutive failed requests (network errors or 5xx responses) to a backend after which further requests fail fast. Zero disables the circuit breaker.",
+				Optional:    true,
+			},
+			"circuit_breaker_cooldown": schema.StringAttribute{
+				Description: "How long the circuit breaker stays open before a trial request is sent, as a Go duration string. Defaults to `30s`.",
+				Optional:    true,
+			},
+			"health_check": schema.BoolAttribute{
+				Description: "If true, probe each backend's `/healthz` endpoint before the first request. A failed probe opens the circuit breaker, or fails every request to that backend when the breaker is disabled.",
+				Optional:    true,
+			},
+			"default_headers": schema.MapAttribute{
+				Description: "Headers sent with every backend request. Resources can override individual headers with their own `headers` attribute. Headers the provider sets itself, such as `Content-Type` and `X-Request-ID`, cannot be configured.",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"gcs_endpoint": schema.StringAttribute{
+				Description: "Base URL of the Cloud Storage JSON API, used by resources that write to GCS directly because they have no backend. Defaults to `https://storage.googleapis.com`. The `STORAGE_EMULATOR_HOST` environment variable takes precedence.",
+				Optional:    true,
+			},
+			"s3_endpoint": schema.StringAttribute{
+				Description: "Endpoint URL of an S3-compatible store such as MinIO, used for `s3://` paths with path-style addressing. Defaults to Amazon S3. Credentials and region come from the AWS SDK's default chain, such as `AWS_ACCESS_KEY_ID`, `AWS_SECRET_ACCESS_KEY` and `AWS_REGION`.",
+				Optional:    true,
+			},
+			"azure_storage_account": schema.StringAttribute{
+				Description: "Azure Storage account holding `az://container/blob` paths, authenticated with the Azure SDK's default credential chain. The `AZURE_STORAGE_CONNECTION_STRING` environment variable takes precedence, which suits Azurite.",
+				Optional:    true,
+			},
+			"azure_endpoint": schema.StringAttribute{
+				Description: "Blob service URL for `az://` paths. Defaults to `https://<azure_storage_account>.blob.core.windows.net/`.",
+				Optional:    true,
+			},
+		},
 	}
 }
 
-func (p *MirageProvider) Configure(_ context.Context, _ provider.ConfigureRequest, resp *provider.ConfigureResponse) {
+func (p *MirageProvider) Configure(ctx context.Context, req provider.ConfigureRequest, resp *provider.ConfigureResponse) {
+	var config mirageProviderModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	transport := client.TransportConfig{
+		CACertFile:          config.CACertFile.ValueString(),
+		CACertPEM:           config.CACertPEM.ValueString(),
+		InsecureSkipVerify:  config.InsecureSkipVerify.ValueBool(),
+		MinTLSVersion:       config.MinTLSVersion.ValueString(),
+		ProxyURL:            config.ProxyURL.ValueString(),
+		MaxIdleConns:        int(config.MaxIdleConns.ValueInt64()),
+		MaxIdleConnsPerHost: int(config.MaxIdleConnsPerHost.ValueInt64()),
+		MaxConnsPerHost:     int(config.MaxConnsPerHost.ValueInt64()),
+	}
+
+	if !config.NoProxy.IsNull() && !config.NoProxy.IsUnknown() {
+		resp.Diagnostics.Append(config.NoProxy.ElementsAs(ctx, &transport.NoProxy, false)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+
+	if timeout := config.IdleConnTimeout.ValueString(); timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("idle_conn_timeout"),
+				"Invalid Duration",
+				fmt.Sprintf("Unable to parse %q as a duration: %v", timeout, err),
+			)
+			return
+		}
+		transport.IdleConnTimeout = d
+	}
+
+	if config.MaxRetries.ValueInt64() < 0 {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("max_retries"),
+			"Invalid Retry Count",
+			"`max_retries` must not be negative.",
+		)
+		return
+	}
+
+	if config.MaxConcurrentRequests.ValueInt64() < 0 || config.RequestsPerSecond.ValueFloat64() < 0 {
+		resp.Diagnostics.AddError(
+			"Invalid Request Limits",
+			"`max_concurrent_requests` and `requests_per_second` must not be negative.",
+		)
+		return
+	}
+
+	if config.CircuitBreakerThreshold.ValueInt64() < 0 {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("circuit_breaker_threshold"),
+			"Invalid Circuit Breaker Threshold",
+			"`circuit_breaker_threshold` must not be negative.",
+		)
+		return
+	}
+
+	breakerCooldown := 30 * time.Second
+	if cooldown := config.CircuitBreakerCooldown.ValueString(); cooldown != "" {
+		d, err := time.ParseDuration(cooldown)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("circuit_breaker_cooldown"),
+				"Invalid Duration",
+				fmt.Sprintf("Unable to parse %q as a duration: %v", cooldown, err),
+			)
+			return
+		}
+		breakerCooldown = d
+	}
+
+	defaultHeaders, diags := stringMapValue(ctx, config.DefaultHeaders)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if err := client.CheckHeaders(defaultHeaders, false); err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("default_headers"), "Invalid Default Headers", err.Error())
+		return
+	}
+
+	// Build a client once so configuration errors (bad CA bundle, proxy URL,
+	// TLS version) surface here instead of on every resource.
+	if _, err := client.NewHTTPClient(transport); err != nil {
+		resp.Diagnostics.AddError("Invalid HTTP Client Configuration", err.Error())
+		return
+	}
+
+	if transport.InsecureSkipVerify {
+		resp.Diagnostics.AddAttributeWarning(
+			path.Root("insecure_skip_verify"),
+			"TLS Verification Disabled",
+			"The backend's TLS certificate will not be verified. Do not use this setting outside of development.",
+		)
+	}
+
+	data := &mirageProviderData{
+		clientOptions: client.ClientOptions{
+			Transport:      transport,
+			UserAgent:      client.UserAgent(p.version, req.TerraformVersion),
+			DefaultHeaders: defaultHeaders,
+			MaxRetries:     int(config.MaxRetries.ValueInt64()),
+
+			MaxConcurrentRequests: int(config.MaxConcurrentRequests.ValueInt64()),
+			RequestsPerSecond:     config.RequestsPerSecond.ValueFloat64(),
+
+			CircuitBreakerThreshold: int(config.CircuitBreakerThreshold.ValueInt64()),
+			CircuitBreakerCooldown:  breakerCooldown,
+			HealthCheck:             config.HealthCheck.ValueBool(),
+		},
+		version:             p.version,
+		gcsEndpoint:         config.GCSEndpoint.ValueString(),
+		s3Endpoint:          config.S3Endpoint.ValueString(),
+		azureStorageAccount: config.AzureStorageAccount.ValueString(),
+		azureEndpoint:       config.AzureEndpoint.ValueString(),
+	}
+	resp.ResourceData = data
+	resp.DataSourceData = data
 }
 
 func (p *MirageProvider) Resources(_ context.Context) []func() resource.Resource {
 	return []func() resource.Resource{
 		NewDagGeneratorResource,
+		NewTemplateResource,
+		NewDagBundleResource,
+		NewDagGeneratorSetResource,
+		NewDagFactoryResource,
 	}
 }
 
 func (p *MirageProvider) DataSources(_ context.Context) []func() datasource.DataSource {
-	return nil
+	return []func() datasource.DataSource{
+		NewBackendDataSource,
+		NewGeneratedFileDataSource,
+		NewTemplateDataSource,
+	}
 }
 
 func New(version string) func() provider.Provider {
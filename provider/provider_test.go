@@ -0,0 +1,412 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+// The tests in this package drive the provider through the plugin protocol,
+// as Terraform does, so they cover plan modifiers, state handling and
+// diagnostics without a terraform binary or network access. Targets are
+// file:// paths, or cloud paths served by fake stores.
+
+// testProvider is a configured provider server.
+type testProvider struct {
+	t       *testing.T
+	server  tfprotov6.ProviderServer
+	schemas *tfprotov6.GetProviderSchemaResponse
+}
+
+// newTestProvider returns a provider configured with config, a map of
+// provider attributes. Attributes left out are null.
+func newTestProvider(t *testing.T, config map[string]tftypes.Value) *testProvider {
+	t.Helper()
+
+	server, err := providerserver.NewProtocol6WithError(New("test")())()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	schemas, err := server.GetProviderSchema(ctx, &tfprotov6.GetProviderSchemaRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	requireNoErrors(t, "GetProviderSchema", schemas.Diagnostics)
+
+	p := &testProvider{t: t, server: server, schemas: schemas}
+	resp, err := server.ConfigureProvider(ctx, &tfprotov6.ConfigureProviderRequest{
+		TerraformVersion: "1.9.0",
+		Config:           p.dynamicValue(schemas.Provider, objectValue(schemas.Provider, config)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	requireNoErrors(t, "ConfigureProvider", resp.Diagnostics)
+	return p
+}
+
+// resource returns a handle on the resource of the given type, such as
+// mirage_dag_generator.
+func (p *testProvider) resource(typeName string) *testResource {
+	p.t.Helper()
+	schema, ok := p.schemas.ResourceSchemas[typeName]
+	if !ok {
+		p.t.Fatalf("no resource type %s", typeName)
+	}
+	return &testResource{p: p, typeName: typeName, schema: schema, state: tftypes.NewValue(schema.ValueType(), nil)}
+}
+
+func (p *testProvider) dynamicValue(schema *tfprotov6.Schema, v tftypes.Value) *tfprotov6.DynamicValue {
+	p.t.Helper()
+	dv, err := tfprotov6.NewDynamicValue(schema.ValueType(), v)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	return &dv
+}
+
+// testResource is a resource instance whose state is kept across applies.
+type testResource struct {
+	p        *testProvider
+	typeName string
+	schema   *tfprotov6.Schema
+	state    tftypes.Value
+}
+
+// plan plans the change from the current state to config, a map of resource
+// attributes, or to destruction when config is nil.
+func (r *testResource) plan(config map[string]tftypes.Value) (*tfprotov6.PlanResourceChangeResponse, tftypes.Value) {
+	r.p.t.Helper()
+
+	configValue := tftypes.NewValue(r.schema.ValueType(), nil)
+	proposed := configValue
+	if config != nil {
+		configValue = objectValue(r.schema, config)
+		proposed = proposedNewState(r.schema, r.state, configValue)
+	}
+
+	resp, err := r.p.server.PlanResourceChange(context.Background(), &tfprotov6.PlanResourceChangeRequest{
+		TypeName:         r.typeName,
+		PriorState:       r.p.dynamicValue(r.schema, r.state),
+		ProposedNewState: r.p.dynamicValue(r.schema, proposed),
+		Config:           r.p.dynamicValue(r.schema, configValue),
+	})
+	if err != nil {
+		r.p.t.Fatal(err)
+	}
+	return resp, configValue
+}
+
+// apply plans and applies config, or destroys the resource when config is
+// nil, and returns the diagnostics of both steps. The state is updated with
+// whatever the apply saved, even if it failed.
+func (r *testResource) apply(config map[string]tftypes.Value) []*tfprotov6.Diagnostic {
+	r.p.t.Helper()
+
+	planResp, configValue := r.plan(config)
+	if hasErrors(planResp.Diagnostics) {
+		return planResp.Diagnostics
+	}
+
+	resp, err := r.p.server.ApplyResourceChange(context.Background(), &tfprotov6.ApplyResourceChangeRequest{
+		TypeName:     r.typeName,
+		PriorState:   r.p.dynamicValue(r.schema, r.state),
+		PlannedState: planResp.PlannedState,
+		Config:       r.p.dynamicValue(r.schema, configValue),
+	})
+	if err != nil {
+		r.p.t.Fatal(err)
+	}
+	if resp.NewState != nil {
+		r.state = r.p.unmarshal(r.schema, resp.NewState)
+	}
+	return append(planResp.Diagnostics, resp.Diagnostics...)
+}
+
+// validate validates config, a map of resource attributes, as Terraform does
+// before planning. Deprecated attributes are reported here.
+func (r *testResource) validate(config map[string]tftypes.Value) []*tfprotov6.Diagnostic {
+	r.p.t.Helper()
+
+	resp, err := r.p.server.ValidateResourceConfig(context.Background(), &tfprotov6.ValidateResourceConfigRequest{
+		TypeName: r.typeName,
+		Config:   r.p.dynamicValue(r.schema, objectValue(r.schema, config)),
+	})
+	if err != nil {
+		r.p.t.Fatal(err)
+	}
+	return resp.Diagnostics
+}
+
+// upgrade replaces the current state with raw, the JSON state of schema
+// version, upgraded to the current schema.
+func (r *testResource) upgrade(version int64, raw map[string]any) []*tfprotov6.Diagnostic {
+	r.p.t.Helper()
+
+	rawJSON, err := json.Marshal(raw)
+	if err != nil {
+		r.p.t.Fatal(err)
+	}
+	resp, err := r.p.server.UpgradeResourceState(context.Background(), &tfprotov6.UpgradeResourceStateRequest{
+		TypeName: r.typeName,
+		Version:  version,
+		RawState: &tfprotov6.RawState{JSON: rawJSON},
+	})
+	if err != nil {
+		r.p.t.Fatal(err)
+	}
+	if resp.UpgradedState != nil {
+		r.state = r.p.unmarshal(r.schema, resp.UpgradedState)
+	}
+	return resp.Diagnostics
+}
+
+// mustApply is apply, failing the test on error diagnostics.
+func (r *testResource) mustApply(config map[string]tftypes.Value) {
+	r.p.t.Helper()
+	requireNoErrors(r.p.t, "apply "+r.typeName, r.apply(config))
+}
+
+// refresh reads the resource and stores the refreshed state.
+func (r *testResource) refresh() []*tfprotov6.Diagnostic {
+	r.p.t.Helper()
+
+	resp, err := r.p.server.ReadResource(context.Background(), &tfprotov6.ReadResourceRequest{
+		TypeName:     r.typeName,
+		CurrentState: r.p.dynamicValue(r.schema, r.state),
+	})
+	if err != nil {
+		r.p.t.Fatal(err)
+	}
+	if resp.NewState != nil {
+		r.state = r.p.unmarshal(r.schema, resp.NewState)
+	}
+	return resp.Diagnostics
+}
+
+// planIsEmpty reports whether planning config from the current state
+// changes nothing.
+func (r *testResource) planIsEmpty(config map[string]tftypes.Value) bool {
+	r.p.t.Helper()
+
+	resp, _ := r.plan(config)
+	requireNoErrors(r.p.t, "plan "+r.typeName, resp.Diagnostics)
+	planned := r.p.unmarshal(r.schema, resp.PlannedState)
+	return planned.Equal(r.state)
+}
+
+// attr returns the named top-level attribute of the state.
+func (r *testResource) attr(name string) tftypes.Value {
+	r.p.t.Helper()
+
+	if r.state.IsNull() {
+		r.p.t.Fatalf("%s has no state", r.typeName)
+	}
+	return objectAttr(r.p.t, r.state, name)
+}
+
+// stringAttr returns the named string attribute of the state, "" if null.
+func (r *testResource) stringAttr(name string) string {
+	r.p.t.Helper()
+
+	v := r.attr(name)
+	if v.IsNull() || !v.IsKnown() {
+		return ""
+	}
+	var s string
+	if err := v.As(&s); err != nil {
+		r.p.t.Fatal(err)
+	}
+	return s
+}
+
+// stringMapAttr returns the named map of strings of the state.
+func (r *testResource) stringMapAttr(name string) map[string]string {
+	r.p.t.Helper()
+
+	var values map[string]tftypes.Value
+	if err := r.attr(name).As(&values); err != nil {
+		r.p.t.Fatal(err)
+	}
+	m := make(map[string]string, len(values))
+	for k, v := range values {
+		var s string
+		if err := v.As(&s); err != nil {
+			r.p.t.Fatal(err)
+		}
+		m[k] = s
+	}
+	return m
+}
+
+// objectMapValue returns a value for the named map of objects attribute, such
+// as the files of mirage_dag_bundle, with an element per entry. Attributes an
+// entry leaves out are null.
+func (r *testResource) objectMapValue(name string, entries map[string]map[string]tftypes.Value) tftypes.Value {
+	r.p.t.Helper()
+
+	mapType, ok := r.schema.ValueType().(tftypes.Object).AttributeTypes[name].(tftypes.Map)
+	if !ok {
+		r.p.t.Fatalf("%s is not a map attribute of %s", name, r.typeName)
+	}
+	elemType := mapType.ElementType.(tftypes.Object)
+	elems := make(map[string]tftypes.Value, len(entries))
+	for key, attrs := range entries {
+		values := make(map[string]tftypes.Value, len(elemType.AttributeTypes))
+		for attrName, attrType := range elemType.AttributeTypes {
+			if v, ok := attrs[attrName]; ok {
+				values[attrName] = v
+				continue
+			}
+			values[attrName] = tftypes.NewValue(attrType, nil)
+		}
+		elems[key] = tftypes.NewValue(elemType, values)
+	}
+	return tftypes.NewValue(mapType, elems)
+}
+
+func (p *testProvider) unmarshal(schema *tfprotov6.Schema, dv *tfprotov6.DynamicValue) tftypes.Value {
+	p.t.Helper()
+	v, err := dv.Unmarshal(schema.ValueType())
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	return v
+}
+
+// objectValue returns a value of schema's type with the given attributes and
+// every other attribute null.
+func objectValue(schema *tfprotov6.Schema, attrs map[string]tftypes.Value) tftypes.Value {
+	typ := schema.ValueType().(tftypes.Object)
+	values := make(map[string]tftypes.Value, len(typ.AttributeTypes))
+	for name, attrType := range typ.AttributeTypes {
+		if v, ok := attrs[name]; ok {
+			values[name] = v
+			continue
+		}
+		values[name] = tftypes.NewValue(attrType, nil)
+	}
+	return tftypes.NewValue(typ, values)
+}
+
+// proposedNewState returns the proposed new state Terraform sends when
+// planning config from prior: config, with computed attributes it leaves null
+// taken from the prior state.
+func proposedNewState(schema *tfprotov6.Schema, prior, config tftypes.Value) tftypes.Value {
+	if prior.IsNull() {
+		return config
+	}
+
+	var priorAttrs, configAttrs map[string]tftypes.Value
+	_ = prior.As(&priorAttrs)
+	_ = config.As(&configAttrs)
+	// As shares the value's own map, which must not change.
+	proposed := make(map[string]tftypes.Value, len(configAttrs))
+	for name, v := range configAttrs {
+		proposed[name] = v
+	}
+	for _, a := range schema.Block.Attributes {
+		if a.Computed && configAttrs[a.Name].IsNull() {
+			proposed[a.Name] = priorAttrs[a.Name]
+		}
+	}
+	return tftypes.NewValue(config.Type(), proposed)
+}
+
+func stringValue(s string) tftypes.Value {
+	return tftypes.NewValue(tftypes.String, s)
+}
+
+func boolValue(b bool) tftypes.Value {
+	return tftypes.NewValue(tftypes.Bool, b)
+}
+
+func numberValue(n int64) tftypes.Value {
+	return tftypes.NewValue(tftypes.Number, n)
+}
+
+func stringMapValueOf(m map[string]string) tftypes.Value {
+	values := make(map[string]tftypes.Value, len(m))
+	for k, v := range m {
+		values[k] = stringValue(v)
+	}
+	return tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, values)
+}
+
+func hasErrors(diags []*tfprotov6.Diagnostic) bool {
+	for _, d := range diags {
+		if d.Severity == tfprotov6.DiagnosticSeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+func requireNoErrors(t *testing.T, step string, diags []*tfprotov6.Diagnostic) {
+	t.Helper()
+	if hasErrors(diags) {
+		t.Fatalf("%s failed:\n%s", step, formatDiagnostics(diags))
+	}
+}
+
+// requireError fails the test unless diags hold an error whose summary or
+// detail contains text.
+func requireError(t *testing.T, diags []*tfprotov6.Diagnostic, text string) {
+	t.Helper()
+	for _, d := range diags {
+		if d.Severity == tfprotov6.DiagnosticSeverityError && (strings.Contains(d.Summary, text) || strings.Contains(d.Detail, text)) {
+			return
+		}
+	}
+	t.Fatalf("no error containing %q in:\n%s", text, formatDiagnostics(diags))
+}
+
+func formatDiagnostics(diags []*tfprotov6.Diagnostic) string {
+	var b strings.Builder
+	for _, d := range diags {
+		severity := "warning"
+		if d.Severity == tfprotov6.DiagnosticSeverityError {
+			severity = "error"
+		}
+		b.WriteString(severity + ": " + d.Summary + ": " + d.Detail + "\n")
+	}
+	return b.String()
+}
+
+// readDataSource reads the data source of the given type with config, a map
+// of its attributes, and returns its state.
+func (p *testProvider) readDataSource(typeName string, config map[string]tftypes.Value) (tftypes.Value, []*tfprotov6.Diagnostic) {
+	p.t.Helper()
+
+	schema, ok := p.schemas.DataSourceSchemas[typeName]
+	if !ok {
+		p.t.Fatalf("no data source type %s", typeName)
+	}
+	resp, err := p.server.ReadDataSource(context.Background(), &tfprotov6.ReadDataSourceRequest{
+		TypeName: typeName,
+		Config:   p.dynamicValue(schema, objectValue(schema, config)),
+	})
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	if resp.State == nil {
+		return tftypes.NewValue(schema.ValueType(), nil), resp.Diagnostics
+	}
+	return p.unmarshal(schema, resp.State), resp.Diagnostics
+}
+
+// objectAttr returns the named attribute of an object value.
+func objectAttr(t *testing.T, v tftypes.Value, name string) tftypes.Value {
+	t.Helper()
+	var attrs map[string]tftypes.Value
+	if err := v.As(&attrs); err != nil {
+		t.Fatal(err)
+	}
+	return attrs[name]
+}
@@ -0,0 +1,115 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// loadTemplateBundle returns the additional template files configured through
+// either template_files or template_dir, keyed by slash-separated relative
+// path. It returns a nil map when neither is set.
+func loadTemplateBundle(ctx context.Context, files types.Map, dir types.String) (map[string]string, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	hasFiles := !files.IsNull() && !files.IsUnknown()
+	hasDir := dir.ValueString() != ""
+	if hasFiles && hasDir {
+		diags.AddAttributeError(
+			path.Root("template_dir"),
+			"Invalid Configuration",
+			"Only one of `template_files` or `template_dir` may be specified.",
+		)
+		return nil, diags
+	}
+
+	if hasFiles {
+		return stringMapValue(ctx, files)
+	}
+	if !hasDir {
+		return nil, diags
+	}
+
+	root := dir.ValueString()
+	bundle := map[string]string{}
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Skip hidden files and directories such as .git.
+		if p != root && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		bundle[filepath.ToSlash(rel)] = string(content)
+		return nil
+	})
+	if err != nil {
+		diags.AddAttributeError(
+			path.Root("template_dir"),
+			"Failed to read template directory",
+			fmt.Sprintf("Unable to read templates from %s: %v", root, err),
+		)
+		return nil, diags
+	}
+	return bundle, diags
+}
+
+// mapFullyKnown reports whether m and all of its elements are known.
+func mapFullyKnown(m types.Map) bool {
+	if m.IsUnknown() {
+		return false
+	}
+	for _, v := range m.Elements() {
+		if v.IsUnknown() {
+			return false
+		}
+	}
+	return true
+}
+
+// bundleChecksum returns a SHA-256 over every file path and content in the
+// bundle, so any added, removed, renamed or edited file changes it. An empty
+// bundle has an empty checksum.
+func bundleChecksum(bundle map[string]string) string {
+	if len(bundle) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(bundle))
+	for name := range bundle {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%d:%s%d:", len(name), name, len(bundle[name]))
+		h.Write([]byte(bundle[name]))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,198 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/render"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var _ datasource.DataSourceWithConfigure = &templateDataSource{}
+
+func NewTemplateDataSource() datasource.DataSource {
+	return &templateDataSource{}
+}
+
+type templateDataSource struct {
+	providerData *mirageProviderData
+}
+
+type templateDataSourceModel struct {
+	DagGeneratorBackendURL   types.String `tfsdk:"dag_generator_backend_url"`
+	UseGCPServiceAccountAuth types.Bool   `tfsdk:"use_gcp_service_account_auth"`
+	Headers                  types.Map    `tfsdk:"headers"`
+	TemplatePath             types.String `tfsdk:"template_path"`
+	TemplateFiles            types.Map    `tfsdk:"template_files"`
+	TemplateDir              types.String `tfsdk:"template_dir"`
+	ID                       types.String `tfsdk:"id"`
+	Checksum                 types.String `tfsdk:"checksum"`
+	Generation               types.String `tfsdk:"generation"`
+	LastModified             types.String `tfsdk:"last_modified"`
+	RequiredVariables        types.List   `tfsdk:"required_variables"`
+}
+
+func (d *templateDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	data, ok := req.ProviderData.(*mirageProviderData)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Data Source Configure Type",
+			fmt.Sprintf("Expected *mirageProviderData, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+		return
+	}
+	d.providerData = data
+}
+
+func (d *templateDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_template"
+}
+
+func (d *templateDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Reads the metadata of a Jinja2 template stored in Google Cloud Storage, S3, Azure Blob Storage or the local filesystem, and the variables it expects.",
+		Attributes: map[string]schema.Attribute{
+			"dag_generator_backend_url": schema.StringAttribute{
+				Description: "The base URL of the backend service. When omitted, the provider reads gs:// templates itself. Not used for file://, s3:// and az:// templates, which the provider always reads itself.",
+				Optional:    true,
+			},
+			"use_gcp_service_account_auth": schema.BoolAttribute{
+				Description: "If true, authenticate requests to the backend using the machine's GCP service account.",
+				Optional:    true,
+			},
+			"headers": schema.MapAttribute{
+				Description: "Headers sent with every backend request, overriding the provider's `default_headers`.",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"template_path": schema.StringAttribute{
+				Description: "The full path to the Jinja2 template: a gs://, s3://, az:// or file:// path.",
+				Required:    true,
+			},
+			"template_files": schema.MapAttribute{
+				Description: "Additional templates, keyed by relative path, that the template can `include`, `import` or `extend`, so the values they use are part of `required_variables`. Conflicts with `template_dir`.",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"template_dir": schema.StringAttribute{
+				Description: "A local directory whose files are additional templates, keyed by their path relative to the directory, as for `template_files`. Hidden files are skipped. Conflicts with `template_files`.",
+				Optional:    true,
+			},
+			"id": schema.StringAttribute{
+				Description: "The path of the template.",
+				Computed:    true,
+			},
+			"checksum": schema.StringAttribute{
+				Description: "The CRC32C checksum of the template file.",
+				Computed:    true,
+			},
+			"generation": schema.StringAttribute{
+				Description: "The version of the template file in its storage: the GCS generation number, the S3 or Azure version ID or ETag, or a pseudo-generation derived from the modification time of a local file.",
+				Computed:    true,
+			},
+			"last_modified": schema.StringAttribute{
+				Description: "When the template was last modified, as an RFC 3339 timestamp.",
+				Computed:    true,
+			},
+			"required_variables": schema.ListAttribute{
+				Description: "The values of the context the template cannot render without, found by parsing it, such as `dag_id` or `tasks[].task_id` for an attribute used on each element of a list. Values used only behind `is defined`, `default` or an `if` are left out. Null if the template includes, imports or extends a file missing from `template_files` or `template_dir`.",
+				ElementType: types.StringType,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func (d *templateDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	ctx, span := tracing.Start(ctx, "mirage_template.Read")
+	defer func() { tracing.End(span, resp.Diagnostics) }()
+
+	var config templateDataSourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	templatePath := config.TemplatePath.ValueString()
+	span.SetAttributes(attribute.String("mirage.template_path", templatePath))
+
+	headers, diags := stringMapValue(ctx, config.Headers)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	dagGenService, err := d.providerData.generatorFor(templatePath, config.DagGeneratorBackendURL.ValueString(), config.UseGCPServiceAccountAuth.ValueBool(), headers)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to create backend client", err.Error())
+		return
+	}
+
+	templateStatus, err := dagGenService.GetTemplateStatus(ctx, templatePath)
+	if err != nil {
+		addBackendError(&resp.Diagnostics, "Failed to read template status", err)
+		return
+	}
+	if !templateStatus.Exists {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("template_path"),
+			"Template not found",
+			fmt.Sprintf("The template %s does not exist. Check the path and that the backend's service account can read it.", templatePath),
+		)
+		return
+	}
+
+	config.ID = config.TemplatePath
+	config.Checksum = basetypes.NewStringValue(templateStatus.Checksum)
+	config.Generation = basetypes.NewStringValue(templateStatus.Generation)
+	config.LastModified = basetypes.NewStringValue(templateStatus.LastModified)
+
+	config.RequiredVariables = types.ListNull(types.StringType)
+	bundle, diags := loadTemplateBundle(ctx, config.TemplateFiles, config.TemplateDir)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if required, err := requiredVariables(ctx, dagGenService, templatePath, bundle); err != nil {
+		resp.Diagnostics.AddAttributeWarning(
+			path.Root("required_variables"),
+			"Could not parse template",
+			fmt.Sprintf("required_variables is unknown: %v", err),
+		)
+	} else {
+		config.RequiredVariables, diags = types.ListValueFrom(ctx, types.StringType, required)
+		resp.Diagnostics.Append(diags...)
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &config)...)
+}
+
+// requiredVariables reads the template at templatePath and returns the
+// values of the context it and the files of bundle it uses require.
+func requiredVariables(ctx context.Context, gen generator, templatePath string, bundle map[string]string) ([]string, error) {
+	template, err := readTemplate(ctx, gen, templatePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", templatePath, err)
+	}
+	usage, err := render.Variables(template, bundle)
+	if err != nil {
+		return nil, err
+	}
+	if !usage.Complete {
+		return nil, errors.New("the template includes, imports or extends a file missing from template_files or template_dir")
+	}
+	required := usage.Required()
+	if required == nil {
+		required = []string{}
+	}
+	return required, nil
+}
@@ -0,0 +1,296 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/client"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/storage"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	_ resource.Resource               = &templateResource{}
+	_ resource.ResourceWithConfigure  = &templateResource{}
+	_ resource.ResourceWithModifyPlan = &templateResource{}
+)
+
+func NewTemplateResource() resource.Resource {
+	return &templateResource{}
+}
+
+type templateResource struct {
+	providerData *mirageProviderData
+}
+
+type templateResourceModel struct {
+	DagGeneratorBackendURL   types.String `tfsdk:"dag_generator_backend_url"`
+	UseGCPServiceAccountAuth types.Bool   `tfsdk:"use_gcp_service_account_auth"`
+	Headers                  types.Map    `tfsdk:"headers"`
+	TemplatePath             types.String `tfsdk:"template_path"`
+	Content                  types.String `tfsdk:"content"`
+	ID                       types.String `tfsdk:"id"`
+	Checksum                 types.String `tfsdk:"checksum"`
+	Generation               types.String `tfsdk:"generation"`
+}
+
+func (r *templateResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	data, ok := req.ProviderData.(*mirageProviderData)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Resource Configure Type",
+			fmt.Sprintf("Expected *mirageProviderData, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+		return
+	}
+	r.providerData = data
+}
+
+func (r *templateResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_template"
+}
+
+func (r *templateResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Uploads a Jinja2 template to Google Cloud Storage through the backend and tracks its version.",
+		Attributes: map[string]schema.Attribute{
+			"dag_generator_backend_url": schema.StringAttribute{
+				Description: "The base URL of the backend service for this specific resource.",
+				Required:    true,
+			},
+			"use_gcp_service_account_auth": schema.BoolAttribute{
+				Description: "If true, authenticate requests to the backend using the machine's GCP service account.",
+				Optional:    true,
+			},
+			"headers": schema.MapAttribute{
+				Description: "Headers sent with every backend request for this resource, overriding the provider's `default_headers`.",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"template_path": schema.StringAttribute{
+				Description: "The full gs:// path the template is uploaded to. Other schemes are rejected at plan time.",
+				Required:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"content": schema.StringAttribute{
+				Description: "The content of the template, typically read with `file()`.",
+				Required:    true,
+			},
+			"id": schema.StringAttribute{
+				Description: "The gs:// path of the template, used as the resource ID.",
+				Computed:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"checksum": schema.StringAttribute{
+				Description: "The CRC32C checksum of the uploaded template.",
+				Computed:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"generation": schema.StringAttribute{
+				Description: "The GCS generation number of the uploaded template.",
+				Computed:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+		},
+	}
+}
+
+// ModifyPlan rejects template paths the backend cannot upload to, and plans a
+// re-upload when the configured content changes or the template in GCS no
+// longer matches it, e.g. after it was overwritten outside Terraform.
+func (r *templateResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var plan templateResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if p := plan.TemplatePath; !p.IsUnknown() && !storage.IsGCS(p.ValueString()) {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("template_path"),
+			"Invalid Template Path",
+			fmt.Sprintf("The backend uploads templates to Google Cloud Storage only, so the path must start with gs://, not %q.", p.ValueString()),
+		)
+		return
+	}
+
+	if req.State.Raw.IsNull() {
+		return
+	}
+	var state templateResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if plan.Content.IsUnknown() || client.CRC32C([]byte(plan.Content.ValueString())) != state.Checksum.ValueString() {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("checksum"), types.StringUnknown())...)
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("generation"), types.StringUnknown())...)
+	}
+}
+
+func (r *templateResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, span := tracing.Start(ctx, "mirage_template.Create")
+	defer func() { tracing.End(span, resp.Diagnostics) }()
+
+	var plan templateResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	span.SetAttributes(attribute.String("mirage.template_path", plan.TemplatePath.ValueString()))
+
+	r.upload(ctx, &plan, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *templateResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, span := tracing.Start(ctx, "mirage_template.Read")
+	defer func() { tracing.End(span, resp.Diagnostics) }()
+
+	var state templateResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	span.SetAttributes(attribute.String("mirage.template_path", state.TemplatePath.ValueString()))
+
+	dagGenService, ok := r.service(ctx, state, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+
+	templateStatus, err := dagGenService.GetTemplateStatus(ctx, state.TemplatePath.ValueString())
+	if err != nil {
+		addBackendError(&resp.Diagnostics, "Failed to read template status", err)
+		return
+	}
+	if !templateStatus.Exists {
+		resp.Diagnostics.AddWarning("Template not found", "The template no longer exists in GCS and will be removed from the state.")
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	state.Checksum = basetypes.NewStringValue(templateStatus.Checksum)
+	state.Generation = basetypes.NewStringValue(templateStatus.Generation)
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+}
+
+func (r *templateResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx, span := tracing.Start(ctx, "mirage_template.Update")
+	defer func() { tracing.End(span, resp.Diagnostics) }()
+
+	var plan templateResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	span.SetAttributes(attribute.String("mirage.template_path", plan.TemplatePath.ValueString()))
+
+	var state templateResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Only backend settings changed and GCS already holds this content.
+	if !plan.Checksum.IsUnknown() {
+		plan.ID = state.ID
+		plan.Checksum = state.Checksum
+		plan.Generation = state.Generation
+		resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+		return
+	}
+
+	r.upload(ctx, &plan, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *templateResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, span := tracing.Start(ctx, "mirage_template.Delete")
+	defer func() { tracing.End(span, resp.Diagnostics) }()
+
+	var state templateResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	span.SetAttributes(attribute.String("mirage.template_path", state.TemplatePath.ValueString()))
+
+	dagGenService, ok := r.service(ctx, state, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+
+	if err := dagGenService.Delete(ctx, state.TemplatePath.ValueString()); err != nil {
+		addBackendError(&resp.Diagnostics, "Failed to delete template", err)
+		return
+	}
+}
+
+// upload writes the planned content and records the resulting checksum and generation in model.
+func (r *templateResource) upload(ctx context.Context, model *templateResourceModel, diags *diag.Diagnostics) {
+	dagGenService, ok := r.service(ctx, *model, diags)
+	if !ok {
+		return
+	}
+
+	uploadResult, err := dagGenService.UploadTemplate(ctx, model.TemplatePath.ValueString(), model.Content.ValueString())
+	if err != nil {
+		addBackendError(diags, "Failed to upload template", err)
+		return
+	}
+
+	model.ID = model.TemplatePath
+	model.Checksum = basetypes.NewStringValue(uploadResult.Checksum)
+	model.Generation = basetypes.NewStringValue(uploadResult.Generation)
+}
+
+func (r *templateResource) service(ctx context.Context, model templateResourceModel, diags *diag.Diagnostics) (*client.DagGeneratorService, bool) {
+	headers, d := stringMapValue(ctx, model.Headers)
+	diags.Append(d...)
+	if diags.HasError() {
+		return nil, false
+	}
+
+	dagGenService, err := r.providerData.newDagGeneratorService(model.DagGeneratorBackendURL.ValueString(), model.UseGCPServiceAccountAuth.ValueBool(), headers)
+	if err != nil {
+		diags.AddError("Failed to create backend client", err.Error())
+		return nil, false
+	}
+	return dagGenService, true
+}
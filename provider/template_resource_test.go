@@ -0,0 +1,27 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+func TestTemplateRejectsNonGCSPath(t *testing.T) {
+	r := newTestProvider(t, nil).resource("mirage_template")
+
+	for _, p := range []string{"file:///tmp/pipeline.py.j2", "s3://bucket/pipeline.py.j2", "bucket/pipeline.py.j2"} {
+		resp, _ := r.plan(map[string]tftypes.Value{
+			"dag_generator_backend_url": stringValue("http://backend.invalid"),
+			"template_path":             stringValue(p),
+			"content":                   stringValue("{{ dag_id }}"),
+		})
+		requireError(t, resp.Diagnostics, "the path must start with gs://")
+	}
+
+	resp, _ := r.plan(map[string]tftypes.Value{
+		"dag_generator_backend_url": stringValue("http://backend.invalid"),
+		"template_path":             stringValue("gs://bucket/pipeline.py.j2"),
+		"content":                   stringValue("{{ dag_id }}"),
+	})
+	requireNoErrors(t, "plan with a gs:// path", resp.Diagnostics)
+}
@@ -0,0 +1,81 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/client"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/render"
+	"github.com/mm-aranda/terraform-provider-mirage/internal/storage"
+)
+
+// readTemplate returns the content of the template at templatePath, read
+// through gen. errTemplateNotFound is returned if there is none.
+func readTemplate(ctx context.Context, gen generator, templatePath string) (string, error) {
+	switch g := gen.(type) {
+	case *client.DagGeneratorService:
+		status, err := g.GetStatusWithOptions(ctx, templatePath, client.StatusOptions{IncludeContent: true})
+		if errors.Is(err, client.ErrNotFound) {
+			return "", errTemplateNotFound
+		}
+		if err != nil {
+			return "", err
+		}
+		return status.Content, nil
+	case *directGenerator:
+		store, err := g.store(ctx, templatePath)
+		if err != nil {
+			return "", err
+		}
+		content, err := store.Read(ctx, templatePath)
+		if errors.Is(err, storage.ErrNotFound) {
+			return "", errTemplateNotFound
+		}
+		if err != nil {
+			return "", err
+		}
+		return string(content), nil
+	default:
+		return "", fmt.Errorf("cannot read templates through %T", gen)
+	}
+}
+
+var errTemplateNotFound = errors.New("template not found")
+
+// checkTemplateVariables compares the context a template uses with
+// contextJSON. Each value the template requires that the context lacks is an
+// error on context_json, and each top-level key the template never uses a
+// warning. A template the provider cannot parse is left to fail when it is
+// rendered.
+func checkTemplateVariables(template string, bundle map[string]string, contextJSON string, diags *diag.Diagnostics) {
+	usage, err := render.Variables(template, bundle)
+	if err != nil {
+		return
+	}
+	missing, unused, err := usage.Check(contextJSON)
+	if err != nil {
+		return
+	}
+
+	for _, m := range missing {
+		where := fmt.Sprintf("line %d", m.Line)
+		if m.Template != "" {
+			where += " of " + m.Template
+		}
+		diags.AddAttributeError(
+			path.Root("context_json"),
+			"Missing Template Variable",
+			fmt.Sprintf("The template uses %s on %s, but context_json has no %s. Add it to the context, or use `is defined` or the `default` filter in the template if it is optional.", m.Reference, where, contextLocation(m.Location)),
+		)
+	}
+	for _, key := range unused {
+		diags.AddAttributeWarning(
+			path.Root("context_json"),
+			"Unused Context Key",
+			fmt.Sprintf("context_json sets %s, which the template never uses.", contextLocation([]string{key})),
+		)
+	}
+}